@@ -0,0 +1,509 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AlertController struct{}
+
+func NewAlertController() *AlertController {
+	return &AlertController{}
+}
+
+// 告警规则请求
+type alertRuleRequest struct {
+	Name            string  `json:"name" binding:"required"`
+	Description     string  `json:"description"`
+	Metric          string  `json:"metric" binding:"required"`
+	Operator        string  `json:"operator" binding:"required"`
+	Threshold       float64 `json:"threshold"`
+	DurationSeconds int     `json:"duration_seconds"`
+	RepeatSeconds   int     `json:"repeat_seconds"`
+	Severity        string  `json:"severity"`
+	HostIDs         []uint  `json:"host_ids"` // 与 tag_ids 均为空表示所有主机
+	TagIDs          []uint  `json:"tag_ids"`  // 带有任一标签的主机
+	ChannelIDs      []uint  `json:"channel_ids"`
+	Enabled         *bool   `json:"enabled"`
+}
+
+// 校验请求并填充到规则
+func (a *AlertController) applyRuleRequest(req *alertRuleRequest, rule *models.AlertRule) string {
+	if !services.ValidAlertMetric(req.Metric) {
+		return "不支持的指标，可选: host_up, " + strings.Join(services.MetricNames(), ", ")
+	}
+	if !services.ValidAlertOperator(req.Operator) {
+		return "不支持的比较运算符"
+	}
+	if req.DurationSeconds < 0 || req.RepeatSeconds < 0 {
+		return "持续时间和重复间隔不能为负数"
+	}
+	switch req.Severity {
+	case "":
+		req.Severity = "warning"
+	case "warning", "critical":
+	default:
+		return "告警级别只能为 warning 或 critical"
+	}
+
+	rule.Name = req.Name
+	rule.Description = req.Description
+	rule.Metric = req.Metric
+	rule.Operator = req.Operator
+	rule.Threshold = req.Threshold
+	rule.DurationSeconds = req.DurationSeconds
+	rule.RepeatSeconds = req.RepeatSeconds
+	rule.Severity = req.Severity
+	rule.Enabled = true
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return ""
+}
+
+// 加载规则关联的主机、标签和通知渠道
+func (a *AlertController) loadRuleRelations(req *alertRuleRequest, rule *models.AlertRule) string {
+	req.HostIDs = uniqueIDs(req.HostIDs)
+	req.TagIDs = uniqueIDs(req.TagIDs)
+	req.ChannelIDs = uniqueIDs(req.ChannelIDs)
+
+	var hosts []models.Host
+	if len(req.HostIDs) > 0 {
+		config.DB.Where("id IN ?", req.HostIDs).Find(&hosts)
+		if len(hosts) != len(req.HostIDs) {
+			return "部分主机不存在"
+		}
+	}
+	var tags []models.Tag
+	if len(req.TagIDs) > 0 {
+		config.DB.Where("id IN ?", req.TagIDs).Find(&tags)
+		if len(tags) != len(req.TagIDs) {
+			return "部分标签不存在"
+		}
+	}
+	var channels []models.NotificationChannel
+	if len(req.ChannelIDs) > 0 {
+		config.DB.Where("id IN ?", req.ChannelIDs).Find(&channels)
+		if len(channels) != len(req.ChannelIDs) {
+			return "部分通知渠道不存在"
+		}
+	}
+	rule.Hosts = hosts
+	rule.Tags = tags
+	rule.Channels = channels
+	return ""
+}
+
+// 获取告警规则列表
+func (a *AlertController) GetRules(c *gin.Context) {
+	var rules []models.AlertRule
+	if err := config.DB.Preload("Hosts").Preload("Tags").Preload("Channels").Order("id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取告警规则失败"})
+		return
+	}
+	for i := range rules {
+		hideChannelSecrets(rules[i].Channels)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": rules})
+}
+
+// 创建告警规则
+func (a *AlertController) CreateRule(c *gin.Context) {
+	var req alertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	var rule models.AlertRule
+	if msg := a.applyRuleRequest(&req, &rule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if msg := a.loadRuleRelations(&req, &rule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := config.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建告警规则失败: " + err.Error()})
+		return
+	}
+	// GORM 不会写入值为 false 的带默认值字段
+	if !rule.Enabled {
+		config.DB.Model(&rule).Update("enabled", false)
+	}
+
+	hideChannelSecrets(rule.Channels)
+	c.JSON(http.StatusCreated, gin.H{"data": rule})
+}
+
+// 更新告警规则
+func (a *AlertController) UpdateRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则ID"})
+		return
+	}
+
+	var rule models.AlertRule
+	if err := config.DB.First(&rule, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "告警规则不存在"})
+		return
+	}
+
+	var req alertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if msg := a.applyRuleRequest(&req, &rule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+	if msg := a.loadRuleRelations(&req, &rule); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("*").Omit("Hosts", "Tags", "Channels", "CreatedAt").Updates(&rule).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&rule).Association("Hosts").Replace(rule.Hosts); err != nil {
+			return err
+		}
+		if err := tx.Model(&rule).Association("Tags").Replace(rule.Tags); err != nil {
+			return err
+		}
+		return tx.Model(&rule).Association("Channels").Replace(rule.Channels)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新告警规则失败: " + err.Error()})
+		return
+	}
+
+	hideChannelSecrets(rule.Channels)
+	c.JSON(http.StatusOK, gin.H{"data": rule})
+}
+
+// 删除告警规则，未恢复的告警在下一轮评估时结束
+func (a *AlertController) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的规则ID"})
+		return
+	}
+
+	result := config.DB.Delete(&models.AlertRule{}, uint(id))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "告警规则不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "告警规则删除成功"})
+}
+
+// 返回给前端的渠道不包含SMTP密码
+func hideChannelSecrets(channels []models.NotificationChannel) {
+	for i := range channels {
+		channels[i].SMTPPassword = ""
+	}
+}
+
+// 获取通知渠道列表
+func (a *AlertController) GetChannels(c *gin.Context) {
+	var channels []models.NotificationChannel
+	if err := config.DB.Order("id ASC").Find(&channels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取通知渠道失败"})
+		return
+	}
+	hideChannelSecrets(channels)
+
+	c.JSON(http.StatusOK, gin.H{"data": channels})
+}
+
+// 创建通知渠道（仅管理员）
+func (a *AlertController) CreateChannel(c *gin.Context) {
+	if currentUser(c).Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以创建通知渠道"})
+		return
+	}
+	var channel models.NotificationChannel
+	if err := c.ShouldBindJSON(&channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if channel.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "渠道名称不能为空"})
+		return
+	}
+	if err := services.ValidateNotificationChannel(&channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel.ID = 0
+	if err := config.DB.Create(&channel).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建通知渠道失败: " + err.Error()})
+		return
+	}
+
+	channel.SMTPPassword = ""
+	c.JSON(http.StatusCreated, gin.H{"data": channel})
+}
+
+// 更新通知渠道（仅管理员），SMTP密码留空表示不修改
+func (a *AlertController) UpdateChannel(c *gin.Context) {
+	if currentUser(c).Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以修改通知渠道"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的渠道ID"})
+		return
+	}
+
+	var existing models.NotificationChannel
+	if err := config.DB.First(&existing, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "通知渠道不存在"})
+		return
+	}
+
+	var channel models.NotificationChannel
+	if err := c.ShouldBindJSON(&channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if channel.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "渠道名称不能为空"})
+		return
+	}
+	if err := services.ValidateNotificationChannel(&channel); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	channel.ID = existing.ID
+	channel.CreatedAt = existing.CreatedAt
+	if channel.SMTPPassword == "" {
+		channel.SMTPPassword = existing.SMTPPassword
+	}
+	if err := config.DB.Save(&channel).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新通知渠道失败: " + err.Error()})
+		return
+	}
+
+	channel.SMTPPassword = ""
+	c.JSON(http.StatusOK, gin.H{"data": channel})
+}
+
+// 删除通知渠道
+func (a *AlertController) DeleteChannel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的渠道ID"})
+		return
+	}
+
+	var channel models.NotificationChannel
+	if err := config.DB.First(&channel, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "通知渠道不存在"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM alert_rule_channels WHERE notification_channel_id = ?", channel.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&channel).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "通知渠道删除成功"})
+}
+
+// 向通知渠道发送测试消息（仅管理员）
+func (a *AlertController) TestChannel(c *gin.Context) {
+	if currentUser(c).Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以测试通知渠道"})
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的渠道ID"})
+		return
+	}
+
+	var channel models.NotificationChannel
+	if err := config.DB.First(&channel, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "通知渠道不存在"})
+		return
+	}
+
+	n := &services.AlertNotification{
+		Status:   "test",
+		RuleName: "测试通知",
+		Severity: "warning",
+		StartsAt: time.Now(),
+	}
+	if err := services.SendNotification(&channel, n); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "发送测试通知失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "测试通知已发送"})
+}
+
+// 获取告警列表，可按状态、规则和主机筛选
+func (a *AlertController) GetAlerts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	query := config.DB.Model(&models.Alert{})
+	if state := c.Query("state"); state != "" {
+		query = query.Where("state IN ?", strings.Split(state, ","))
+	}
+	if ruleID := c.Query("rule_id"); ruleID != "" {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if hostID := c.Query("host_id"); hostID != "" {
+		query = query.Where("host_id = ?", hostID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var alerts []models.Alert
+	result := query.Preload("Rule", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Preload("Host", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+		Order("starts_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&alerts)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取告警列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"alerts":    alerts,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}})
+}
+
+// 获取静默列表，默认只返回未过期的静默
+func (a *AlertController) GetSilences(c *gin.Context) {
+	query := config.DB.Preload("Rule").Preload("Host").Order("ends_at DESC")
+	if c.Query("all") != "true" {
+		query = query.Where("ends_at > ?", time.Now())
+	}
+
+	var silences []models.AlertSilence
+	if err := query.Find(&silences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取静默列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": silences})
+}
+
+// 静默请求，开始时间为空表示立即生效
+type silenceRequest struct {
+	RuleID   *uint      `json:"rule_id"`
+	HostID   *uint      `json:"host_id"`
+	StartsAt *time.Time `json:"starts_at"`
+	EndsAt   time.Time  `json:"ends_at" binding:"required"`
+	Comment  string     `json:"comment"`
+}
+
+// 创建静默
+func (a *AlertController) CreateSilence(c *gin.Context) {
+	var req silenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	silence := models.AlertSilence{
+		RuleID:    req.RuleID,
+		HostID:    req.HostID,
+		StartsAt:  time.Now(),
+		EndsAt:    req.EndsAt,
+		Comment:   req.Comment,
+		CreatedBy: currentUser(c).ID,
+	}
+	if req.StartsAt != nil {
+		silence.StartsAt = *req.StartsAt
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "结束时间必须晚于开始时间"})
+		return
+	}
+	if req.RuleID != nil {
+		if err := config.DB.First(&models.AlertRule{}, *req.RuleID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "告警规则不存在"})
+			return
+		}
+	}
+	if req.HostID != nil {
+		if err := config.DB.First(&models.Host{}, *req.HostID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "主机不存在"})
+			return
+		}
+	}
+
+	if err := config.DB.Create(&silence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建静默失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": silence})
+}
+
+// 删除静默
+func (a *AlertController) DeleteSilence(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的静默ID"})
+		return
+	}
+
+	result := config.DB.Delete(&models.AlertSilence{}, uint(id))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "静默不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "静默删除成功"})
+}
@@ -1,22 +1,30 @@
 package controllers
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"host-manager/models"
 	"host-manager/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
 type AuditController struct {
 	auditService *services.AuditService
+	authService  *services.AuthService
 }
 
 func NewAuditController() *AuditController {
 	return &AuditController{
 		auditService: services.NewAuditService(),
+		authService:  services.NewAuthService(),
 	}
 }
 
@@ -78,6 +86,317 @@ func (a *AuditController) GetSessionOperations(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": operations})
 }
 
+// 导出会话的 asciicast v2 录像，可用 asciinema play 等标准播放器回放；
+// 没有录像文件的旧会话由操作记录生成
+func (a *AuditController) GetSessionCast(c *gin.Context) {
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return
+	}
+
+	session, err := a.auditService.GetSession(uint(sessionID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"session-%d.cast\"", session.ID))
+	c.Header("Content-Type", "application/x-asciicast")
+	if path := a.auditService.SessionCastPath(session); path != "" {
+		c.File(path)
+		return
+	}
+
+	data, err := a.auditService.BuildSessionCast(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成录像失败"})
+		return
+	}
+	c.Data(http.StatusOK, "application/x-asciicast", data)
+}
+
+// 按原始时间回放会话：/api/audit/sessions/:id/play?speed=2&idle=3&start=60&paused=true
+// speed 为倍速（0.5-16），idle 为空闲间隔上限秒数（默认0不跳过），start 为开始位置秒数。
+// 服务端推送 JSON 消息（output 的 data 直接写入 xterm，reset 时清空终端），
+// 浏览器发送 {"type":"pause|play|speed|seek|idle","value":..} 控制回放
+func (a *AuditController) HandlePlayback(c *gin.Context) {
+	user, err := a.authService.ValidateToken(requestToken(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供有效的认证token"})
+		return
+	}
+
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return
+	}
+
+	opts := services.PlaybackOptions{Speed: 1, Paused: c.Query("paused") == "true"}
+	for name, target := range map[string]*float64{"speed": &opts.Speed, "idle": &opts.Idle, "start": &opts.Start} {
+		if v := c.Query(name); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || f < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的参数 " + name})
+				return
+			}
+			*target = f
+		}
+	}
+	if opts.Speed < services.MinPlaybackSpeed || opts.Speed > services.MaxPlaybackSpeed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("speed 必须在%g到%g之间", services.MinPlaybackSpeed, services.MaxPlaybackSpeed)})
+		return
+	}
+
+	session, err := a.auditService.GetSession(uint(sessionID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+		return
+	}
+	playback, err := a.auditService.OpenPlayback(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "打开录像失败: " + err.Error()})
+		return
+	}
+	defer playback.Close()
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	a.auditService.RecordAction(user.ID, session.HostID, "audit.playback", session.SessionID, "", nil)
+
+	// 读取控制命令，连接断开时关闭通道结束回放
+	commands := make(chan models.PlaybackCommand)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(commands)
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var cmd models.PlaybackCommand
+			if err := json.Unmarshal(message, &cmd); err != nil {
+				continue
+			}
+			select {
+			case commands <- cmd:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	err = playback.Run(opts, commands, func(msg models.PlaybackMessage) error {
+		return conn.WriteJSON(msg)
+	})
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()))
+	}
+}
+
+// 获取运维操作审计日志
+func (a *AuditController) GetAuditLogs(c *gin.Context) {
+	var req models.AuditQueryRequest
+
+	if userID := c.Query("user_id"); userID != "" {
+		if id, err := strconv.ParseUint(userID, 10, 32); err == nil {
+			uid := uint(id)
+			req.UserID = &uid
+		}
+	}
+
+	if hostID := c.Query("host_id"); hostID != "" {
+		if id, err := strconv.ParseUint(hostID, 10, 32); err == nil {
+			hid := uint(id)
+			req.HostID = &hid
+		}
+	}
+
+	if page := c.Query("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil {
+			req.Page = p
+		}
+	}
+
+	if pageSize := c.Query("page_size"); pageSize != "" {
+		if ps, err := strconv.Atoi(pageSize); err == nil {
+			req.PageSize = ps
+		}
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	logs, total, err := a.auditService.GetAuditLogs(req, c.Query("action"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取审计日志失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"logs":      logs,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	}})
+}
+
+// 搜索所有会话中执行过的命令：/api/audit/commands?q=systemctl&user_id=1&host_id=2&session_id=3&start_time=&end_time=
+// 时间为 RFC3339 或 unix 秒
+func (a *AuditController) SearchCommands(c *gin.Context) {
+	req, ok := parseAuditQuery(c)
+	if !ok {
+		return
+	}
+
+	var sessionID *uint
+	if v := c.Query("session_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的参数 session_id"})
+			return
+		}
+		sid := uint(id)
+		sessionID = &sid
+	}
+
+	commands, total, err := a.auditService.SearchCommands(req, sessionID, strings.TrimSpace(c.Query("q")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索命令失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"commands":  commands,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	}})
+}
+
+// 在所有会话的终端输入输出和还原出的命令中全文搜索：/api/audit/search?q=rm -rf&type=command&group=session
+// type 为 input、output 或 command（默认全部）；输入按键逐条记录，input 只能匹配一次粘贴的内容，
+// 查找执行过的命令应使用 command。group=session 时每个会话只返回第一处匹配；
+// 结果中的 offset 为匹配内容在会话中的秒数，可作为回放的 start 参数
+func (a *AuditController) SearchOperations(c *gin.Context) {
+	keyword := strings.TrimSpace(c.Query("q"))
+	if keyword == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请提供搜索内容"})
+		return
+	}
+
+	opType := c.Query("type")
+	if opType != "" && opType != "input" && opType != "output" && opType != "command" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type 只能为 input、output 或 command"})
+		return
+	}
+
+	req, ok := parseAuditQuery(c)
+	if !ok {
+		return
+	}
+
+	results, total, err := a.auditService.SearchOperations(req, keyword, opType, c.Query("group") == "session")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索审计记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"results":   results,
+		"total":     total,
+		"page":      req.Page,
+		"page_size": req.PageSize,
+	}})
+}
+
+// 解析审计查询共用的 user_id、host_id、start_time、end_time（RFC3339 或 unix 秒）和分页参数，
+// 参数无效时已返回 400
+func parseAuditQuery(c *gin.Context) (models.AuditQueryRequest, bool) {
+	var req models.AuditQueryRequest
+
+	for name, target := range map[string]**uint{"user_id": &req.UserID, "host_id": &req.HostID} {
+		if v := c.Query(name); v != "" {
+			id, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的参数 " + name})
+				return req, false
+			}
+			uid := uint(id)
+			*target = &uid
+		}
+	}
+
+	for name, target := range map[string]**time.Time{"start_time": &req.StartTime, "end_time": &req.EndTime} {
+		if v := c.Query(name); v != "" {
+			t, err := parseTimeParam(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "无效的时间 " + name})
+				return req, false
+			}
+			*target = &t
+		}
+	}
+
+	if page := c.Query("page"); page != "" {
+		if p, err := strconv.Atoi(page); err == nil {
+			req.Page = p
+		}
+	}
+
+	if pageSize := c.Query("page_size"); pageSize != "" {
+		if ps, err := strconv.Atoi(pageSize); err == nil {
+			req.PageSize = ps
+		}
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+	return req, true
+}
+
+// 从录像重新还原会话执行的命令，用于命令还原功能上线前的会话
+func (a *AuditController) RebuildSessionCommands(c *gin.Context) {
+	sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的会话ID"})
+		return
+	}
+
+	session, err := a.auditService.GetSession(uint(sessionID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在"})
+		return
+	}
+	if session.Status == "active" {
+		c.JSON(http.StatusConflict, gin.H{"error": "会话尚未结束"})
+		return
+	}
+
+	count, err := a.auditService.RebuildSessionCommands(session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "还原命令失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"session_id": session.ID, "commands": count}})
+}
+
 // 删除审计会话
 func (a *AuditController) DeleteSession(c *gin.Context) {
 	sessionIDStr := c.Param("id")
@@ -118,13 +118,39 @@ func (a *AuthController) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 简单的token验证（实际项目中应该验证JWT）
-		if len(token) < 32 {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的token"})
+		user, err := a.authService.ValidateToken(token)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
+		c.Set("user", user)
 		c.Next()
 	}
 }
+
+// 注销登录
+func (a *AuthController) Logout(c *gin.Context) {
+	a.authService.RevokeToken(c.GetHeader("Authorization"))
+	c.JSON(http.StatusOK, gin.H{"message": "已退出登录"})
+}
+
+// 获取当前登录用户（需经过 AuthMiddleware）
+func currentUser(c *gin.Context) *models.User {
+	if v, ok := c.Get("user"); ok {
+		if user, ok := v.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// 从查询参数或请求头中获取token（WebSocket无法自定义请求头）
+func requestToken(c *gin.Context) string {
+	token := c.Query("token")
+	if token == "" {
+		token = c.GetHeader("Authorization")
+	}
+	return token
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DockerController struct {
+	sshService   *services.SSHService
+	auditService *services.AuditService
+}
+
+func NewDockerController() *DockerController {
+	return &DockerController{
+		sshService:   services.NewSSHService(),
+		auditService: services.NewAuditService(),
+	}
+}
+
+// 统一处理 docker 命令的错误，未安装 Docker 返回 404
+func dockerError(c *gin.Context, prefix string, err error) {
+	if errors.Is(err, services.ErrDockerNotInstalled) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": prefix + ": " + err.Error()})
+}
+
+// 获取容器列表
+// 参数: state 按状态筛选（如 running、exited）；q 按名称或镜像关键字筛选
+func (d *DockerController) GetContainers(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	containers, err := d.sshService.ListDockerContainers(host)
+	if err != nil {
+		dockerError(c, "获取容器列表失败", err)
+		return
+	}
+
+	state := c.Query("state")
+	keyword := strings.ToLower(c.Query("q"))
+	filtered := make([]models.DockerContainer, 0, len(containers))
+	for _, container := range containers {
+		if state != "" && container.State != state {
+			continue
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(container.Name), keyword) &&
+			!strings.Contains(strings.ToLower(container.Image), keyword) {
+			continue
+		}
+		filtered = append(filtered, container)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": filtered})
+}
+
+// 获取镜像列表
+func (d *DockerController) GetImages(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	images, err := d.sshService.ListDockerImages(host)
+	if err != nil {
+		dockerError(c, "获取镜像列表失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": images})
+}
+
+// 获取卷列表
+func (d *DockerController) GetVolumes(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	volumes, err := d.sshService.ListDockerVolumes(host)
+	if err != nil {
+		dockerError(c, "获取卷列表失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": volumes})
+}
+
+// 获取网络列表
+func (d *DockerController) GetNetworks(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	networks, err := d.sshService.ListDockerNetworks(host)
+	if err != nil {
+		dockerError(c, "获取网络列表失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": networks})
+}
+
+// 获取运行中容器的资源使用情况
+// 参数: container 只查询指定容器
+func (d *DockerController) GetStats(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	stats, err := d.sshService.DockerStats(host, c.Query("container"))
+	if err != nil {
+		dockerError(c, "获取容器资源使用情况失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": stats})
+}
+
+// 对容器执行 start/stop/restart/remove，remove 时 force=true 强制删除运行中的容器
+func (d *DockerController) ContainerAction(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	container, action := c.Param("container"), c.Param("action")
+	if err := services.ValidateDockerContainer(container); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.ValidDockerAction(action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的操作: " + action})
+		return
+	}
+	force := c.Query("force") == "true"
+
+	result, err := d.sshService.DockerAction(host, container, action, force)
+	detail := "state: " + result.State
+	if force {
+		detail = "force"
+	}
+	user := currentUser(c)
+	d.auditService.RecordAction(user.ID, host.ID, "docker."+action, container, detail, err)
+	if err != nil {
+		if errors.Is(err, services.ErrDockerNotInstalled) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": action + " " + container + " 失败: " + err.Error(), "data": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// 获取容器的最近日志，实时跟踪使用 /api/logs/tail?container=
+// 参数: lines 行数（默认200，最多5000）；since 开始时间；timestamps=true 带时间戳
+func (d *DockerController) ContainerLogs(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	container := c.Param("container")
+	opts := services.DockerLogOptions{
+		Since:      c.Query("since"),
+		Timestamps: c.Query("timestamps") == "true",
+	}
+	if v := c.Query("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 5000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lines 必须在1到5000之间"})
+			return
+		}
+		opts.Lines = n
+	}
+
+	output, err := d.sshService.DockerLogs(host, container, opts)
+	user := currentUser(c)
+	d.auditService.RecordAction(user.ID, host.ID, "docker.logs", container, "", err)
+	if err != nil {
+		dockerError(c, "获取容器日志失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"container": container,
+		"logs":      output,
+	}})
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"host-manager/config"
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type GroupController struct{}
+
+func NewGroupController() *GroupController {
+	return &GroupController{}
+}
+
+// 获取分组列表，默认按路径排序的平铺列表，tree=true 时返回树形结构
+func (g *GroupController) GetGroups(c *gin.Context) {
+	groups, err := services.ListHostGroups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取分组列表失败"})
+		return
+	}
+	if c.Query("tree") == "true" {
+		c.JSON(http.StatusOK, gin.H{"data": services.BuildGroupTree(groups)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": groups})
+}
+
+// 校验分组请求，同一上级下的分组不能重名
+func validateGroupRequest(req *models.HostGroupRequest, id uint) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || strings.Contains(req.Name, "/") {
+		return "分组名不能为空且不能包含 /"
+	}
+	if err := services.ValidateGroupParent(id, req.ParentID); err != nil {
+		return err.Error()
+	}
+
+	query := config.DB.Model(&models.HostGroup{}).Where("name = ? AND id <> ?", req.Name, id)
+	if req.ParentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *req.ParentID)
+	}
+	var count int64
+	query.Count(&count)
+	if count > 0 {
+		return "同一上级下已存在同名分组"
+	}
+	return ""
+}
+
+// 创建分组
+func (g *GroupController) CreateGroup(c *gin.Context) {
+	var req models.HostGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if msg := validateGroupRequest(&req, 0); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	group := models.HostGroup{
+		Name:        req.Name,
+		ParentID:    req.ParentID,
+		Description: req.Description,
+	}
+	if err := config.DB.Create(&group).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建分组失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": group})
+}
+
+// 更新分组，可修改上级以移动分组
+func (g *GroupController) UpdateGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分组ID"})
+		return
+	}
+
+	var group models.HostGroup
+	if err := config.DB.First(&group, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分组不存在"})
+		return
+	}
+
+	var req models.HostGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if msg := validateGroupRequest(&req, group.ID); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	group.Name = req.Name
+	group.ParentID = req.ParentID
+	group.Description = req.Description
+	if err := config.DB.Save(&group).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新分组失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": group})
+}
+
+// 删除分组，有子分组时不允许删除，分组内的主机移到上级分组
+func (g *GroupController) DeleteGroup(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分组ID"})
+		return
+	}
+
+	var group models.HostGroup
+	if err := config.DB.First(&group, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "分组不存在"})
+		return
+	}
+
+	var children int64
+	config.DB.Model(&models.HostGroup{}).Where("parent_id = ?", group.ID).Count(&children)
+	if children > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "请先删除或移走子分组"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Host{}).Where("group_id = ?", group.ID).Update("group_id", group.ParentID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&group).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除分组失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "分组删除成功"})
+}
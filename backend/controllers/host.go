@@ -1,59 +1,158 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"host-manager/config"
 	"host-manager/models"
 	"host-manager/services"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type HostController struct {
-	sshService *services.SSHService
+	sshService   *services.SSHService
+	auditService *services.AuditService
 }
 
 func NewHostController() *HostController {
 	return &HostController{
-		sshService: services.NewSSHService(),
+		sshService:   services.NewSSHService(),
+		auditService: services.NewAuditService(),
 	}
 }
 
+// 主机列表可用的排序字段
+var hostSortFields = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"ip_address": "ip_address",
+	"status":     "status",
+	"os":         "facts_os",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
 // GetHosts 获取主机列表
+// 参数: q 按名称、地址、主机名、系统、内核、CPU型号关键字搜索；name、ip 按名称、地址子串筛选；
+// tag 按标签筛选（可重复，需同时带有所有标签）；group_id 按分组筛选（包含子分组，0为未分组）；
+// status、os、os_version、arch、virtualization、package_manager 精确筛选；kernel 按前缀筛选；
+// sort 排序字段（默认name），order asc/desc；page、page_size 分页（默认20，最多1000）
 func (h *HostController) GetHosts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 1000 {
+		pageSize = 1000
+	}
+
+	sortField, ok := hostSortFields[c.DefaultQuery("sort", "name")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的排序字段: " + c.Query("sort")})
+		return
+	}
+	order := sortField + " ASC"
+	if c.Query("order") == "desc" {
+		order = sortField + " DESC"
+	}
+
+	query, err := filterHosts(c, config.DB.Model(&models.Host{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var total int64
+	query.Count(&total)
+
 	var hosts []models.Host
-	result := config.DB.Find(&hosts)
+	result := query.Preload("Tags").Preload("Group").
+		Order(order).Order("id ASC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&hosts)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"data": hosts})
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"hosts":     hosts,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}})
 }
 
-// CreateHost 创建主机
+// 校验主机所属分组是否存在
+func validateHostGroup(groupID *uint) error {
+	if groupID == nil {
+		return nil
+	}
+	var count int64
+	config.DB.Model(&models.HostGroup{}).Where("id = ?", *groupID).Count(&count)
+	if count == 0 {
+		return errors.New("分组不存在")
+	}
+	return nil
+}
+
+// CreateHost 创建主机，tags 为标签名列表，不存在的标签自动创建
 func (h *HostController) CreateHost(c *gin.Context) {
-	var host models.Host
-	if err := c.ShouldBindJSON(&host); err != nil {
+	var req struct {
+		models.Host
+		Tags []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	host := req.Host
 
-	// 测试连接
-	if err := h.sshService.TestConnection(&host); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无法连接到主机: " + err.Error()})
+	host.ID = 0
+	host.Facts = models.HostFacts{}
+	host.Group = nil
+	if err := validateHostGroup(host.GroupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateJumpHost(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateProxyURL(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	host.Status = "online"
+	tags, err := services.ResolveTags(config.DB, req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	host.Tags = tags
+
+	// 不等待连接测试，主机可以在就绪前预先登记，检测结果稍后写入 status 和 check
+	host.Status = "unknown"
+	host.Check = models.HostCheck{}
 	result := config.DB.Create(&host)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 		return
 	}
 
+	go h.checkHost(host)
+
 	c.JSON(http.StatusCreated, gin.H{"data": host})
 }
 
@@ -65,6 +164,24 @@ func (h *HostController) GetHost(c *gin.Context) {
 		return
 	}
 
+	var host models.Host
+	result := config.DB.Preload("Tags").Preload("Group").First(&host, uint(id))
+	if result.Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": host})
+}
+
+// UpdateHost 更新主机连接信息
+func (h *HostController) UpdateHost(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的主机ID"})
+		return
+	}
+
 	var host models.Host
 	result := config.DB.First(&host, uint(id))
 	if result.Error != nil {
@@ -72,9 +189,111 @@ func (h *HostController) GetHost(c *gin.Context) {
 		return
 	}
 
+	var req struct {
+		Name         string    `json:"name" binding:"required"`
+		IPAddress    string    `json:"ip_address" binding:"required"`
+		Port         int       `json:"port"`
+		Username     string    `json:"username" binding:"required"`
+		Password     string    `json:"password"`
+		PrivateKey   string    `json:"private_key"`
+		JumpHostID   *uint     `json:"jump_host_id"`
+		ProxyURL     string    `json:"proxy_url"`
+		UseSudo      bool      `json:"use_sudo"`
+		SudoPassword string    `json:"sudo_password"`
+		GroupID      *uint     `json:"group_id"`
+		Tags         *[]string `json:"tags"` // 为空时保留原有标签
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 地址变化后原主机密钥、连接状态和清单信息不再适用
+	addressChanged := req.IPAddress != host.IPAddress || (req.Port != 0 && req.Port != host.Port)
+	if addressChanged {
+		host.HostKey = ""
+		host.Status = "unknown"
+	}
+
+	host.Name = req.Name
+	host.IPAddress = req.IPAddress
+	if req.Port != 0 {
+		host.Port = req.Port
+	}
+	host.Username = req.Username
+	// 未填写时保留原有凭据
+	if req.Password != "" {
+		host.Password = req.Password
+	}
+	if req.PrivateKey != "" {
+		host.PrivateKey = req.PrivateKey
+	}
+	host.JumpHostID = req.JumpHostID
+	host.ProxyURL = req.ProxyURL
+	host.UseSudo = req.UseSudo
+	if req.SudoPassword != "" {
+		host.SudoPassword = req.SudoPassword
+	}
+	host.GroupID = req.GroupID
+	if err := validateHostGroup(host.GroupID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.ValidateJumpHost(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := services.ValidateProxyURL(&host); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := config.DB.Save(&host).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Tags != nil {
+		tags, err := services.ResolveTags(config.DB, *req.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := config.DB.Model(&host).Association("Tags").Replace(tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	config.DB.Preload("Tags").Preload("Group").First(&host, host.ID)
+
+	if addressChanged {
+		go h.checkHost(host)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"data": host})
 }
 
+// ResetHostKey 清除记录的主机密钥，下次连接时重新记录
+func (h *HostController) ResetHostKey(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的主机ID"})
+		return
+	}
+
+	result := config.DB.Model(&models.Host{}).Where("id = ?", uint(id)).Update("host_key", "")
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "主机密钥已重置"})
+}
+
 // DeleteHost 删除主机
 func (h *HostController) DeleteHost(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -83,6 +302,13 @@ func (h *HostController) DeleteHost(c *gin.Context) {
 		return
 	}
 
+	var dependents int64
+	config.DB.Model(&models.Host{}).Where("jump_host_id = ?", uint(id)).Count(&dependents)
+	if dependents > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "该主机正被其他主机用作跳板机"})
+		return
+	}
+
 	result := config.DB.Delete(&models.Host{}, uint(id))
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
@@ -94,9 +320,47 @@ func (h *HostController) DeleteHost(c *gin.Context) {
 		return
 	}
 
+	config.DB.Where("host_id = ?", uint(id)).Delete(&models.MetricSample{})
+	config.DB.Exec("DELETE FROM host_tags WHERE host_id = ?", uint(id))
+
 	c.JSON(http.StatusOK, gin.H{"message": "主机删除成功"})
 }
 
+// 后台检测主机连接，成功后采集清单信息
+func (h *HostController) checkHost(host models.Host) {
+	if result := h.sshService.CheckHost(&host); result.Success {
+		h.sshService.RefreshHostFacts(&host)
+	}
+}
+
+// TestHost 诊断主机连接，分别给出DNS解析、跳板机、TCP连接、SSH握手、认证和执行命令的结果，
+// 并更新主机状态
+func (h *HostController) TestHost(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	result := h.sshService.CheckHost(host)
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// RefreshHostFacts 重新采集主机的清单信息
+func (h *HostController) RefreshHostFacts(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	facts, err := h.sshService.RefreshHostFacts(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "采集主机信息失败: " + err.Error(), "data": facts})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": facts})
+}
+
 // GetHostStats 获取主机统计信息
 func (h *HostController) GetHostStats(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -120,3 +384,141 @@ func (h *HostController) GetHostStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"data": stats})
 }
+
+// GetHostStatsHistory 获取主机指标历史
+// 参数: metric 逗号分隔的指标名；from/to 为RFC3339或unix秒，默认最近1小时；step 为聚合步长（秒），默认自动
+func (h *HostController) GetHostStatsHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的主机ID"})
+		return
+	}
+
+	var host models.Host
+	if err := config.DB.First(&host, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return
+	}
+
+	var metrics []string
+	for _, metric := range strings.Split(c.Query("metric"), ",") {
+		if metric = strings.TrimSpace(metric); metric != "" {
+			metrics = append(metrics, metric)
+		}
+	}
+	if len(metrics) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "缺少metric参数，可选: " + strings.Join(services.MetricNames(), ", ")})
+		return
+	}
+
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		if to, err = parseTimeParam(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的to参数"})
+			return
+		}
+	}
+	from := to.Add(-time.Hour)
+	if v := c.Query("from"); v != "" {
+		if from, err = parseTimeParam(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的from参数"})
+			return
+		}
+	}
+
+	step := 0
+	if v := c.Query("step"); v != "" {
+		if step, err = strconv.Atoi(v); err != nil || step <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的step参数"})
+			return
+		}
+	}
+
+	history, err := services.GetMetricsCollector().History(host.ID, metrics, from, to, step)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": history})
+}
+
+// 解析RFC3339或unix秒格式的时间
+func parseTimeParam(v string) (time.Time, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// 按查询参数筛选主机
+func filterHosts(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if v := strings.TrimSpace(c.Query("name")); v != "" {
+		query = query.Where("name LIKE ?", "%"+v+"%")
+	}
+	if v := strings.TrimSpace(c.Query("ip")); v != "" {
+		query = query.Where("ip_address LIKE ? OR facts_ip_addresses LIKE ?", "%"+v+"%", "%"+v+"%")
+	}
+	for _, tag := range c.QueryArray("tag") {
+		query = query.Where("id IN (?)", config.DB.Table("host_tags").
+			Select("host_tags.host_id").
+			Joins("JOIN tags ON tags.id = host_tags.tag_id").
+			Where("tags.name = ?", tag))
+	}
+	if v := c.Query("group_id"); v != "" {
+		groupID, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, errors.New("无效的分组ID")
+		}
+		if groupID == 0 {
+			query = query.Where("group_id IS NULL")
+		} else {
+			ids, err := services.GroupDescendantIDs(uint(groupID))
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where("group_id IN ?", ids)
+		}
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		query = query.Where("name LIKE ? OR ip_address LIKE ? OR facts_hostname LIKE ? OR facts_fqdn LIKE ? OR "+
+			"facts_ip_addresses LIKE ? OR facts_os_name LIKE ? OR facts_kernel LIKE ? OR facts_cpu_model LIKE ?",
+			like, like, like, like, like, like, like, like)
+	}
+
+	filters := map[string]string{
+		"status":          "status",
+		"os":              "facts_os",
+		"os_version":      "facts_os_version",
+		"arch":            "facts_arch",
+		"virtualization":  "facts_virtualization",
+		"package_manager": "facts_package_manager",
+	}
+	for param, column := range filters {
+		if v := c.Query(param); v != "" {
+			query = query.Where(column+" = ?", v)
+		}
+	}
+	if v := c.Query("kernel"); v != "" {
+		query = query.Where("facts_kernel LIKE ?", v+"%")
+	}
+	return query, nil
+}
+
+// 按路径参数 :id 查找主机，失败时已写入错误响应
+func findHost(c *gin.Context) (*models.Host, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的主机ID"})
+		return nil, false
+	}
+
+	var host models.Host
+	if err := config.DB.First(&host, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "主机不存在"})
+		return nil, false
+	}
+	return &host, true
+}
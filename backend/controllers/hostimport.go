@@ -0,0 +1,122 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 导入后并发检测连接的上限
+const importCheckConcurrency = 10
+
+// ImportHosts 批量导入主机
+// 支持 csv、yaml、json、ssh_config 格式；dry_run 时只校验并返回每行结果，
+// 否则仅在所有行都有效时在一个事务中写入
+func (h *HostController) ImportHosts(c *gin.Context) {
+	var req models.HostImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.sshService.ImportHosts(req)
+	user := currentUser(c)
+	if err != nil {
+		h.auditService.RecordAction(user.ID, 0, "host.import", req.Format, "", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.DryRun {
+		c.JSON(http.StatusOK, gin.H{"data": resp})
+		return
+	}
+
+	detail := fmt.Sprintf("total: %d, created: %d, updated: %d, skipped: %d, invalid: %d",
+		resp.Total, resp.Created, resp.Updated, resp.Skipped, resp.Invalid)
+	if !resp.Committed {
+		err := fmt.Errorf("%d 行校验失败，未导入任何主机", resp.Invalid)
+		h.auditService.RecordAction(user.ID, 0, "host.import", req.Format, detail, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "data": resp})
+		return
+	}
+	h.auditService.RecordAction(user.ID, 0, "host.import", req.Format, detail, nil)
+
+	var ids []uint
+	for _, row := range resp.Rows {
+		if row.Action == "create" || row.Action == "update" {
+			ids = append(ids, row.HostID)
+		}
+	}
+	go h.checkImportedHosts(ids)
+
+	c.JSON(http.StatusCreated, gin.H{"data": resp})
+}
+
+// 后台检测导入主机的连接并采集清单信息，限制并发避免同时建立过多连接
+func (h *HostController) checkImportedHosts(ids []uint) {
+	if len(ids) == 0 {
+		return
+	}
+	var hosts []models.Host
+	config.DB.Where("id IN ?", ids).Find(&hosts)
+
+	sem := make(chan struct{}, importCheckConcurrency)
+	var wg sync.WaitGroup
+	for i := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(host models.Host) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			h.checkHost(host)
+		}(hosts[i])
+	}
+	wg.Wait()
+}
+
+// ExportHosts 按 format 导出主机列表，筛选参数与主机列表一致
+// include_secrets=true 时包含密码和私钥，仅管理员可用并记录审计
+func (h *HostController) ExportHosts(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	includeSecrets, _ := strconv.ParseBool(c.Query("include_secrets"))
+	user := currentUser(c)
+	if includeSecrets && user.Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以导出凭据"})
+		return
+	}
+
+	query, err := filterHosts(c, config.DB.Model(&models.Host{}))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var hosts []models.Host
+	if err := query.Preload("Tags").Order("name").Find(&hosts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	rows := services.HostExportRows(hosts, includeSecrets)
+	data, contentType, ext, err := services.EncodeHostExport(rows, format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if includeSecrets {
+		h.auditService.RecordAction(user.ID, 0, "host.export", format, fmt.Sprintf("include_secrets, hosts: %d", len(hosts)), nil)
+	}
+
+	filename := fmt.Sprintf("hosts-%s.%s", time.Now().Format("20060102-150405"), ext)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,408 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type JobController struct {
+	scheduler *services.Scheduler
+}
+
+func NewJobController() *JobController {
+	return &JobController{
+		scheduler: services.GetScheduler(),
+	}
+}
+
+// 定时任务请求
+type jobRequest struct {
+	Name           string `json:"name" binding:"required"`
+	CronExpr       string `json:"cron_expr" binding:"required"`
+	Timezone       string `json:"timezone"`
+	JitterSeconds  int    `json:"jitter_seconds"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+	Command        string `json:"command"`
+	ScriptID       *uint  `json:"script_id"`
+	HostIDs        []uint `json:"host_ids" binding:"required"`
+	Enabled        *bool  `json:"enabled"`
+}
+
+// 校验请求并填充到任务
+func (j *JobController) applyJobRequest(req *jobRequest, job *models.ScheduledJob) string {
+	if strings.TrimSpace(req.Command) == "" && req.ScriptID == nil {
+		return "命令和脚本至少需要指定一个"
+	}
+	if req.ScriptID != nil {
+		var script models.Script
+		if err := config.DB.First(&script, *req.ScriptID).Error; err != nil {
+			return "脚本不存在"
+		}
+	}
+	if len(req.HostIDs) == 0 {
+		return "至少需要选择一台主机"
+	}
+	if req.JitterSeconds < 0 || req.TimeoutSeconds < 0 {
+		return "抖动和超时不能为负数"
+	}
+
+	job.Name = req.Name
+	job.CronExpr = req.CronExpr
+	job.Timezone = req.Timezone
+	if job.Timezone == "" {
+		job.Timezone = "Local"
+	}
+	job.JitterSeconds = req.JitterSeconds
+	job.TimeoutSeconds = req.TimeoutSeconds
+	if job.TimeoutSeconds == 0 {
+		job.TimeoutSeconds = 300
+	}
+	job.Command = req.Command
+	job.ScriptID = req.ScriptID
+	job.Enabled = true
+	if req.Enabled != nil {
+		job.Enabled = *req.Enabled
+	}
+
+	// 校验cron表达式和时区
+	if _, err := services.NextRunTime(job, time.Now()); err != nil {
+		return "无效的调度配置: " + err.Error()
+	}
+	return ""
+}
+
+func (j *JobController) loadHosts(ids []uint) ([]models.Host, bool) {
+	ids = uniqueIDs(ids)
+	var hosts []models.Host
+	config.DB.Where("id IN ?", ids).Find(&hosts)
+	return hosts, len(hosts) == len(ids)
+}
+
+// 去掉重复的ID并保持原有顺序，避免按数量校验 IN 查询结果时误判为不存在
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// 计算下次触发时间
+func (j *JobController) nextRunAt(job *models.ScheduledJob) *time.Time {
+	if !job.Enabled {
+		return nil
+	}
+	next, err := services.NextRunTime(job, time.Now())
+	if err != nil {
+		return nil
+	}
+	return &next
+}
+
+// 获取定时任务列表
+func (j *JobController) GetJobs(c *gin.Context) {
+	var jobs []models.ScheduledJob
+	result := config.DB.Preload("Hosts").Preload("Script").Order("id ASC").Find(&jobs)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取定时任务失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": jobs})
+}
+
+// 获取单个定时任务
+func (j *JobController) GetJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	var job models.ScheduledJob
+	if err := config.DB.Preload("Hosts").Preload("Script").First(&job, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// 创建定时任务
+func (j *JobController) CreateJob(c *gin.Context) {
+	var req jobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	var job models.ScheduledJob
+	if msg := j.applyJobRequest(&req, &job); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	hosts, ok := j.loadHosts(req.HostIDs)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "部分主机不存在"})
+		return
+	}
+	job.Hosts = hosts
+	job.NextRunAt = j.nextRunAt(&job)
+
+	if err := config.DB.Create(&job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建定时任务失败: " + err.Error()})
+		return
+	}
+	// GORM 不会写入值为 false 的带默认值字段
+	if !job.Enabled {
+		config.DB.Model(&job).Update("enabled", false)
+	}
+
+	j.scheduler.Reload()
+	c.JSON(http.StatusCreated, gin.H{"data": job})
+}
+
+// 更新定时任务
+func (j *JobController) UpdateJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	var job models.ScheduledJob
+	if err := config.DB.First(&job, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	var req jobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	if msg := j.applyJobRequest(&req, &job); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	hosts, ok := j.loadHosts(req.HostIDs)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "部分主机不存在"})
+		return
+	}
+	job.NextRunAt = j.nextRunAt(&job)
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("*").Omit("Hosts", "Script", "CreatedAt").Updates(&job).Error; err != nil {
+			return err
+		}
+		return tx.Model(&job).Association("Hosts").Replace(hosts)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新定时任务失败: " + err.Error()})
+		return
+	}
+	job.Hosts = hosts
+
+	j.scheduler.Reload()
+	c.JSON(http.StatusOK, gin.H{"data": job})
+}
+
+// 删除定时任务
+func (j *JobController) DeleteJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	result := config.DB.Delete(&models.ScheduledJob{}, uint(id))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	j.scheduler.Reload()
+	c.JSON(http.StatusOK, gin.H{"message": "任务删除成功"})
+}
+
+// 立即执行定时任务
+func (j *JobController) RunJob(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	var job models.ScheduledJob
+	if err := config.DB.First(&job, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "任务不存在"})
+		return
+	}
+
+	if err := j.scheduler.RunNow(job.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "任务已开始执行"})
+}
+
+// 获取定时任务执行历史
+func (j *JobController) GetJobRuns(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的任务ID"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
+	if page <= 0 {
+		page = 1
+	}
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+
+	query := config.DB.Model(&models.JobRun{}).Where("job_id = ?", uint(id))
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var runs []models.JobRun
+	result := query.Preload("Host").
+		Order("start_time DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&runs)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取执行记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"runs":      runs,
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	}})
+}
+
+// 获取脚本列表
+func (j *JobController) GetScripts(c *gin.Context) {
+	var scripts []models.Script
+	if err := config.DB.Order("id ASC").Find(&scripts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取脚本列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": scripts})
+}
+
+// 创建脚本
+func (j *JobController) CreateScript(c *gin.Context) {
+	var script models.Script
+	if err := c.ShouldBindJSON(&script); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if script.Name == "" || script.Content == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "脚本名称和内容不能为空"})
+		return
+	}
+
+	script.ID = 0
+	if err := config.DB.Create(&script).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建脚本失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": script})
+}
+
+// 更新脚本
+func (j *JobController) UpdateScript(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的脚本ID"})
+		return
+	}
+
+	var script models.Script
+	if err := config.DB.First(&script, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "脚本不存在"})
+		return
+	}
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Content     string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	script.Name = req.Name
+	script.Description = req.Description
+	script.Content = req.Content
+	if err := config.DB.Save(&script).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新脚本失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": script})
+}
+
+// 删除脚本
+func (j *JobController) DeleteScript(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的脚本ID"})
+		return
+	}
+
+	var count int64
+	config.DB.Model(&models.ScheduledJob{}).Where("script_id = ?", uint(id)).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "脚本正在被定时任务使用"})
+		return
+	}
+
+	result := config.DB.Delete(&models.Script{}, uint(id))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "脚本不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "脚本删除成功"})
+}
@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// 一条WebSocket消息最多合并的行数
+	logTailBatch = 500
+	// 客户端长时间不读取时断开
+	logTailWriteTimeout = 10 * time.Second
+)
+
+type LogTailController struct {
+	sshService   *services.SSHService
+	auditService *services.AuditService
+	authService  *services.AuthService
+}
+
+func NewLogTailController() *LogTailController {
+	return &LogTailController{
+		sshService:   services.NewSSHService(),
+		auditService: services.NewAuditService(),
+		authService:  services.NewAuthService(),
+	}
+}
+
+// 实时跟踪日志：/api/logs/tail?host_id=1&host_id=2&file=/var/log/syslog&unit=nginx&container=web&grep=error
+// 每个文件、单元和容器在每台主机上分别跟踪（tail -F / journalctl -f / docker logs -f），合并后以文本消息推送，
+// 多个日志源时每行带 [主机:文件] 前缀。参数 lines 为开始时输出的历史行数，invert=true 反向过滤，ignore_case=true 忽略大小写
+func (l *LogTailController) HandleTail(c *gin.Context) {
+	user, err := l.authService.ValidateToken(requestToken(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供有效的认证token"})
+		return
+	}
+
+	var hostIDs []uint
+	for _, v := range c.QueryArray("host_id") {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的主机ID"})
+			return
+		}
+		hostIDs = append(hostIDs, uint(id))
+	}
+	if len(hostIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "至少需要指定一台主机"})
+		return
+	}
+
+	hostIDs = uniqueIDs(hostIDs)
+	var hosts []models.Host
+	config.DB.Where("id IN ?", hostIDs).Order("id ASC").Find(&hosts)
+	if len(hosts) != len(hostIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "部分主机不存在"})
+		return
+	}
+
+	opts := services.LogTailOptions{
+		Files:      c.QueryArray("file"),
+		Units:      c.QueryArray("unit"),
+		Containers: c.QueryArray("container"),
+		Lines:      10,
+		Invert:     c.Query("invert") == "true",
+	}
+	if v := c.Query("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 1000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lines 必须在0到1000之间"})
+			return
+		}
+		opts.Lines = n
+	}
+	if pattern := c.Query("grep"); pattern != "" {
+		if c.Query("ignore_case") == "true" {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的过滤表达式: " + err.Error()})
+			return
+		}
+		opts.Grep = re
+	}
+	opts.Prefix = len(hosts)*(len(opts.Files)+len(opts.Units)+len(opts.Containers)) > 1
+	if v := c.Query("prefix"); v != "" {
+		opts.Prefix = v == "true"
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	tail, err := l.sshService.StartLogTail(hosts, opts)
+	var sources []string
+	sources = append(sources, opts.Files...)
+	sources = append(sources, opts.Units...)
+	for _, container := range opts.Containers {
+		sources = append(sources, "docker:"+container)
+	}
+	for _, host := range hosts {
+		l.auditService.RecordAction(user.ID, host.ID, "logs.tail", strings.Join(sources, ", "), "grep: "+c.Query("grep"), err)
+	}
+	if err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()))
+		return
+	}
+	// 连接断开时关闭远程跟踪进程
+	defer tail.Close()
+
+	// 客户端不发送数据，读取只用于处理控制帧和感知断开
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case line, ok := <-tail.Lines():
+			if !ok {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "日志跟踪已结束"))
+				return
+			}
+
+			// 合并缓冲中已有的行，减少消息数量
+			batch := []string{line}
+		drain:
+			for len(batch) < logTailBatch {
+				select {
+				case next, ok := <-tail.Lines():
+					if !ok {
+						break drain
+					}
+					batch = append(batch, next)
+				default:
+					break drain
+				}
+			}
+			if dropped := tail.TakeDropped(); dropped > 0 {
+				batch = append([]string{fmt.Sprintf("[host-manager] 客户端处理过慢，已丢弃 %d 行", dropped)}, batch...)
+			}
+
+			conn.SetWriteDeadline(time.Now().Add(logTailWriteTimeout))
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(strings.Join(batch, "\n")+"\n")); err != nil {
+				log.Printf("Log tail write error: %v", err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetricsController struct {
+	token  string
+	public bool
+}
+
+func NewMetricsController() *MetricsController {
+	public, _ := strconv.ParseBool(os.Getenv("METRICS_PUBLIC"))
+	return &MetricsController{
+		token:  os.Getenv("METRICS_TOKEN"),
+		public: public,
+	}
+}
+
+// Prometheus 抓取接口，需携带 Authorization: Bearer <METRICS_TOKEN>；
+// 未设置 METRICS_TOKEN 时拒绝抓取，除非显式设置 METRICS_PUBLIC=true 允许匿名访问
+func (m *MetricsController) Metrics(c *gin.Context) {
+	if m.token == "" && !m.public {
+		c.String(http.StatusForbidden, "metrics disabled: set METRICS_TOKEN, or METRICS_PUBLIC=true to allow anonymous scraping\n")
+		return
+	}
+	if m.token != "" {
+		token := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(token), []byte(m.token)) != 1 {
+			c.String(http.StatusUnauthorized, "unauthorized\n")
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := services.WritePrometheusMetrics(c.Writer); err != nil {
+		log.Printf("Failed to write metrics: %v", err)
+	}
+}
+
+// 记录API请求耗时，WebSocket等长连接不计入
+func (m *MetricsController) RequestMetrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		services.ObserveAPIRequest(c.Request.Method, route, c.Writer.Status(), time.Since(start))
+	}
+}
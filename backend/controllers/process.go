@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProcessController struct {
+	sshService   *services.SSHService
+	auditService *services.AuditService
+}
+
+func NewProcessController() *ProcessController {
+	return &ProcessController{
+		sshService:   services.NewSSHService(),
+		auditService: services.NewAuditService(),
+	}
+}
+
+// 获取进程列表
+// 参数: sort 排序字段（默认cpu）；order asc/desc（默认desc）；user 按用户名筛选；q 按命令行关键字筛选；state 按状态筛选；limit 最多返回条数
+func (p *ProcessController) GetProcesses(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	limit := 0
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的limit参数"})
+			return
+		}
+		limit = n
+	}
+
+	processes, err := p.sshService.ListProcesses(host)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取进程列表失败: " + err.Error()})
+		return
+	}
+
+	user := c.Query("user")
+	keyword := strings.ToLower(c.Query("q"))
+	state := c.Query("state")
+	filtered := make([]models.ProcessInfo, 0, len(processes))
+	for _, proc := range processes {
+		if user != "" && proc.User != user {
+			continue
+		}
+		if state != "" && !strings.HasPrefix(proc.State, state) {
+			continue
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(proc.Command), keyword) {
+			continue
+		}
+		filtered = append(filtered, proc)
+	}
+
+	if err := services.SortProcesses(filtered, c.DefaultQuery("sort", "cpu"), c.Query("order") != "asc"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	total := len(filtered)
+	if limit > 0 && limit < total {
+		filtered = filtered[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"processes": filtered,
+		"total":     total,
+	}})
+}
+
+// 向进程发送信号，普通用户只能操作SSH登录用户自己的进程，管理员不受限制
+func (p *ProcessController) SignalProcess(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	pid, err := strconv.Atoi(c.Param("pid"))
+	if err != nil || pid <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的进程ID"})
+		return
+	}
+
+	var req models.ProcessSignalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	signal, err := services.NormalizeSignal(req.Signal)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user := currentUser(c)
+	command, err := p.sshService.SignalProcess(host, pid, signal, user.Role != "admin")
+	p.auditService.RecordAction(user.ID, host.ID, "process.signal", fmt.Sprintf("pid %d", pid),
+		fmt.Sprintf("SIG%s %s", signal, command), err)
+
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrProcessNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrNotProcessOwner):
+			status = http.StatusForbidden
+		}
+		c.JSON(status, gin.H{"error": "发送信号失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": fmt.Sprintf("已向进程 %d 发送 SIG%s", pid, signal)})
+}
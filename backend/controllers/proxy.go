@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 反向代理会话Cookie名，只设置在代理域名上被代理服务的路径下
+const proxyTokenCookie = "hm_proxy_session"
+
+type ProxyController struct {
+	proxyService *services.HTTPProxyService
+}
+
+func NewProxyController() *ProxyController {
+	return &ProxyController{
+		proxyService: services.GetHTTPProxyService(),
+	}
+}
+
+type proxyLinkRequest struct {
+	Port  int  `json:"port" binding:"required"`
+	HTTPS bool `json:"https"`
+}
+
+// 生成打开主机上HTTP服务的一次性链接：POST /api/hosts/:id/proxy-link
+func (p *ProxyController) CreateLink(c *gin.Context) {
+	if services.ProxyDomain() == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "未配置 PROXY_DOMAIN，反向代理未启用"})
+		return
+	}
+
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	var req proxyLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	target, err := services.NewProxyTarget(host.ID, req.Port, req.HTTPS)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	link, expires, err := services.ProxyLink(c.GetHeader("Authorization"), target, scheme)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{"url": link, "expires_at": expires}})
+}
+
+// HostRouter 处理发往代理域名（PROXY_DOMAIN）的请求，其他请求交给后续路由。
+// 代理域名上只提供 /proxy/:hostId/:port/*path，不接受本系统的登录token，只接受签发给该主机端口的短期token
+func (p *ProxyController) HostRouter() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !services.IsProxyHost(c.Request.Host) {
+			c.Next()
+			return
+		}
+		c.Abort()
+
+		rest, ok := strings.CutPrefix(c.Request.URL.Path, "/proxy/")
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "页面不存在"})
+			return
+		}
+		hostID, rest, _ := strings.Cut(rest, "/")
+		port, path, hasPath := strings.Cut(rest, "/")
+		target, err := services.ParseProxyTarget(hostID, port)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if !hasPath {
+			c.Redirect(http.StatusMovedPermanently, target.Prefix+"/")
+			return
+		}
+		p.proxy(c, target, "/"+path)
+	}
+}
+
+// 反向代理到主机回环地址上的HTTP服务
+func (p *ProxyController) proxy(c *gin.Context, target *services.ProxyTarget, path string) {
+	// 浏览器通过一次性链接打开时，用链接token换取只对该目标路径有效的会话Cookie
+	if token := c.Query("proxy_token"); token != "" {
+		session, expires, err := services.ExchangeProxyLink(token, target)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		http.SetCookie(c.Writer, &http.Cookie{
+			Name:     proxyTokenCookie,
+			Value:    session,
+			Path:     target.Prefix + "/",
+			Expires:  expires,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+			Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		})
+
+		query := c.Request.URL.Query()
+		query.Del("proxy_token")
+		location := c.Request.URL.Path
+		if encoded := query.Encode(); encoded != "" {
+			location += "?" + encoded
+		}
+		c.Redirect(http.StatusFound, location)
+		return
+	}
+
+	cookie, err := c.Cookie(proxyTokenCookie)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "代理会话已失效，请从管理界面重新打开"})
+		return
+	}
+	user, err := services.VerifyProxySession(cookie, target)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 不把本系统的凭据转发给目标服务
+	stripCookie(c.Request, proxyTokenCookie)
+	p.proxyService.ServeHTTP(c.Writer, c.Request, user, target, path)
+}
+
+// 从请求的Cookie头中移除指定Cookie
+func stripCookie(r *http.Request, name string) {
+	cookies := r.Cookies()
+	r.Header.Del("Cookie")
+
+	var kept []string
+	for _, cookie := range cookies {
+		if cookie.Name != name {
+			kept = append(kept, cookie.Name+"="+cookie.Value)
+		}
+	}
+	if len(kept) > 0 {
+		r.Header.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
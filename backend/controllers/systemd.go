@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"host-manager/config"
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 批量操作时同时连接的主机数上限
+const systemdBulkConcurrency = 10
+
+type SystemdController struct {
+	sshService   *services.SSHService
+	auditService *services.AuditService
+}
+
+func NewSystemdController() *SystemdController {
+	return &SystemdController{
+		sshService:   services.NewSSHService(),
+		auditService: services.NewAuditService(),
+	}
+}
+
+// 获取单元列表
+// 参数: type 单元类型（默认service）；state 按 active 状态筛选；q 按名称或描述关键字筛选
+func (s *SystemdController) GetUnits(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	units, err := s.sshService.ListSystemdUnits(host, c.DefaultQuery("type", "service"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取单元列表失败: " + err.Error()})
+		return
+	}
+
+	state := c.Query("state")
+	keyword := strings.ToLower(c.Query("q"))
+	filtered := make([]models.SystemdUnit, 0, len(units))
+	for _, unit := range units {
+		if state != "" && unit.Active != state {
+			continue
+		}
+		if keyword != "" && !strings.Contains(strings.ToLower(unit.Name), keyword) &&
+			!strings.Contains(strings.ToLower(unit.Description), keyword) {
+			continue
+		}
+		filtered = append(filtered, unit)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": filtered})
+}
+
+// 执行操作并记录审计
+func (s *SystemdController) runAction(user *models.User, host *models.Host, unit, action string) models.SystemdActionResult {
+	state, output, err := s.sshService.SystemdAction(host, unit, action)
+	s.auditService.RecordAction(user.ID, host.ID, "systemd."+action, unit, "state: "+state, err)
+
+	result := models.SystemdActionResult{
+		HostID:   host.ID,
+		HostName: host.Name,
+		Success:  err == nil,
+		State:    state,
+		Output:   output,
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// 对单个主机的单元执行 start/stop/restart/reload/enable/disable
+func (s *SystemdController) UnitAction(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	unit, action := c.Param("unit"), c.Param("action")
+	if err := services.ValidateSystemdUnit(unit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.ValidSystemdAction(action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的操作: " + action})
+		return
+	}
+
+	result := s.runAction(currentUser(c), host, unit, action)
+	if !result.Success {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%s %s 失败: %s", action, unit, result.Error), "data": result})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
+
+// 在多台主机上并发执行同一操作，返回每台主机的结果
+func (s *SystemdController) BulkAction(c *gin.Context) {
+	var req models.SystemdBulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	if err := services.ValidateSystemdUnit(req.Unit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !services.ValidSystemdAction(req.Action) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不支持的操作: " + req.Action})
+		return
+	}
+	if len(req.HostIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "至少需要选择一台主机"})
+		return
+	}
+
+	req.HostIDs = uniqueIDs(req.HostIDs)
+	var hosts []models.Host
+	config.DB.Where("id IN ?", req.HostIDs).Order("id ASC").Find(&hosts)
+	if len(hosts) != len(req.HostIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "部分主机不存在"})
+		return
+	}
+
+	user := currentUser(c)
+	results := make([]models.SystemdActionResult, len(hosts))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, systemdBulkConcurrency)
+	for i := range hosts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = s.runAction(user, &hosts[i], req.Unit, req.Action)
+		}(i)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"results": results,
+		"total":   len(results),
+		"failed":  failed,
+	}})
+}
+
+// 获取单元的 journalctl 日志
+// 参数: lines 行数（默认200，最多5000）；since/until 时间范围；priority 日志级别
+func (s *SystemdController) UnitLogs(c *gin.Context) {
+	host, ok := findHost(c)
+	if !ok {
+		return
+	}
+
+	unit := c.Param("unit")
+	opts := services.JournalOptions{
+		Since:    c.Query("since"),
+		Until:    c.Query("until"),
+		Priority: c.Query("priority"),
+	}
+	if v := c.Query("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 5000 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "lines 必须在1到5000之间"})
+			return
+		}
+		opts.Lines = n
+	}
+
+	output, err := s.sshService.SystemdJournal(host, unit, opts)
+	user := currentUser(c)
+	s.auditService.RecordAction(user.ID, host.ID, "systemd.logs", unit, "", err)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取日志失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"unit": unit,
+		"logs": output,
+	}})
+}
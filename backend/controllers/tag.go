@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"host-manager/config"
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TagController struct{}
+
+func NewTagController() *TagController {
+	return &TagController{}
+}
+
+// 获取标签列表（带主机数）
+func (t *TagController) GetTags(c *gin.Context) {
+	var tags []models.Tag
+	if err := config.DB.Order("name ASC").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取标签列表失败"})
+		return
+	}
+
+	type tagCount struct {
+		TagID uint
+		Count int64
+	}
+	var counts []tagCount
+	config.DB.Table("host_tags").
+		Select("host_tags.tag_id, COUNT(*) AS count").
+		Joins("JOIN hosts ON hosts.id = host_tags.host_id AND hosts.deleted_at IS NULL").
+		Group("host_tags.tag_id").
+		Scan(&counts)
+	countByTag := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		countByTag[count.TagID] = count.Count
+	}
+	for i := range tags {
+		tags[i].HostCount = countByTag[tags[i].ID]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// 创建标签
+func (t *TagController) CreateTag(c *gin.Context) {
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	name, err := services.NormalizeTagName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	config.DB.Model(&models.Tag{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "标签已存在"})
+		return
+	}
+
+	tag := models.Tag{Name: name, Color: req.Color}
+	if err := config.DB.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建标签失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": tag})
+}
+
+// 更新标签（重命名或修改颜色）
+func (t *TagController) UpdateTag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的标签ID"})
+		return
+	}
+
+	var tag models.Tag
+	if err := config.DB.First(&tag, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+
+	var req models.TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+	name, err := services.NormalizeTagName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	config.DB.Model(&models.Tag{}).Where("name = ? AND id <> ?", name, tag.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "标签已存在"})
+		return
+	}
+
+	tag.Name = name
+	tag.Color = req.Color
+	if err := config.DB.Save(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新标签失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tag})
+}
+
+// 删除标签，同时从所有主机和告警规则上移除
+func (t *TagController) DeleteTag(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的标签ID"})
+		return
+	}
+
+	var tag models.Tag
+	if err := config.DB.First(&tag, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "标签不存在"})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM host_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM alert_rule_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除标签失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "标签删除成功"})
+}
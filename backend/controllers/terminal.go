@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"host-manager/config"
 	"host-manager/models"
@@ -15,6 +16,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
 )
 
 var upgrader = websocket.Upgrader{
@@ -26,15 +28,21 @@ var upgrader = websocket.Upgrader{
 type TerminalController struct {
 	sshService   *services.SSHService
 	auditService *services.AuditService
+	authService  *services.AuthService
+	live         *liveSessions
 }
 
 func NewTerminalController() *TerminalController {
 	return &TerminalController{
 		sshService:   services.NewSSHService(),
 		auditService: services.NewAuditService(),
+		authService:  services.NewAuthService(),
+		live:         newLiveSessions(),
 	}
 }
 
+// 打开主机终端；带 container 参数时改为在该容器中执行 docker exec，
+// 可选参数 user 为容器内用户，shell 为容器内shell路径（默认优先 bash）
 func (t *TerminalController) HandleTerminal(c *gin.Context) {
 	hostID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
@@ -43,11 +51,8 @@ func (t *TerminalController) HandleTerminal(c *gin.Context) {
 	}
 
 	// 检查token认证
-	token := c.Query("token")
-	if token == "" {
-		token = c.GetHeader("Authorization")
-	}
-	if token == "" || len(token) < 32 {
+	user, err := t.authService.ValidateToken(requestToken(c))
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供有效的认证token"})
 		return
 	}
@@ -59,6 +64,14 @@ func (t *TerminalController) HandleTerminal(c *gin.Context) {
 		return
 	}
 
+	container := c.Query("container")
+	if container != "" {
+		if err := services.ValidateDockerContainer(container); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade failed: %v", err)
@@ -69,28 +82,36 @@ func (t *TerminalController) HandleTerminal(c *gin.Context) {
 	// 生成会话ID
 	sessionID := uuid.New().String()
 
-	// 创建审计会话（假设用户ID为1，实际应该从认证中获取）
-	auditSession, err := t.auditService.CreateSession(1, uint(hostID), sessionID)
+	// 创建审计会话，操作记录由记录器异步批量写入
+	var recorder *services.SessionRecorder
+	auditSession, err := t.auditService.CreateSession(user.ID, uint(hostID), sessionID, container)
 	if err != nil {
 		log.Printf("Failed to create audit session: %v", err)
+	} else {
+		recorder = t.auditService.NewSessionRecorder(auditSession, fmt.Sprintf("%s@%s", user.Username, host.Name))
 	}
 
-	// 会话结束时关闭审计
+	// 会话结束时写入剩余记录并关闭审计
 	defer func() {
 		if auditSession != nil {
+			recorder.Close()
 			t.auditService.CloseSession(sessionID)
 		}
 	}()
 
 	// 创建SSH连接
-	sshClient, sshSession, err := t.sshService.CreateTerminalSession(&host)
+	var sshClient *ssh.Client
+	var sshSession *ssh.Session
+	if container != "" {
+		sshClient, sshSession, err = t.sshService.CreateDockerExecSession(&host)
+	} else {
+		sshClient, sshSession, err = t.sshService.CreateTerminalSession(&host)
+	}
 	if err != nil {
-		errorMsg := "SSH连接失败: " + err.Error()
+		errorMsg := err.Error()
 		conn.WriteMessage(websocket.TextMessage, []byte(errorMsg))
 		// 记录错误
-		if auditSession != nil {
-			t.auditService.RecordOperation(sessionID, "error", errorMsg)
-		}
+		recorder.Record("error", errorMsg)
 		return
 	}
 	defer sshClient.Close()
@@ -101,9 +122,7 @@ func (t *TerminalController) HandleTerminal(c *gin.Context) {
 	if err != nil {
 		errorMsg := "获取SSH输入流失败: " + err.Error()
 		conn.WriteMessage(websocket.TextMessage, []byte(errorMsg))
-		if auditSession != nil {
-			t.auditService.RecordOperation(sessionID, "error", errorMsg)
-		}
+		recorder.Record("error", errorMsg)
 		return
 	}
 
@@ -111,68 +130,81 @@ func (t *TerminalController) HandleTerminal(c *gin.Context) {
 	if err != nil {
 		errorMsg := "获取SSH输出流失败: " + err.Error()
 		conn.WriteMessage(websocket.TextMessage, []byte(errorMsg))
-		if auditSession != nil {
-			t.auditService.RecordOperation(sessionID, "error", errorMsg)
-		}
+		recorder.Record("error", errorMsg)
 		return
 	}
 
 	// 启动shell
-	if err := sshSession.Shell(); err != nil {
+	if container != "" {
+		err = t.sshService.StartDockerExec(sshSession, sshIn, &host, container, c.Query("user"), c.Query("shell"))
+		t.auditService.RecordAction(user.ID, host.ID, "docker.exec", container, "session: "+sessionID, err)
+	} else {
+		err = sshSession.Shell()
+	}
+	if err != nil {
 		errorMsg := "启动Shell失败: " + err.Error()
 		conn.WriteMessage(websocket.TextMessage, []byte(errorMsg))
-		if auditSession != nil {
-			t.auditService.RecordOperation(sessionID, "error", errorMsg)
-		}
+		recorder.Record("error", errorMsg)
 		return
 	}
 
+	// 统计活跃终端会话数
+	defer services.TerminalSessionStarted()()
+
 	// 记录会话开始
+	startInfo := fmt.Sprintf("Connected to host %s (%s)", host.Name, host.IPAddress)
+	if container != "" {
+		startInfo += fmt.Sprintf(", docker exec in container %s", container)
+	}
+	recorder.Record("session_start", startInfo)
+
+	// 登记为运行中的会话，SSH 输出经广播发给发起人和监看的管理员
+	broadcaster := &terminalBroadcaster{
+		sessionID: sessionID,
+		owner:     user,
+		host:      host,
+		container: container,
+		startTime: time.Now(),
+		stdin:     sshIn,
+		session:   sshSession,
+		recorder:  recorder,
+		viewers:   make(map[*terminalViewer]struct{}),
+		invites:   make(map[string]models.TerminalInvite),
+		cols:      80,
+		rows:      24,
+	}
 	if auditSession != nil {
-		t.auditService.RecordOperation(sessionID, "session_start", fmt.Sprintf("Connected to host %s (%s)", host.Name, host.IPAddress))
+		broadcaster.auditID = auditSession.ID
+		broadcaster.startTime = auditSession.StartTime
 	}
+	owner := newTerminalViewer(conn, user, "owner")
+	broadcaster.join(owner)
+	t.live.add(broadcaster)
+	defer func() {
+		t.live.remove(sessionID)
+		broadcaster.closeViewers()
+	}()
 
 	// 处理WebSocket到SSH的数据传输
 	go func() {
-		defer func() {
-			// 确保在goroutine结束时关闭会话
-			if auditSession != nil {
-				t.auditService.CloseSession(sessionID)
-			}
-		}()
-
+		defer broadcaster.leave(owner)
 		for {
 			_, message, err := conn.ReadMessage()
 			if err != nil {
 				log.Printf("WebSocket read error: %v", err)
+				// 浏览器断开后结束SSH会话，避免远端shell残留
+				sshSession.Close()
 				return
 			}
 
 			// 检查是否是调整大小的消息
-			var resizeMsg map[string]interface{}
-			if err := json.Unmarshal(message, &resizeMsg); err == nil {
-				if msgType, ok := resizeMsg["type"].(string); ok && msgType == "resize" {
-					if cols, ok := resizeMsg["cols"].(float64); ok {
-						if rows, ok := resizeMsg["rows"].(float64); ok {
-							// 调整SSH会话的终端大小
-							sshSession.WindowChange(int(rows), int(cols))
-							// 记录终端大小调整
-							if auditSession != nil {
-								resizeInfo := fmt.Sprintf("Terminal resized to %dx%d", int(cols), int(rows))
-								t.auditService.RecordOperation(sessionID, "resize", resizeInfo)
-							}
-							continue
-						}
-					}
-				}
+			if cols, rows, ok := parseResizeMessage(message); ok {
+				broadcaster.resize(owner, cols, rows)
+				continue
 			}
 
-			// 普通数据传输 - 记录用户输入
-			if auditSession != nil {
-				t.auditService.RecordOperation(sessionID, "input", string(message))
-			}
-
-			if _, err := sshIn.Write(message); err != nil {
+			// 普通数据传输，管理员接管期间忽略发起人的输入
+			if err := broadcaster.input(owner, message); err != nil {
 				log.Printf("SSH write error: %v", err)
 				return
 			}
@@ -181,13 +213,6 @@ func (t *TerminalController) HandleTerminal(c *gin.Context) {
 
 	// 处理SSH到WebSocket的数据传输
 	go func() {
-		defer func() {
-			// 确保在goroutine结束时关闭会话
-			if auditSession != nil {
-				t.auditService.CloseSession(sessionID)
-			}
-		}()
-
 		buffer := make([]byte, 1024)
 		for {
 			n, err := sshOut.Read(buffer)
@@ -198,17 +223,8 @@ func (t *TerminalController) HandleTerminal(c *gin.Context) {
 				return
 			}
 
-			output := buffer[:n]
-
-			// 记录输出
-			if auditSession != nil {
-				t.auditService.RecordOperation(sessionID, "output", string(output))
-			}
-
-			if err := conn.WriteMessage(websocket.TextMessage, output); err != nil {
-				log.Printf("WebSocket write error: %v", err)
-				return
-			}
+			// 记录输出（连续的输出由记录器合并）并发送给所有连接
+			broadcaster.output(append([]byte(nil), buffer[:n]...))
 		}
 	}()
 
@@ -216,7 +232,308 @@ func (t *TerminalController) HandleTerminal(c *gin.Context) {
 	sshSession.Wait()
 
 	// 记录会话结束
-	if auditSession != nil {
-		t.auditService.RecordOperation(sessionID, "session_end", "Session terminated")
+	recorder.Record("session_end", "Session terminated")
+}
+
+// 解析 {"type":"resize","cols":..,"rows":..} 消息
+func parseResizeMessage(message []byte) (int, int, bool) {
+	var resizeMsg map[string]interface{}
+	if err := json.Unmarshal(message, &resizeMsg); err != nil {
+		return 0, 0, false
+	}
+	if msgType, ok := resizeMsg["type"].(string); !ok || msgType != "resize" {
+		return 0, 0, false
+	}
+	cols, ok := resizeMsg["cols"].(float64)
+	if !ok {
+		return 0, 0, false
+	}
+	rows, ok := resizeMsg["rows"].(float64)
+	if !ok {
+		return 0, 0, false
+	}
+	return int(cols), int(rows), true
+}
+
+// 列出运行中的终端会话及其参与者（仅管理员）
+func (t *TerminalController) GetLiveSessions(c *gin.Context) {
+	if currentUser(c).Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以查看运行中的会话"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": t.live.list()})
+}
+
+// 管理员实时监看终端会话（只读）：/api/audit/live/:session_id/watch
+// 连接后先收到最近的输出，之后与发起人同步收到所有输出；
+// 发送 {"type":"takeover"} 接管输入，此时发送的数据和 resize 消息写入会话，发起人的输入被忽略，
+// 发送 {"type":"release"} 或断开连接后交还输入
+func (t *TerminalController) WatchLiveSession(c *gin.Context) {
+	user, err := t.authService.ValidateToken(requestToken(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供有效的认证token"})
+		return
+	}
+	if user.Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以监看会话"})
+		return
+	}
+
+	broadcaster := t.live.get(c.Param("session_id"))
+	if broadcaster == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在或已结束"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	t.auditService.RecordAction(user.ID, broadcaster.host.ID, "terminal.watch", broadcaster.sessionID,
+		"user: "+broadcaster.owner.Username, nil)
+
+	viewer := newTerminalViewer(conn, user, "monitor")
+	defer viewer.close()
+	broadcaster.join(viewer)
+	defer broadcaster.leave(viewer)
+
+	t.readViewer(broadcaster, viewer)
+}
+
+// 读取非发起人连接的消息：管理员可发送 takeover、release 控制消息，其余数据按权限写入会话
+func (t *TerminalController) readViewer(broadcaster *terminalBroadcaster, viewer *terminalViewer) {
+	user, conn := viewer.user, viewer.conn
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var control struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(message, &control) == nil {
+			switch control.Type {
+			case "takeover":
+				if viewer.role != "monitor" {
+					continue
+				}
+				broadcaster.takeover(viewer)
+				t.auditService.RecordAction(user.ID, broadcaster.host.ID, "terminal.takeover", broadcaster.sessionID,
+					"user: "+broadcaster.owner.Username, nil)
+				continue
+			case "release":
+				broadcaster.release(viewer)
+				continue
+			case "resize":
+				if cols, rows, ok := parseResizeMessage(message); ok {
+					broadcaster.resize(viewer, cols, rows)
+				}
+				continue
+			}
+		}
+
+		if err := broadcaster.input(viewer, message); err != nil {
+			log.Printf("SSH write error: %v", err)
+			return
+		}
+	}
+}
+
+// 管理员强制结束终端会话，请求体 {"message": "..."} 会显示给会话中的用户
+func (t *TerminalController) TerminateLiveSession(c *gin.Context) {
+	user := currentUser(c)
+	if user.Role != "admin" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有管理员可以结束会话"})
+		return
 	}
+
+	var req struct {
+		Message string `json:"message"`
+	}
+	c.ShouldBindJSON(&req)
+
+	broadcaster := t.live.get(c.Param("session_id"))
+	if broadcaster == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在或已结束"})
+		return
+	}
+
+	broadcaster.terminate(user, req.Message)
+	t.auditService.RecordAction(user.ID, broadcaster.host.ID, "terminal.terminate", broadcaster.sessionID,
+		"user: "+broadcaster.owner.Username+", message: "+req.Message, nil)
+
+	c.JSON(http.StatusOK, gin.H{"message": "会话已结束"})
+}
+
+// 当前用户发起或参与的运行中会话，发起人可据此取得会话ID以创建邀请
+func (t *TerminalController) GetMySessions(c *gin.Context) {
+	user := currentUser(c)
+	sessions := []models.LiveSession{}
+	for _, live := range t.live.list() {
+		for _, p := range live.Participants {
+			if p.UserID == user.ID && p.Role != "monitor" {
+				if p.Role != "owner" {
+					live.Invites = nil
+				}
+				sessions = append(sessions, live)
+				break
+			}
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+// 获取运行中会话的参与者，会话的发起人、参与者和管理员可以查看
+func (t *TerminalController) GetLiveSession(c *gin.Context) {
+	user := currentUser(c)
+	broadcaster := t.live.get(c.Param("session_id"))
+	if broadcaster == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在或已结束"})
+		return
+	}
+
+	isOwner := broadcaster.owner.ID == user.ID
+	live := broadcaster.info(isOwner || user.Role == "admin")
+	if !isOwner && user.Role != "admin" {
+		joined := false
+		for _, p := range live.Participants {
+			if p.UserID == user.ID {
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			c.JSON(http.StatusForbidden, gin.H{"error": "没有权限查看该会话"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": live})
+}
+
+// 会话发起人创建邀请链接：{"access":"ro|rw","user_id":0,"expires_in":3600}
+// user_id 限定可加入的用户（默认任意已登录用户），expires_in 为有效秒数（默认1小时，最长24小时）
+func (t *TerminalController) CreateInvite(c *gin.Context) {
+	user := currentUser(c)
+
+	var req struct {
+		Access    string `json:"access"`
+		UserID    uint   `json:"user_id"`
+		ExpiresIn int    `json:"expires_in"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Access == "" {
+		req.Access = "ro"
+	}
+	if req.Access != "ro" && req.Access != "rw" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "access 只能为 ro 或 rw"})
+		return
+	}
+	if req.ExpiresIn <= 0 {
+		req.ExpiresIn = 3600
+	}
+	if req.ExpiresIn > 24*3600 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "邀请有效期最长为24小时"})
+		return
+	}
+	if req.UserID != 0 {
+		var count int64
+		config.DB.Model(&models.User{}).Where("id = ?", req.UserID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "邀请的用户不存在"})
+			return
+		}
+	}
+
+	broadcaster := t.live.get(c.Param("session_id"))
+	if broadcaster == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在或已结束"})
+		return
+	}
+	if broadcaster.owner.ID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有会话发起人可以邀请"})
+		return
+	}
+
+	token, err := t.authService.GenerateToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成邀请失败"})
+		return
+	}
+	invite := broadcaster.createInvite(token, req.Access, req.UserID, time.Duration(req.ExpiresIn)*time.Second)
+
+	detail := "access: " + req.Access
+	if req.UserID != 0 {
+		detail += fmt.Sprintf(", user_id: %d", req.UserID)
+	}
+	t.auditService.RecordAction(user.ID, broadcaster.host.ID, "terminal.invite", broadcaster.sessionID, detail, nil)
+
+	c.JSON(http.StatusCreated, gin.H{"data": invite})
+}
+
+// 会话发起人撤销邀请，已加入的参与者不受影响
+func (t *TerminalController) RevokeInvite(c *gin.Context) {
+	user := currentUser(c)
+	broadcaster := t.live.get(c.Param("session_id"))
+	if broadcaster == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "会话不存在或已结束"})
+		return
+	}
+	if broadcaster.owner.ID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "只有会话发起人可以撤销邀请"})
+		return
+	}
+	if !broadcaster.revokeInvite(c.Param("token")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "邀请不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "邀请已撤销"})
+}
+
+// 通过邀请加入共享会话：/api/terminal/join/:token?token=<登录token>
+// 与发起人看到相同的输出，rw 邀请加入的参与者可以输入（输入按用户分别记录审计），终端大小由发起人决定
+func (t *TerminalController) JoinSession(c *gin.Context) {
+	user, err := t.authService.ValidateToken(requestToken(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供有效的认证token"})
+		return
+	}
+
+	broadcaster, invite, ok := t.live.findInvite(c.Param("invite"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "邀请不存在或已过期"})
+		return
+	}
+	if invite.UserID != 0 && invite.UserID != user.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "该邀请不是发给你的"})
+		return
+	}
+	if broadcaster.owner.ID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "你是该会话的发起人"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	t.auditService.RecordAction(user.ID, broadcaster.host.ID, "terminal.join", broadcaster.sessionID,
+		"owner: "+broadcaster.owner.Username+", access: "+invite.Access, nil)
+
+	viewer := newTerminalViewer(conn, user, "participant")
+	viewer.access = invite.Access
+	defer viewer.close()
+	broadcaster.join(viewer)
+	defer broadcaster.leave(viewer)
+
+	t.readViewer(broadcaster, viewer)
 }
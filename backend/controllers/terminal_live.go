@@ -0,0 +1,457 @@
+package controllers
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	liveBacklogBytes    = 64 * 1024 // 保留的最近输出，新加入的监看者先收到这部分内容
+	liveViewerQueueSize = 256       // 每个连接待发送的消息数，监看者跟不上时断开
+)
+
+// 连接到终端会话的一个 WebSocket
+type terminalViewer struct {
+	conn     *websocket.Conn
+	user     *models.User
+	role     string // owner、participant、monitor
+	access   string // participant 的权限：ro、rw
+	joinedAt time.Time
+
+	send      chan []byte
+	flush     chan struct{} // 关闭后发送完已排队的消息再断开
+	done      chan struct{}
+	flushOnce sync.Once
+	closeOnce sync.Once
+}
+
+func newTerminalViewer(conn *websocket.Conn, user *models.User, role string) *terminalViewer {
+	v := &terminalViewer{
+		conn:     conn,
+		user:     user,
+		role:     role,
+		joinedAt: time.Now(),
+		send:     make(chan []byte, liveViewerQueueSize),
+		flush:    make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go v.writeLoop()
+	return v
+}
+
+// 同一连接的写入只在该协程中进行
+func (v *terminalViewer) writeLoop() {
+	for {
+		select {
+		case data := <-v.send:
+			if err := v.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				v.close()
+				return
+			}
+		case <-v.flush:
+			v.drain()
+			v.close()
+			return
+		case <-v.done:
+			return
+		}
+	}
+}
+
+// 发送队列中剩余的消息
+func (v *terminalViewer) drain() {
+	for {
+		select {
+		case data := <-v.send:
+			if err := v.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+// 会话发起人的输出阻塞等待发送，保持原有的流量控制；其他连接队列满时断开，不拖慢会话
+func (v *terminalViewer) write(data []byte) {
+	if v.role == "owner" {
+		select {
+		case v.send <- data:
+		case <-v.done:
+		}
+		return
+	}
+	select {
+	case v.send <- data:
+	case <-v.done:
+	default:
+		log.Printf("Terminal %s %s is too slow, disconnecting", v.role, v.user.Username)
+		v.close()
+	}
+}
+
+// 等待已排队的消息发送完（最多 timeout）后断开
+func (v *terminalViewer) flushAndClose(timeout time.Duration) {
+	v.flushOnce.Do(func() { close(v.flush) })
+	select {
+	case <-v.done:
+	case <-time.After(timeout):
+		v.close()
+	}
+}
+
+func (v *terminalViewer) close() {
+	v.closeOnce.Do(func() {
+		close(v.done)
+		v.conn.Close()
+	})
+}
+
+// terminalBroadcaster 一个运行中的终端会话：SSH 输出广播给发起人、受邀的参与者和监看的管理员，
+// 输入接受发起人和可输入的参与者，管理员接管后只接受该管理员；终端大小由发起人（或接管的管理员）决定
+type terminalBroadcaster struct {
+	sessionID string
+	auditID   uint
+	owner     *models.User
+	host      models.Host
+	container string
+	startTime time.Time
+	stdin     io.Writer
+	session   *ssh.Session
+	recorder  *services.SessionRecorder
+
+	mu         sync.Mutex
+	viewers    map[*terminalViewer]struct{}
+	controller *terminalViewer // 接管输入的管理员，为 nil 时由发起人和可输入的参与者输入
+	backlog    []byte
+	cols, rows int
+	invites    map[string]models.TerminalInvite
+}
+
+// 广播 SSH 输出并写入审计
+func (b *terminalBroadcaster) output(data []byte) {
+	b.recorder.Record("output", string(data))
+
+	b.mu.Lock()
+	b.backlog = append(b.backlog, data...)
+	if len(b.backlog) > liveBacklogBytes {
+		// 从换行处截断，尽量不从控制序列中间开始
+		cut := len(b.backlog) - liveBacklogBytes
+		if i := bytes.IndexByte(b.backlog[cut:], '\n'); i >= 0 {
+			cut += i + 1
+		}
+		b.backlog = append([]byte(nil), b.backlog[cut:]...)
+	}
+	var owners []*terminalViewer
+	for v := range b.viewers {
+		if v.role == "owner" {
+			owners = append(owners, v)
+		} else {
+			v.write(data)
+		}
+	}
+	b.mu.Unlock()
+
+	for _, v := range owners {
+		v.write(data)
+	}
+}
+
+// 向所有连接显示一条提示，不进入录像
+func (b *terminalBroadcaster) notice(text string) {
+	b.recorder.Record("notice", text)
+	data := []byte("\r\n\x1b[1;33m[" + text + "]\x1b[0m\r\n")
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for v := range b.viewers {
+		select {
+		case v.send <- data:
+		default:
+		}
+	}
+}
+
+// 加入会话，后加入的连接先收到最近的输出；参与者加入时通知所有人
+func (b *terminalBroadcaster) join(v *terminalViewer) {
+	b.mu.Lock()
+	if v.role != "owner" && len(b.backlog) > 0 {
+		v.send <- append([]byte(nil), b.backlog...)
+	}
+	b.viewers[v] = struct{}{}
+	b.mu.Unlock()
+
+	if v.role == "participant" {
+		b.notice(v.user.Username + " 加入了会话（" + accessLabel(v.access) + "）")
+	}
+}
+
+// 离开会话，接管输入的管理员离开时交还输入
+func (b *terminalBroadcaster) leave(v *terminalViewer) {
+	b.mu.Lock()
+	delete(b.viewers, v)
+	released := b.controller == v
+	if released {
+		b.controller = nil
+	}
+	b.mu.Unlock()
+
+	if released {
+		b.notice("管理员 " + v.user.Username + " 已断开，输入交还给 " + b.owner.Username)
+	}
+	if v.role == "participant" {
+		b.notice(v.user.Username + " 离开了会话")
+	}
+}
+
+func accessLabel(access string) string {
+	if access == "rw" {
+		return "可输入"
+	}
+	return "只读"
+}
+
+// 是否允许该连接输入
+func (b *terminalBroadcaster) canInput(v *terminalViewer) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.controller != nil {
+		return b.controller == v
+	}
+	return v.role == "owner" || (v.role == "participant" && v.access == "rw")
+}
+
+// 写入用户输入，没有输入权限时忽略；输入按连接的用户分别记录审计
+func (b *terminalBroadcaster) input(v *terminalViewer, data []byte) error {
+	if !b.canInput(v) {
+		return nil
+	}
+	b.recorder.RecordInput(v.user.ID, string(data))
+	_, err := b.stdin.Write(data)
+	return err
+}
+
+// 调整终端大小，只接受发起人或接管输入的管理员
+func (b *terminalBroadcaster) resize(v *terminalViewer, cols, rows int) {
+	b.mu.Lock()
+	allowed := b.controller == v || (b.controller == nil && v.role == "owner")
+	b.mu.Unlock()
+	if !allowed {
+		return
+	}
+	b.mu.Lock()
+	b.cols, b.rows = cols, rows
+	b.mu.Unlock()
+	b.session.WindowChange(rows, cols)
+	b.recorder.RecordResize(cols, rows)
+}
+
+// 管理员接管输入
+func (b *terminalBroadcaster) takeover(v *terminalViewer) {
+	b.mu.Lock()
+	changed := b.controller != v
+	b.controller = v
+	b.mu.Unlock()
+
+	if changed {
+		b.notice("管理员 " + v.user.Username + " 已接管输入")
+	}
+}
+
+// 管理员交还输入
+func (b *terminalBroadcaster) release(v *terminalViewer) {
+	b.mu.Lock()
+	released := b.controller == v
+	if released {
+		b.controller = nil
+	}
+	b.mu.Unlock()
+
+	if released {
+		b.notice("管理员 " + v.user.Username + " 已交还输入")
+	}
+}
+
+// 强制结束会话，message 显示给所有连接
+func (b *terminalBroadcaster) terminate(admin *models.User, message string) {
+	text := "会话已被管理员 " + admin.Username + " 终止"
+	if message != "" {
+		text += "：" + message
+	}
+	b.notice(text)
+	b.session.Close()
+}
+
+// 会话结束，发送完剩余的输出后断开所有连接
+func (b *terminalBroadcaster) closeViewers() {
+	b.mu.Lock()
+	viewers := make([]*terminalViewer, 0, len(b.viewers))
+	for v := range b.viewers {
+		viewers = append(viewers, v)
+	}
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, v := range viewers {
+		wg.Add(1)
+		go func(v *terminalViewer) {
+			defer wg.Done()
+			v.flushAndClose(time.Second)
+		}(v)
+	}
+	wg.Wait()
+}
+
+// 创建邀请，ttl 后失效
+func (b *terminalBroadcaster) createInvite(token, access string, userID uint, ttl time.Duration) models.TerminalInvite {
+	now := time.Now()
+	invite := models.TerminalInvite{
+		Token:     token,
+		URL:       "/api/terminal/join/" + token,
+		Access:    access,
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.invites[token] = invite
+	return invite
+}
+
+// 撤销邀请，已加入的参与者不受影响
+func (b *terminalBroadcaster) revokeInvite(token string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	_, ok := b.invites[token]
+	delete(b.invites, token)
+	return ok
+}
+
+// 查找未过期的邀请
+func (b *terminalBroadcaster) invite(token string) (models.TerminalInvite, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	invite, ok := b.invites[token]
+	if ok && time.Now().After(invite.ExpiresAt) {
+		delete(b.invites, token)
+		return invite, false
+	}
+	return invite, ok
+}
+
+// 会话信息，withInvites 为 true 时包含未过期的邀请
+func (b *terminalBroadcaster) info(withInvites bool) models.LiveSession {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	live := models.LiveSession{
+		SessionID:      b.sessionID,
+		AuditSessionID: b.auditID,
+		UserID:         b.owner.ID,
+		Username:       b.owner.Username,
+		HostID:         b.host.ID,
+		HostName:       b.host.Name,
+		Container:      b.container,
+		StartTime:      b.startTime,
+		Cols:           b.cols,
+		Rows:           b.rows,
+		Participants:   []models.LiveParticipant{},
+	}
+	for v := range b.viewers {
+		live.Participants = append(live.Participants, models.LiveParticipant{
+			UserID:      v.user.ID,
+			Username:    v.user.Username,
+			Role:        v.role,
+			Access:      v.access,
+			Controlling: b.controller == v,
+			JoinedAt:    v.joinedAt,
+		})
+	}
+	sort.Slice(live.Participants, func(i, j int) bool {
+		return live.Participants[i].JoinedAt.Before(live.Participants[j].JoinedAt)
+	})
+
+	if withInvites {
+		now := time.Now()
+		for _, invite := range b.invites {
+			if now.Before(invite.ExpiresAt) {
+				live.Invites = append(live.Invites, invite)
+			}
+		}
+		sort.Slice(live.Invites, func(i, j int) bool {
+			return live.Invites[i].CreatedAt.Before(live.Invites[j].CreatedAt)
+		})
+	}
+	return live
+}
+
+// 运行中的终端会话，按会话ID索引
+type liveSessions struct {
+	mu       sync.RWMutex
+	sessions map[string]*terminalBroadcaster
+}
+
+func newLiveSessions() *liveSessions {
+	return &liveSessions{sessions: make(map[string]*terminalBroadcaster)}
+}
+
+func (l *liveSessions) add(b *terminalBroadcaster) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sessions[b.sessionID] = b
+}
+
+func (l *liveSessions) remove(sessionID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, sessionID)
+}
+
+func (l *liveSessions) get(sessionID string) *terminalBroadcaster {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.sessions[sessionID]
+}
+
+func (l *liveSessions) list() []models.LiveSession {
+	l.mu.RLock()
+	sessions := make([]*terminalBroadcaster, 0, len(l.sessions))
+	for _, b := range l.sessions {
+		sessions = append(sessions, b)
+	}
+	l.mu.RUnlock()
+
+	result := make([]models.LiveSession, 0, len(sessions))
+	for _, b := range sessions {
+		result = append(result, b.info(true))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].StartTime.After(result[j].StartTime)
+	})
+	return result
+}
+
+// 按邀请token查找会话
+func (l *liveSessions) findInvite(token string) (*terminalBroadcaster, models.TerminalInvite, bool) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, b := range l.sessions {
+		if invite, ok := b.invite(token); ok {
+			return b, invite, true
+		}
+	}
+	return nil, models.TerminalInvite{}, false
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"host-manager/models"
+	"host-manager/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+type TunnelController struct {
+	tunnelService *services.TunnelService
+	authService   *services.AuthService
+}
+
+func NewTunnelController() *TunnelController {
+	return &TunnelController{
+		tunnelService: services.GetTunnelService(),
+		authService:   services.NewAuthService(),
+	}
+}
+
+// 创建端口转发隧道
+func (t *TunnelController) CreateTunnel(c *gin.Context) {
+	var req models.TunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误"})
+		return
+	}
+
+	tunnel, err := t.tunnelService.Open(currentUser(c), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "创建隧道失败: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": tunnel})
+}
+
+// 获取隧道列表
+func (t *TunnelController) GetTunnels(c *gin.Context) {
+	tunnels, err := t.tunnelService.List(currentUser(c), c.Query("all") == "true", c.Query("status") == "active")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取隧道列表失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tunnels})
+}
+
+// 关闭隧道
+func (t *TunnelController) CloseTunnel(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的隧道ID"})
+		return
+	}
+
+	if err := t.tunnelService.Close(uint(id), currentUser(c)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "隧道已关闭"})
+}
+
+// WebSocket模式隧道：每个WebSocket连接对应一条到目标的TCP连接，使用二进制消息传输
+func (t *TunnelController) HandleTunnelStream(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的隧道ID"})
+		return
+	}
+
+	user, err := t.authService.ValidateToken(requestToken(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未提供有效的认证token"})
+		return
+	}
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	stream := &wsStream{conn: conn}
+	if err := t.tunnelService.ServeStream(uint(id), user, stream); err != nil {
+		conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()))
+		conn.Close()
+	}
+}
+
+// 将WebSocket连接适配为字节流
+type wsStream struct {
+	conn    *websocket.Conn
+	reader  io.Reader
+	writeMu sync.Mutex
+}
+
+func (w *wsStream) Read(p []byte) (int, error) {
+	for {
+		if w.reader == nil {
+			_, reader, err := w.conn.NextReader()
+			if err != nil {
+				return 0, io.EOF
+			}
+			w.reader = reader
+		}
+
+		n, err := w.reader.Read(p)
+		if err == io.EOF {
+			w.reader = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (w *wsStream) Write(p []byte) (int, error) {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	if err := w.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *wsStream) Close() error {
+	return w.conn.Close()
+}
@@ -14,24 +14,53 @@ func main() {
 	config.InitDatabase()
 
 	// 自动迁移数据库表
-	err := config.DB.AutoMigrate(&models.Host{}, &models.User{}, &models.TerminalSession{}, &models.TerminalOperation{})
+	err := config.DB.AutoMigrate(
+		&models.Host{}, &models.User{}, &models.TerminalSession{}, &models.TerminalOperation{}, &models.TerminalCommand{},
+		&models.Script{}, &models.ScheduledJob{}, &models.JobRun{},
+		&models.UserToken{}, &models.AuditLog{}, &models.PortForward{}, &models.MetricSample{},
+		&models.AlertRule{}, &models.NotificationChannel{}, &models.Alert{}, &models.AlertSilence{},
+		&models.Tag{}, &models.HostGroup{},
+	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// 为终端操作内容建立全文索引
+	services.InitOperationSearch()
+
 	// 创建默认管理员账户（仅在没有用户时）
 	var userCount int64
 	config.DB.Model(&models.User{}).Count(&userCount)
 	if userCount == 0 {
 		authService := services.NewAuthService()
-		_, err := authService.CreateUser("admin", "admin123", "admin@example.com")
+		admin, err := authService.CreateUser("admin", "admin123", "admin@example.com")
 		if err != nil {
 			log.Printf("Failed to create default admin user: %v", err)
 		} else {
+			config.DB.Model(admin).Update("role", "admin")
 			log.Println("Created default admin user: admin/admin123")
 		}
 	}
 
+	// 早期版本创建的默认管理员角色为 user，没有管理员时只修正当时作为第一个用户创建的默认账户，
+	// 不按用户名匹配，避免之后注册为 admin 的普通用户被提升为管理员
+	var adminCount int64
+	config.DB.Model(&models.User{}).Where("role = ?", "admin").Count(&adminCount)
+	if adminCount == 0 {
+		config.DB.Model(&models.User{}).
+			Where("id = ? AND username = ? AND email = ?", 1, "admin", "admin@example.com").
+			Update("role", "admin")
+	}
+
+	// 启动定时任务调度器
+	services.GetScheduler().Start()
+
+	// 清理上次运行遗留的端口转发记录
+	services.GetTunnelService().CloseStale()
+
+	// 启动主机指标采集
+	services.GetMetricsCollector().Start()
+
 	// 设置路由
 	r := routes.SetupRoutes()
 
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 告警规则，如 disk_usage > 90 持续 300 秒
+type AlertRule struct {
+	ID              uint                  `json:"id" gorm:"primaryKey"`
+	Name            string                `json:"name" gorm:"not null"`
+	Description     string                `json:"description"`
+	Metric          string                `json:"metric" gorm:"not null"`   // 指标名，host_up 为主机在线状态（1在线，0离线）
+	Operator        string                `json:"operator" gorm:"not null"` // >、>=、<、<=、==、!=
+	Threshold       float64               `json:"threshold"`
+	DurationSeconds int                   `json:"duration_seconds"`                        // 持续满足条件多久后触发，0为立即触发
+	RepeatSeconds   int                   `json:"repeat_seconds"`                          // 持续触发时重复通知的间隔，0为不重复
+	Severity        string                `json:"severity" gorm:"default:warning"`         // warning、critical
+	Hosts           []Host                `json:"hosts" gorm:"many2many:alert_rule_hosts"` // 与 Tags 均为空表示所有主机
+	Tags            []Tag                 `json:"tags" gorm:"many2many:alert_rule_tags"`   // 带有任一标签的主机
+	Channels        []NotificationChannel `json:"channels" gorm:"many2many:alert_rule_channels"`
+	Enabled         bool                  `json:"enabled" gorm:"default:true"`
+	CreatedAt       time.Time             `json:"created_at"`
+	UpdatedAt       time.Time             `json:"updated_at"`
+	DeletedAt       gorm.DeletedAt        `json:"-" gorm:"index"`
+}
+
+// 通知渠道
+type NotificationChannel struct {
+	ID   uint   `json:"id" gorm:"primaryKey"`
+	Name string `json:"name" gorm:"not null"`
+	Type string `json:"type" gorm:"not null"` // webhook、email、chat
+	// webhook 和 chat 使用
+	URL        string `json:"url"`
+	ChatFormat string `json:"chat_format"` // chat 消息格式：slack（默认）、dingtalk、feishu、wecom
+	// email 使用
+	SMTPHost     string    `json:"smtp_host"`
+	SMTPPort     int       `json:"smtp_port"`
+	SMTPUsername string    `json:"smtp_username"`
+	SMTPPassword string    `json:"smtp_password,omitempty"`
+	SMTPSecurity string    `json:"smtp_security"` // 空为服务器支持时使用STARTTLS，starttls 强制，tls 为隐式TLS，none 不加密
+	EmailFrom    string    `json:"email_from"`
+	EmailTo      string    `json:"email_to"` // 多个收件人以逗号分隔
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// 告警实例，同一规则和主机同时最多有一条未恢复的告警
+type Alert struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	RuleID         uint       `json:"rule_id" gorm:"not null;index"`
+	Rule           AlertRule  `json:"rule" gorm:"foreignKey:RuleID"`
+	HostID         uint       `json:"host_id" gorm:"not null;index"`
+	Host           Host       `json:"host" gorm:"foreignKey:HostID"`
+	State          string     `json:"state" gorm:"index"` // pending、firing、resolved
+	Value          float64    `json:"value"`              // 最近一次评估的指标值
+	StartsAt       time.Time  `json:"starts_at"`          // 首次满足条件的时间
+	FiredAt        *time.Time `json:"fired_at"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	LastNotifiedAt *time.Time `json:"last_notified_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// 静默：时间范围内匹配的告警不发送通知
+type AlertSilence struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	RuleID    *uint      `json:"rule_id"` // 为空匹配所有规则
+	HostID    *uint      `json:"host_id"` // 为空匹配所有主机
+	StartsAt  time.Time  `json:"starts_at"`
+	EndsAt    time.Time  `json:"ends_at" gorm:"index"`
+	Comment   string     `json:"comment"`
+	CreatedBy uint       `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Rule      *AlertRule `json:"rule,omitempty" gorm:"foreignKey:RuleID"`
+	Host      *Host      `json:"host,omitempty" gorm:"foreignKey:HostID"`
+}
@@ -14,9 +14,12 @@ type TerminalSession struct {
 	HostID    uint           `json:"host_id" gorm:"not null"`
 	Host      Host           `json:"host" gorm:"foreignKey:HostID"`
 	SessionID string         `json:"session_id" gorm:"unique;not null"` // WebSocket会话ID
+	Container string         `json:"container"`                         // docker exec 会话的容器，普通终端为空
 	StartTime time.Time      `json:"start_time"`
 	EndTime   *time.Time     `json:"end_time"`
 	Status    string         `json:"status" gorm:"default:active"` // active, closed
+	CastFile  string         `json:"-"`                            // RECORDING_DIR 下的 asciicast 录像文件名
+	CastSize  int64          `json:"cast_size"`                    // 录像文件大小，为0表示没有录像
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
@@ -29,11 +32,90 @@ type TerminalOperation struct {
 	Session   TerminalSession `json:"session" gorm:"foreignKey:SessionID"`
 	Type      string          `json:"type"` // input, output, resize
 	Content   string          `json:"content"`
+	UserID    *uint           `json:"user_id,omitempty" gorm:"index"` // 输入的用户，共享会话中区分各参与者
 	Timestamp time.Time       `json:"timestamp"`
 	CreatedAt time.Time       `json:"created_at"`
 	DeletedAt gorm.DeletedAt  `json:"-" gorm:"index"`
 }
 
+// 从终端会话中还原出的已执行命令
+type TerminalCommand struct {
+	ID         uint             `json:"id" gorm:"primaryKey"`
+	SessionID  uint             `json:"session_id" gorm:"index;not null"`
+	Session    *TerminalSession `json:"session,omitempty" gorm:"foreignKey:SessionID"`
+	UserID     uint             `json:"user_id" gorm:"index;not null"`
+	User       User             `json:"user" gorm:"foreignKey:UserID"`
+	HostID     uint             `json:"host_id" gorm:"index;not null"`
+	Host       Host             `json:"host" gorm:"foreignKey:HostID"`
+	Command    string           `json:"command" gorm:"type:text"`
+	WorkingDir string           `json:"working_dir"`            // 从 OSC 7、提示符或终端标题中识别，无法识别时为空
+	Timestamp  time.Time        `json:"timestamp" gorm:"index"` // 按下回车的时间
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+// 终端操作内容搜索的结果，offset 为匹配内容在会话中的秒数，可作为回放的 start 参数
+type OperationSearchResult struct {
+	OperationID uint             `json:"operation_id,omitempty"` // type 为 input、output 时对应的 TerminalOperation
+	CommandID   uint             `json:"command_id,omitempty"`   // type 为 command 时对应的 TerminalCommand
+	SessionID   uint             `json:"session_id"`
+	Session     *TerminalSession `json:"session"`
+	Type        string           `json:"type"` // input, output, command
+	Timestamp   time.Time        `json:"timestamp"`
+	Offset      float64          `json:"offset"`
+	WorkingDir  string           `json:"working_dir,omitempty"` // 命令执行时的工作目录
+	Snippet     string           `json:"snippet"`               // 匹配位置前后的内容，已去掉终端控制序列
+}
+
+// 运行中的终端会话，供管理员实时监看
+type LiveSession struct {
+	SessionID      string            `json:"session_id"`       // WebSocket会话ID
+	AuditSessionID uint              `json:"audit_session_id"` // 对应的 TerminalSession，审计会话创建失败时为0
+	UserID         uint              `json:"user_id"`
+	Username       string            `json:"username"`
+	HostID         uint              `json:"host_id"`
+	HostName       string            `json:"host_name"`
+	Container      string            `json:"container,omitempty"`
+	StartTime      time.Time         `json:"start_time"`
+	Cols           int               `json:"cols"`
+	Rows           int               `json:"rows"`
+	Participants   []LiveParticipant `json:"participants"`
+	Invites        []TerminalInvite  `json:"invites,omitempty"` // 只对会话发起人和管理员返回
+}
+
+// 连接到运行中终端会话的一方
+type LiveParticipant struct {
+	UserID      uint      `json:"user_id"`
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`             // owner 会话发起人，participant 通过邀请加入，monitor 管理员监看
+	Access      string    `json:"access,omitempty"` // participant 的权限：ro 只读，rw 可输入
+	Controlling bool      `json:"controlling"`      // 管理员接管了输入
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// 共享终端会话的邀请，持有邀请链接的已登录用户可以加入会话
+type TerminalInvite struct {
+	Token     string    `json:"token"`
+	URL       string    `json:"url"`               // 加入会话的 WebSocket 地址（不含登录token）
+	Access    string    `json:"access"`            // ro 只读，rw 可输入
+	UserID    uint      `json:"user_id,omitempty"` // 限定可加入的用户，0 表示任意已登录用户
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// 运维操作审计日志（端口转发、进程控制等终端之外的操作）
+type AuditLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index;not null"`
+	User      User      `json:"user" gorm:"foreignKey:UserID"`
+	HostID    *uint     `json:"host_id" gorm:"index"`
+	Host      *Host     `json:"host,omitempty" gorm:"foreignKey:HostID"`
+	Action    string    `json:"action" gorm:"index"` // 如 tunnel.open、tunnel.connect
+	Target    string    `json:"target"`
+	Detail    string    `json:"detail" gorm:"type:text"`
+	Status    string    `json:"status"` // success, failed
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
 // 审计查询请求
 type AuditQueryRequest struct {
 	UserID    *uint      `json:"user_id"`
@@ -51,3 +133,23 @@ type AuditQueryResponse struct {
 	Page     int               `json:"page"`
 	PageSize int               `json:"page_size"`
 }
+
+// 会话回放的控制命令，由浏览器通过 WebSocket 发送
+type PlaybackCommand struct {
+	Type  string  `json:"type"`  // play、pause、speed、seek、idle
+	Value float64 `json:"value"` // speed 为倍速，seek 为录像中的秒数，idle 为空闲间隔的上限秒数（0 表示不跳过）
+}
+
+// 会话回放推送给浏览器的消息，output 的 data 直接写入 xterm
+type PlaybackMessage struct {
+	Type     string  `json:"type"`           // header、output、resize、reset、status、end、error
+	Time     float64 `json:"time"`           // 当前在录像中的秒数
+	Data     string  `json:"data,omitempty"` // output 为终端输出，error 为错误信息
+	Cols     int     `json:"cols,omitempty"` // header、resize 的终端列数和行数
+	Rows     int     `json:"rows,omitempty"`
+	Duration float64 `json:"duration,omitempty"` // header、status 中为录像总时长
+	Speed    float64 `json:"speed,omitempty"`
+	Idle     float64 `json:"idle,omitempty"`
+	Paused   bool    `json:"paused,omitempty"`
+	Title    string  `json:"title,omitempty"`
+}
@@ -0,0 +1,66 @@
+package models
+
+// Docker 容器
+type DockerContainer struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Image     string `json:"image"`
+	Command   string `json:"command"`
+	State     string `json:"state"`  // created、running、paused、restarting、exited、dead
+	Status    string `json:"status"` // 如 "Up 2 hours"
+	Ports     string `json:"ports"`
+	Networks  string `json:"networks"`
+	Mounts    string `json:"mounts"`
+	Labels    string `json:"labels"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Docker 镜像
+type DockerImage struct {
+	ID         string `json:"id"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+	Size       string `json:"size"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// Docker 卷
+type DockerVolume struct {
+	Name       string `json:"name"`
+	Driver     string `json:"driver"`
+	Scope      string `json:"scope"`
+	Mountpoint string `json:"mountpoint"`
+	Labels     string `json:"labels"`
+}
+
+// Docker 网络
+type DockerNetwork struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Driver    string `json:"driver"`
+	Scope     string `json:"scope"`
+	Internal  string `json:"internal"`
+	IPv6      string `json:"ipv6"`
+	CreatedAt string `json:"created_at"`
+}
+
+// 容器资源使用情况（docker stats 的一次采样）
+type DockerContainerStats struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name"`
+	CPU      float64 `json:"cpu"`       // 百分比，单核满载为100
+	Memory   float64 `json:"memory"`    // 占内存限制的百分比
+	MemUsage string  `json:"mem_usage"` // 如 "12.5MiB / 1.944GiB"
+	NetIO    string  `json:"net_io"`
+	BlockIO  string  `json:"block_io"`
+	PIDs     int     `json:"pids"`
+}
+
+// 单个容器的操作结果
+type DockerActionResult struct {
+	Container string `json:"container"`
+	Action    string `json:"action"`
+	State     string `json:"state"` // 操作后的容器状态，删除后为空
+	Output    string `json:"output"`
+}
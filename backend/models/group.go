@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// 主机标签，如 prod、web、mysql
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"uniqueIndex;size:64;not null"`
+	Color     string    `json:"color"` // 前端展示用，如 #409EFF
+	HostCount int64     `json:"host_count" gorm:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 主机分组，可多级嵌套，如 环境 / 项目 / 地域
+type HostGroup struct {
+	ID          uint        `json:"id" gorm:"primaryKey"`
+	Name        string      `json:"name" gorm:"size:128;not null"`
+	ParentID    *uint       `json:"parent_id" gorm:"index"` // 为空表示顶级分组
+	Description string      `json:"description"`
+	Path        string      `json:"path" gorm:"-"`       // 从顶级分组开始的完整路径，如 prod/shop/us-east
+	HostCount   int64       `json:"host_count" gorm:"-"` // 直接属于该分组的主机数
+	Children    []HostGroup `json:"children,omitempty" gorm:"-"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// 创建或更新分组的请求
+type HostGroupRequest struct {
+	Name        string `json:"name" binding:"required"`
+	ParentID    *uint  `json:"parent_id"`
+	Description string `json:"description"`
+}
+
+// 创建或更新标签的请求
+type TagRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Color string `json:"color"`
+}
@@ -7,29 +7,128 @@ import (
 )
 
 type Host struct {
-	ID         uint           `json:"id" gorm:"primaryKey"`
-	Name       string         `json:"name" gorm:"not null"`
-	IPAddress  string         `json:"ip_address" gorm:"not null"`
-	Port       int            `json:"port" gorm:"default:22"`
-	Username   string         `json:"username" gorm:"not null"`
-	Password   string         `json:"password,omitempty"`
-	PrivateKey string         `json:"private_key,omitempty"`
-	Status     string         `json:"status" gorm:"default:offline"`
-	CreatedAt  time.Time      `json:"created_at"`
-	UpdatedAt  time.Time      `json:"updated_at"`
-	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	Name         string         `json:"name" gorm:"not null"`
+	IPAddress    string         `json:"ip_address" gorm:"not null"`
+	Port         int            `json:"port" gorm:"default:22"`
+	Username     string         `json:"username" gorm:"not null"`
+	Password     string         `json:"password,omitempty"`
+	PrivateKey   string         `json:"private_key,omitempty"`
+	Status       string         `json:"status" gorm:"default:offline"`
+	JumpHostID   *uint          `json:"jump_host_id"`            // 跳板机（ProxyJump），可逐级链式引用
+	HostKey      string         `json:"host_key"`                // 首次连接时记录的主机密钥指纹（SHA256）
+	ProxyURL     string         `json:"proxy_url"`               // socks5://[user:pass@]host:port 或 http://...，为空使用全局 SSH_PROXY，direct 表示不使用代理
+	UseSudo      bool           `json:"use_sudo"`                // 特权操作（如 systemd 管理）通过 sudo 执行
+	SudoPassword string         `json:"sudo_password,omitempty"` // 为空时使用登录密码，密钥登录时需配置 NOPASSWD
+	GroupID      *uint          `json:"group_id" gorm:"index"`
+	Group        *HostGroup     `json:"group,omitempty" gorm:"foreignKey:GroupID"`
+	Tags         []Tag          `json:"tags" gorm:"many2many:host_tags"`
+	Facts        HostFacts      `json:"facts" gorm:"embedded;embeddedPrefix:facts_"`
+	Check        HostCheck      `json:"check" gorm:"embedded;embeddedPrefix:check_"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// 主机清单信息，添加主机时和按需采集
+type HostFacts struct {
+	OS             string     `json:"os" gorm:"index"`             // os-release 中的 ID，如 ubuntu、centos、alpine
+	OSName         string     `json:"os_name"`                     // 如 "Ubuntu 22.04.3 LTS"
+	OSVersion      string     `json:"os_version"`                  // 如 22.04
+	Kernel         string     `json:"kernel"`                      // uname -r
+	Arch           string     `json:"arch" gorm:"index"`           // uname -m，如 x86_64、aarch64
+	CPUModel       string     `json:"cpu_model"`                   // CPU 型号
+	CPUCount       int        `json:"cpu_count"`                   // 逻辑CPU数
+	MemoryTotal    uint64     `json:"memory_total"`                // 字节
+	Hostname       string     `json:"hostname"`                    // 主机上配置的主机名
+	FQDN           string     `json:"fqdn"`                        // hostname -f
+	IPAddresses    string     `json:"ip_addresses"`                // 除回环和链路本地地址外的所有地址，逗号分隔
+	Virtualization string     `json:"virtualization" gorm:"index"` // 如 kvm、vmware、docker、lxc，物理机为 none
+	PackageManager string     `json:"package_manager"`             // apt、dnf、yum、zypper、pacman、apk 等
+	Error          string     `json:"error,omitempty"`             // 最近一次采集失败的原因
+	CollectedAt    *time.Time `json:"collected_at"`                // 最近一次采集成功的时间
+}
+
+// 最近一次连接检测的结果，添加主机后检测完成前主机状态为 unknown
+type HostCheck struct {
+	Success    bool       `json:"success"`
+	FailedStep string     `json:"failed_step,omitempty"` // 失败的步骤：dns、jump、tcp、handshake、auth、shell
+	Error      string     `json:"error,omitempty"`
+	CheckedAt  *time.Time `json:"checked_at"`
+}
+
+// 连接诊断结果，按步骤分别给出
+type HostDiagnostic struct {
+	HostID     uint             `json:"host_id"`
+	Success    bool             `json:"success"`
+	FailedStep string           `json:"failed_step,omitempty"`
+	Steps      []DiagnosticStep `json:"steps"`
+	HostKey    string           `json:"host_key,omitempty"` // 握手时服务器提供的主机密钥指纹
+	CheckedAt  time.Time        `json:"checked_at"`
+}
+
+// 诊断中单个步骤的结果
+type DiagnosticStep struct {
+	Name       string `json:"name"`   // dns、jump、tcp、handshake、auth、shell
+	Status     string `json:"status"` // ok、failed、skipped
+	Detail     string `json:"detail,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
 }
 
 type HostStats struct {
-	HostID      uint      `json:"host_id"`
-	CPUUsage    float64   `json:"cpu_usage"`
-	MemoryUsage float64   `json:"memory_usage"`
-	MemoryTotal uint64    `json:"memory_total"`
-	MemoryUsed  uint64    `json:"memory_used"`
-	DiskUsage   float64   `json:"disk_usage"`
-	DiskTotal   uint64    `json:"disk_total"`
-	DiskUsed    uint64    `json:"disk_used"`
-	NetworkIn   uint64    `json:"network_in"`
-	NetworkOut  uint64    `json:"network_out"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	HostID         uint              `json:"host_id"`
+	CPUUsage       float64           `json:"cpu_usage"`
+	CPUCount       int               `json:"cpu_count"`
+	CPUCores       []float64         `json:"cpu_cores"` // 每个逻辑CPU的使用率
+	MemoryUsage    float64           `json:"memory_usage"`
+	MemoryTotal    uint64            `json:"memory_total"`
+	MemoryUsed     uint64            `json:"memory_used"`
+	SwapUsage      float64           `json:"swap_usage"`
+	SwapTotal      uint64            `json:"swap_total"`
+	SwapUsed       uint64            `json:"swap_used"`
+	DiskUsage      float64           `json:"disk_usage"` // 根文件系统
+	DiskTotal      uint64            `json:"disk_total"`
+	DiskUsed       uint64            `json:"disk_used"`
+	Filesystems    []FilesystemStats `json:"filesystems"`
+	NetworkIn      uint64            `json:"network_in"` // 除回环外所有网卡的累计接收字节
+	NetworkOut     uint64            `json:"network_out"`
+	NetworkInRate  float64           `json:"network_in_rate"` // 字节/秒
+	NetworkOutRate float64           `json:"network_out_rate"`
+	Interfaces     []InterfaceStats  `json:"interfaces"`
+	Load1          float64           `json:"load1"`
+	Load5          float64           `json:"load5"`
+	Load15         float64           `json:"load15"`
+	Uptime         uint64            `json:"uptime"` // 秒
+	ProcessCount   int               `json:"process_count"`
+	LoggedInUsers  int               `json:"logged_in_users"` // 登录会话数
+	Users          []string          `json:"users"`           // 已登录的用户名（去重）
+	UpdatedAt      time.Time         `json:"updated_at"`
+	// 各项指标采集失败的原因，键为 cpu、memory、swap、disk、network、load、uptime、processes、users
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// 文件系统使用情况
+type FilesystemStats struct {
+	Device      string  `json:"device"`
+	MountPoint  string  `json:"mount_point"`
+	FSType      string  `json:"fs_type"`
+	Total       uint64  `json:"total"`
+	Used        uint64  `json:"used"`
+	Available   uint64  `json:"available"`
+	Usage       float64 `json:"usage"`
+	InodesTotal uint64  `json:"inodes_total"`
+	InodesUsed  uint64  `json:"inodes_used"`
+	InodeUsage  float64 `json:"inode_usage"`
+}
+
+// 网卡流量
+type InterfaceStats struct {
+	Name      string  `json:"name"`
+	RxBytes   uint64  `json:"rx_bytes"`
+	TxBytes   uint64  `json:"tx_bytes"`
+	RxPackets uint64  `json:"rx_packets"`
+	TxPackets uint64  `json:"tx_packets"`
+	RxRate    float64 `json:"rx_rate"` // 字节/秒
+	TxRate    float64 `json:"tx_rate"`
 }
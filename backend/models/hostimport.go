@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 导入导出的一台主机，CSV、YAML/JSON 和 ssh_config 解析后统一为该结构
+type HostImportRow struct {
+	Name         string  `json:"name" yaml:"name"`
+	IPAddress    string  `json:"ip_address" yaml:"ip_address"`
+	Port         int     `json:"port,omitempty" yaml:"port,omitempty"`
+	Username     string  `json:"username,omitempty" yaml:"username,omitempty"`
+	Password     string  `json:"password,omitempty" yaml:"password,omitempty"`
+	PrivateKey   string  `json:"private_key,omitempty" yaml:"private_key,omitempty"`
+	IdentityFile string  `json:"identity_file,omitempty" yaml:"identity_file,omitempty"` // 私钥文件路径，内容由请求的 identity_files 提供
+	JumpHost     string  `json:"jump_host,omitempty" yaml:"jump_host,omitempty"`         // 跳板机名称，可以是已有主机或同批导入的主机
+	ProxyURL     string  `json:"proxy_url,omitempty" yaml:"proxy_url,omitempty"`
+	UseSudo      *bool   `json:"use_sudo,omitempty" yaml:"use_sudo,omitempty"` // 未填写时新建为 false，更新时沿用原值
+	SudoPassword string  `json:"sudo_password,omitempty" yaml:"sudo_password,omitempty"`
+	Group        string  `json:"group,omitempty" yaml:"group,omitempty"` // 分组路径，如 prod/shop，不存在时自动创建
+	Tags         TagList `json:"tags,omitempty" yaml:"tags,omitempty"`
+}
+
+// TagList 标签名列表，也接受逗号或分号分隔的字符串
+type TagList []string
+
+// SplitTagList 按逗号、分号或竖线拆分标签名
+func SplitTagList(s string) TagList {
+	var tags TagList
+	for _, name := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if name = strings.TrimSpace(name); name != "" {
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
+
+func (t *TagList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = SplitTagList(s)
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+func (t *TagList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = SplitTagList(node.Value)
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// 批量导入请求
+type HostImportRequest struct {
+	Format         string            `json:"format" binding:"required"`  // csv、yaml、json、ssh_config
+	Content        string            `json:"content" binding:"required"` // 文件内容
+	DryRun         bool              `json:"dry_run"`                    // 只校验并预览，不写入
+	TestConnection bool              `json:"test_connection"`            // 并发测试每台主机的连接，失败的行视为无效
+	OnConflict     string            `json:"on_conflict"`                // 与已有主机重名时：error（默认）、skip、update
+	Defaults       HostImportRow     `json:"defaults"`                   // 各行未填写的字段使用的默认值
+	IdentityFiles  map[string]string `json:"identity_files"`             // 私钥文件路径（如 ssh_config 中的 IdentityFile）到私钥内容
+}
+
+// 单行的校验和导入结果
+type HostImportRowResult struct {
+	Row        int      `json:"row"` // 源文件中的行号，ssh_config 为 Host 所在行
+	Name       string   `json:"name"`
+	IPAddress  string   `json:"ip_address"`
+	Action     string   `json:"action"` // create、update、skip
+	Valid      bool     `json:"valid"`
+	Errors     []string `json:"errors,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"`
+	Connection string   `json:"connection,omitempty"` // 测试连接时为 ok 或失败原因
+	HostID     uint     `json:"host_id,omitempty"`    // 写入后的主机ID
+}
+
+// 批量导入结果
+type HostImportResponse struct {
+	DryRun    bool                  `json:"dry_run"`
+	Committed bool                  `json:"committed"` // 是否已写入数据库
+	Total     int                   `json:"total"`
+	Valid     int                   `json:"valid"`
+	Invalid   int                   `json:"invalid"`
+	Created   int                   `json:"created"` // 预览或未写入时为计划数量
+	Updated   int                   `json:"updated"`
+	Skipped   int                   `json:"skipped"`
+	Warnings  []string              `json:"warnings,omitempty"` // 与具体行无关的提示，如不支持的 CSV 列
+	Rows      []HostImportRowResult `json:"rows"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 保存的脚本
+type Script struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"not null"`
+	Description string         `json:"description"`
+	Content     string         `json:"content" gorm:"type:text;not null"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// 定时任务
+type ScheduledJob struct {
+	ID             uint           `json:"id" gorm:"primaryKey"`
+	Name           string         `json:"name" gorm:"not null"`
+	CronExpr       string         `json:"cron_expr" gorm:"not null"`          // 标准5段cron表达式或 @daily 等描述符
+	Timezone       string         `json:"timezone" gorm:"default:Local"`      // IANA时区名，如 Asia/Shanghai
+	JitterSeconds  int            `json:"jitter_seconds" gorm:"default:0"`    // 每次触发随机延迟的上限（秒）
+	TimeoutSeconds int            `json:"timeout_seconds" gorm:"default:300"` // 单台主机执行超时（秒）
+	Command        string         `json:"command" gorm:"type:text"`           // 与ScriptID二选一
+	ScriptID       *uint          `json:"script_id"`
+	Script         *Script        `json:"script,omitempty" gorm:"foreignKey:ScriptID"`
+	Hosts          []Host         `json:"hosts" gorm:"many2many:scheduled_job_hosts"`
+	Enabled        bool           `json:"enabled" gorm:"default:true"`
+	LastRunAt      *time.Time     `json:"last_run_at"`
+	LastStatus     string         `json:"last_status"` // success, failed, skipped
+	NextRunAt      *time.Time     `json:"next_run_at" gorm:"index"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// 定时任务执行记录（每台主机一条）
+type JobRun struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	JobID     uint       `json:"job_id" gorm:"not null;index"`
+	HostID    uint       `json:"host_id"`
+	Host      Host       `json:"host" gorm:"foreignKey:HostID"`
+	Trigger   string     `json:"trigger"` // schedule, manual
+	Status    string     `json:"status"`  // running, success, failed, skipped
+	Output    string     `json:"output" gorm:"type:text"`
+	Error     string     `json:"error"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   *time.Time `json:"end_time"`
+	CreatedAt time.Time  `json:"created_at"`
+}
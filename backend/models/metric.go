@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// 主机指标采样点
+type MetricSample struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	HostID     uint      `json:"host_id" gorm:"index:idx_metric_series,priority:1;not null"`
+	Metric     string    `json:"metric" gorm:"index:idx_metric_series,priority:2;size:64;not null"`
+	Resolution int       `json:"resolution" gorm:"index:idx_metric_series,priority:3"` // 0为原始采样，否则为降采样的时间粒度（秒）
+	Timestamp  time.Time `json:"timestamp" gorm:"index:idx_metric_series,priority:4;index"`
+	Value      float64   `json:"value"`
+}
+
+// 历史查询返回的数据点
+type MetricPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// 单个指标的时间序列
+type MetricSeries struct {
+	Metric string        `json:"metric"`
+	Points []MetricPoint `json:"points"`
+}
+
+// 历史查询响应
+type MetricHistoryResponse struct {
+	HostID uint           `json:"host_id"`
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Step   int            `json:"step"` // 秒
+	Series []MetricSeries `json:"series"`
+}
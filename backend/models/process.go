@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// 远程主机上的进程
+type ProcessInfo struct {
+	PID       int       `json:"pid"`
+	PPID      int       `json:"ppid"`
+	UID       int       `json:"uid"`
+	User      string    `json:"user"`
+	State     string    `json:"state"`  // R、S、D、Z 等
+	CPU       float64   `json:"cpu"`    // 采样期间的CPU使用率，单核满载为100
+	Memory    float64   `json:"memory"` // 占物理内存的百分比
+	RSS       uint64    `json:"rss"`    // 字节
+	Threads   int       `json:"threads"`
+	Command   string    `json:"command"`
+	StartTime time.Time `json:"start_time"`
+}
+
+// 发送信号请求
+type ProcessSignalRequest struct {
+	Signal string `json:"signal" binding:"required"` // TERM、KILL、HUP
+}
@@ -0,0 +1,28 @@
+package models
+
+// systemd 单元
+type SystemdUnit struct {
+	Name          string `json:"name"`
+	Load          string `json:"load"`            // loaded、not-found、masked，未加载的单元为 not-loaded
+	Active        string `json:"active"`          // active、inactive、failed 等
+	Sub           string `json:"sub"`             // running、exited、dead 等
+	UnitFileState string `json:"unit_file_state"` // enabled、disabled、static、masked 等
+	Description   string `json:"description"`
+}
+
+// 批量操作 systemd 单元的请求
+type SystemdBulkRequest struct {
+	HostIDs []uint `json:"host_ids" binding:"required"`
+	Unit    string `json:"unit" binding:"required"`
+	Action  string `json:"action" binding:"required"` // start、stop、restart、reload、enable、disable
+}
+
+// 单台主机的 systemd 操作结果
+type SystemdActionResult struct {
+	HostID   uint   `json:"host_id"`
+	HostName string `json:"host_name"`
+	Success  bool   `json:"success"`
+	State    string `json:"state"` // 操作后的 is-active 或 is-enabled 结果
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+}
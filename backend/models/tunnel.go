@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// 端口转发隧道
+type PortForward struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"user_id" gorm:"index;not null"`
+	User        User       `json:"user" gorm:"foreignKey:UserID"`
+	HostID      uint       `json:"host_id" gorm:"index;not null"`
+	Host        Host       `json:"host" gorm:"foreignKey:HostID"`
+	Mode        string     `json:"mode"`        // local: 服务端监听端口; dynamic: 服务端SOCKS5代理; websocket: WebSocket字节流
+	TargetHost  string     `json:"target_host"` // dynamic 模式下为空，目标由每个连接决定
+	TargetPort  int        `json:"target_port"`
+	ListenAddr  string     `json:"listen_addr"`                  // local/dynamic 模式的服务端监听地址
+	Status      string     `json:"status" gorm:"default:active"` // active, closed
+	CloseReason string     `json:"close_reason"`
+	BytesIn     int64      `json:"bytes_in"`  // 远端 -> 客户端
+	BytesOut    int64      `json:"bytes_out"` // 客户端 -> 远端
+	ConnCount   int64      `json:"conn_count"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// 创建端口转发请求
+type TunnelRequest struct {
+	HostID     uint   `json:"host_id" binding:"required"`
+	Mode       string `json:"mode" binding:"required"`
+	TargetHost string `json:"target_host"`
+	TargetPort int    `json:"target_port"`
+	ListenPort int    `json:"listen_port"` // local/dynamic 模式可指定服务端监听端口，0为随机
+	TTLSeconds int    `json:"ttl_seconds"`
+}
@@ -18,6 +18,15 @@ type User struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
+// 登录令牌
+type UserToken struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Token     string    `json:"-" gorm:"uniqueIndex;size:64;not null"`
+	UserID    uint      `json:"user_id" gorm:"index;not null"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
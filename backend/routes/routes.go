@@ -10,6 +10,10 @@ import (
 func SetupRoutes() *gin.Engine {
 	r := gin.Default()
 
+	// 发往代理域名的 /proxy/:hostId/:port/*path 请求直接反向代理到主机上的HTTP服务（有自己的token验证），不经过下面的CORS和API路由
+	proxyController := controllers.NewProxyController()
+	r.Use(proxyController.HostRouter())
+
 	// 配置CORS
 	config := cors.DefaultConfig()
 	// 生产环境和开发环境的CORS配置
@@ -28,9 +32,23 @@ func SetupRoutes() *gin.Engine {
 	authController := controllers.NewAuthController()
 	auditController := controllers.NewAuditController()
 	fileController := controllers.NewFileController()
+	jobController := controllers.NewJobController()
+	tunnelController := controllers.NewTunnelController()
+	metricsController := controllers.NewMetricsController()
+	alertController := controllers.NewAlertController()
+	processController := controllers.NewProcessController()
+	systemdController := controllers.NewSystemdController()
+	logTailController := controllers.NewLogTailController()
+	dockerController := controllers.NewDockerController()
+	groupController := controllers.NewGroupController()
+	tagController := controllers.NewTagController()
+
+	// Prometheus 指标
+	r.GET("/metrics", metricsController.Metrics)
 
 	// API路由组
 	api := r.Group("/api")
+	api.Use(metricsController.RequestMetrics())
 	{
 		// 健康检查路由（不需要认证）
 		api.GET("/health", func(c *gin.Context) {
@@ -42,6 +60,7 @@ func SetupRoutes() *gin.Engine {
 		{
 			auth.POST("/login", authController.Login)
 			auth.POST("/register", authController.Register)
+			auth.POST("/logout", authController.Logout)
 		}
 
 		// 需要认证的路由
@@ -53,9 +72,47 @@ func SetupRoutes() *gin.Engine {
 			{
 				hosts.GET("", hostController.GetHosts)
 				hosts.POST("", hostController.CreateHost)
+				hosts.POST("/import", hostController.ImportHosts)
+				hosts.GET("/export", hostController.ExportHosts)
 				hosts.GET("/:id", hostController.GetHost)
+				hosts.PUT("/:id", hostController.UpdateHost)
+				hosts.DELETE("/:id/host-key", hostController.ResetHostKey)
 				hosts.DELETE("/:id", hostController.DeleteHost)
+				hosts.POST("/:id/test", hostController.TestHost)
+				hosts.POST("/:id/facts", hostController.RefreshHostFacts)
 				hosts.GET("/:id/stats", hostController.GetHostStats)
+				hosts.GET("/:id/stats/history", hostController.GetHostStatsHistory)
+				hosts.POST("/:id/proxy-link", proxyController.CreateLink)
+				hosts.GET("/:id/processes", processController.GetProcesses)
+				hosts.POST("/:id/processes/:pid/signal", processController.SignalProcess)
+				hosts.GET("/:id/systemd", systemdController.GetUnits)
+				hosts.GET("/:id/systemd/:unit/logs", systemdController.UnitLogs)
+				hosts.POST("/:id/systemd/:unit/:action", systemdController.UnitAction)
+				hosts.GET("/:id/docker/containers", dockerController.GetContainers)
+				hosts.GET("/:id/docker/containers/:container/logs", dockerController.ContainerLogs)
+				hosts.POST("/:id/docker/containers/:container/:action", dockerController.ContainerAction)
+				hosts.GET("/:id/docker/images", dockerController.GetImages)
+				hosts.GET("/:id/docker/volumes", dockerController.GetVolumes)
+				hosts.GET("/:id/docker/networks", dockerController.GetNetworks)
+				hosts.GET("/:id/docker/stats", dockerController.GetStats)
+			}
+
+			// 主机分组路由
+			groups := protected.Group("/groups")
+			{
+				groups.GET("", groupController.GetGroups)
+				groups.POST("", groupController.CreateGroup)
+				groups.PUT("/:id", groupController.UpdateGroup)
+				groups.DELETE("/:id", groupController.DeleteGroup)
+			}
+
+			// 主机标签路由
+			tags := protected.Group("/tags")
+			{
+				tags.GET("", tagController.GetTags)
+				tags.POST("", tagController.CreateTag)
+				tags.PUT("/:id", tagController.UpdateTag)
+				tags.DELETE("/:id", tagController.DeleteTag)
 			}
 
 			// 用户管理路由
@@ -67,12 +124,28 @@ func SetupRoutes() *gin.Engine {
 				users.PUT("/:id/password", authController.ChangePassword)
 			}
 
+			// 共享终端会话
+			terminals := protected.Group("/terminal/sessions")
+			{
+				terminals.GET("", terminalController.GetMySessions)
+				terminals.GET("/:session_id", terminalController.GetLiveSession)
+				terminals.POST("/:session_id/invites", terminalController.CreateInvite)
+				terminals.DELETE("/:session_id/invites/:token", terminalController.RevokeInvite)
+			}
+
 			// 审计管理路由
 			audit := protected.Group("/audit")
 			{
 				audit.GET("/sessions", auditController.GetSessions)
 				audit.GET("/sessions/:id/operations", auditController.GetSessionOperations)
+				audit.GET("/sessions/:id/cast", auditController.GetSessionCast)
+				audit.POST("/sessions/:id/commands/rebuild", auditController.RebuildSessionCommands)
+				audit.GET("/commands", auditController.SearchCommands)
+				audit.GET("/search", auditController.SearchOperations)
+				audit.GET("/live", terminalController.GetLiveSessions)
+				audit.POST("/live/:session_id/terminate", terminalController.TerminateLiveSession)
 				audit.DELETE("/sessions/:id", auditController.DeleteSession)
+				audit.GET("/logs", auditController.GetAuditLogs)
 			}
 
 			// 文件管理路由
@@ -85,10 +158,71 @@ func SetupRoutes() *gin.Engine {
 				files.POST("/:id/mkdir", fileController.CreateDirectory)
 				files.PUT("/:id/rename", fileController.RenameFile)
 			}
+
+			// 定时任务路由
+			jobs := protected.Group("/jobs")
+			{
+				jobs.GET("", jobController.GetJobs)
+				jobs.POST("", jobController.CreateJob)
+				jobs.GET("/:id", jobController.GetJob)
+				jobs.PUT("/:id", jobController.UpdateJob)
+				jobs.DELETE("/:id", jobController.DeleteJob)
+				jobs.POST("/:id/run", jobController.RunJob)
+				jobs.GET("/:id/runs", jobController.GetJobRuns)
+			}
+
+			// 脚本管理路由
+			scripts := protected.Group("/scripts")
+			{
+				scripts.GET("", jobController.GetScripts)
+				scripts.POST("", jobController.CreateScript)
+				scripts.PUT("/:id", jobController.UpdateScript)
+				scripts.DELETE("/:id", jobController.DeleteScript)
+			}
+
+			// 端口转发路由
+			tunnels := protected.Group("/tunnels")
+			{
+				tunnels.GET("", tunnelController.GetTunnels)
+				tunnels.POST("", tunnelController.CreateTunnel)
+				tunnels.DELETE("/:id", tunnelController.CloseTunnel)
+			}
+
+			// systemd 批量操作路由
+			protected.POST("/systemd/actions", systemdController.BulkAction)
+
+			// 告警路由
+			alerts := protected.Group("/alerts")
+			{
+				alerts.GET("", alertController.GetAlerts)
+				alerts.GET("/rules", alertController.GetRules)
+				alerts.POST("/rules", alertController.CreateRule)
+				alerts.PUT("/rules/:id", alertController.UpdateRule)
+				alerts.DELETE("/rules/:id", alertController.DeleteRule)
+				alerts.GET("/channels", alertController.GetChannels)
+				alerts.POST("/channels", alertController.CreateChannel)
+				alerts.PUT("/channels/:id", alertController.UpdateChannel)
+				alerts.DELETE("/channels/:id", alertController.DeleteChannel)
+				alerts.POST("/channels/:id/test", alertController.TestChannel)
+				alerts.GET("/silences", alertController.GetSilences)
+				alerts.POST("/silences", alertController.CreateSilence)
+				alerts.DELETE("/silences/:id", alertController.DeleteSilence)
+			}
 		}
 
 		// 终端路由（有自己的token验证）
 		api.GET("/terminal/:id", terminalController.HandleTerminal)
+		api.GET("/terminal/join/:invite", terminalController.JoinSession)
+		api.GET("/tunnels/:id/stream", tunnelController.HandleTunnelStream)
+
+		// 实时日志路由（有自己的token验证）
+		api.GET("/logs/tail", logTailController.HandleTail)
+
+		// 审计会话回放路由（有自己的token验证）
+		api.GET("/audit/sessions/:id/play", auditController.HandlePlayback)
+
+		// 管理员监看运行中的终端会话（有自己的token验证）
+		api.GET("/audit/live/:session_id/watch", terminalController.WatchLiveSession)
 	}
 
 	return r
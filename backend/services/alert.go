@@ -0,0 +1,278 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+)
+
+// AlertManager 在每轮指标采集后评估告警规则并发送通知
+type AlertManager struct {
+	mu sync.Mutex
+}
+
+var (
+	alertManagerOnce     sync.Once
+	alertManagerInstance *AlertManager
+)
+
+// GetAlertManager 返回全局告警管理器
+func GetAlertManager() *AlertManager {
+	alertManagerOnce.Do(func() {
+		alertManagerInstance = &AlertManager{}
+	})
+	return alertManagerInstance
+}
+
+// ValidAlertMetric 判断规则可使用的指标名
+func ValidAlertMetric(metric string) bool {
+	if metric == "host_up" {
+		return true
+	}
+	_, ok := metricExtractors[metric]
+	return ok
+}
+
+// ValidAlertOperator 判断比较运算符是否合法
+func ValidAlertOperator(op string) bool {
+	switch op {
+	case ">", ">=", "<", "<=", "==", "!=":
+		return true
+	}
+	return false
+}
+
+func compareThreshold(value float64, op string, threshold float64) bool {
+	switch op {
+	case ">":
+		return value > threshold
+	case ">=":
+		return value >= threshold
+	case "<":
+		return value < threshold
+	case "<=":
+		return value <= threshold
+	case "==":
+		return value == threshold
+	case "!=":
+		return value != threshold
+	}
+	return false
+}
+
+// 取主机当前的指标值，没有数据时返回false
+func alertMetricValue(metric string, hostID uint) (float64, bool) {
+	stats := GetMetricsCollector().Latest(hostID)
+	if metric == "host_up" {
+		if stats == nil {
+			return 0, true
+		}
+		return 1, true
+	}
+	if stats == nil {
+		return 0, false
+	}
+	if _, failed := stats.Errors[metricErrorKeys[metric]]; failed {
+		return 0, false
+	}
+	return metricExtractors[metric](stats), true
+}
+
+func alertKey(ruleID, hostID uint) string {
+	return fmt.Sprintf("%d:%d", ruleID, hostID)
+}
+
+// Evaluate 评估所有启用的规则，更新告警状态
+func (a *AlertManager) Evaluate() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+
+	var rules []models.AlertRule
+	if err := config.DB.Preload("Hosts").Preload("Tags").Preload("Channels").Where("enabled = ?", true).Find(&rules).Error; err != nil {
+		log.Printf("Failed to load alert rules: %v", err)
+		return
+	}
+
+	var hosts []models.Host
+	config.DB.Preload("Tags").Find(&hosts)
+
+	var silences []models.AlertSilence
+	config.DB.Where("starts_at <= ? AND ends_at > ?", now, now).Find(&silences)
+
+	// 同一规则和主机只保留一条未恢复的告警，实现去重
+	var activeAlerts []models.Alert
+	config.DB.Where("state IN ?", []string{"pending", "firing"}).Find(&activeAlerts)
+	active := make(map[string]*models.Alert, len(activeAlerts))
+	for i := range activeAlerts {
+		active[alertKey(activeAlerts[i].RuleID, activeAlerts[i].HostID)] = &activeAlerts[i]
+	}
+
+	evaluated := make(map[string]bool)
+	for i := range rules {
+		rule := &rules[i]
+		targets := ruleTargets(rule, hosts)
+
+		for j := range targets {
+			host := &targets[j]
+			key := alertKey(rule.ID, host.ID)
+			evaluated[key] = true
+
+			value, ok := alertMetricValue(rule.Metric, host.ID)
+			if !ok {
+				// 没有数据时保持原状态
+				continue
+			}
+			silenced := isSilenced(silences, rule.ID, host.ID)
+			a.transition(rule, host, active[key], value, silenced, now)
+		}
+	}
+
+	// 规则被删除、停用或不再包含该主机时直接结束告警，不发送恢复通知
+	for key, alert := range active {
+		if evaluated[key] {
+			continue
+		}
+		if alert.State == "pending" {
+			config.DB.Delete(alert)
+			continue
+		}
+		alert.State = "resolved"
+		alert.ResolvedAt = &now
+		config.DB.Save(alert)
+	}
+}
+
+// 规则作用的主机：指定的主机加上带有任一指定标签的主机，都未指定时为所有主机
+func ruleTargets(rule *models.AlertRule, hosts []models.Host) []models.Host {
+	if len(rule.Hosts) == 0 && len(rule.Tags) == 0 {
+		return hosts
+	}
+
+	targets := append([]models.Host{}, rule.Hosts...)
+	included := make(map[uint]bool, len(targets))
+	for _, host := range targets {
+		included[host.ID] = true
+	}
+	tagIDs := make(map[uint]bool, len(rule.Tags))
+	for _, tag := range rule.Tags {
+		tagIDs[tag.ID] = true
+	}
+	for _, host := range hosts {
+		if included[host.ID] {
+			continue
+		}
+		for _, tag := range host.Tags {
+			if tagIDs[tag.ID] {
+				targets = append(targets, host)
+				included[host.ID] = true
+				break
+			}
+		}
+	}
+	return targets
+}
+
+func isSilenced(silences []models.AlertSilence, ruleID, hostID uint) bool {
+	for _, s := range silences {
+		if (s.RuleID == nil || *s.RuleID == ruleID) && (s.HostID == nil || *s.HostID == hostID) {
+			return true
+		}
+	}
+	return false
+}
+
+// 根据本次评估结果推进告警状态：pending -> firing -> resolved
+func (a *AlertManager) transition(rule *models.AlertRule, host *models.Host, alert *models.Alert, value float64, silenced bool, now time.Time) {
+	breach := compareThreshold(value, rule.Operator, rule.Threshold)
+
+	if !breach {
+		if alert == nil {
+			return
+		}
+		if alert.State == "pending" {
+			// 未达到持续时间就恢复，不产生告警
+			config.DB.Delete(alert)
+			return
+		}
+		alert.State = "resolved"
+		alert.Value = value
+		alert.ResolvedAt = &now
+		if err := config.DB.Save(alert).Error; err != nil {
+			log.Printf("Failed to resolve alert %d: %v", alert.ID, err)
+			return
+		}
+		// 只有发出过触发通知的告警才发送恢复通知
+		if alert.LastNotifiedAt != nil && !silenced {
+			a.notify(rule, host, alert)
+		}
+		return
+	}
+
+	if alert == nil {
+		alert = &models.Alert{
+			RuleID:   rule.ID,
+			HostID:   host.ID,
+			State:    "pending",
+			StartsAt: now,
+		}
+	}
+	alert.Value = value
+
+	if alert.State == "pending" && now.Sub(alert.StartsAt) >= time.Duration(rule.DurationSeconds)*time.Second {
+		alert.State = "firing"
+		alert.FiredAt = &now
+	}
+
+	notify := false
+	if alert.State == "firing" && !silenced {
+		repeat := time.Duration(rule.RepeatSeconds) * time.Second
+		if alert.LastNotifiedAt == nil || (repeat > 0 && now.Sub(*alert.LastNotifiedAt) >= repeat) {
+			notify = true
+			alert.LastNotifiedAt = &now
+		}
+	}
+
+	if err := config.DB.Save(alert).Error; err != nil {
+		log.Printf("Failed to save alert for rule %d host %d: %v", rule.ID, host.ID, err)
+		return
+	}
+	if notify {
+		a.notify(rule, host, alert)
+	}
+}
+
+// 异步向规则的所有渠道发送通知，避免阻塞采集循环
+func (a *AlertManager) notify(rule *models.AlertRule, host *models.Host, alert *models.Alert) {
+	n := &AlertNotification{
+		Status:      alert.State,
+		AlertID:     alert.ID,
+		RuleID:      rule.ID,
+		RuleName:    rule.Name,
+		Description: rule.Description,
+		Severity:    rule.Severity,
+		HostID:      host.ID,
+		HostName:    host.Name,
+		HostAddress: host.IPAddress,
+		Metric:      rule.Metric,
+		Operator:    rule.Operator,
+		Threshold:   rule.Threshold,
+		Value:       alert.Value,
+		StartsAt:    alert.StartsAt,
+		ResolvedAt:  alert.ResolvedAt,
+	}
+
+	for i := range rule.Channels {
+		channel := rule.Channels[i]
+		go func() {
+			if err := SendNotification(&channel, n); err != nil {
+				log.Printf("Failed to send alert %d via channel %s: %v", alert.ID, channel.Name, err)
+			}
+		}()
+	}
+}
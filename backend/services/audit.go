@@ -2,6 +2,8 @@ package services
 
 import (
 	"fmt"
+	"log"
+	"os"
 	"time"
 
 	"host-manager/config"
@@ -14,12 +16,13 @@ func NewAuditService() *AuditService {
 	return &AuditService{}
 }
 
-// 创建终端会话
-func (a *AuditService) CreateSession(userID, hostID uint, sessionID string) (*models.TerminalSession, error) {
+// 创建终端会话，container 不为空时为容器内的 docker exec 会话
+func (a *AuditService) CreateSession(userID, hostID uint, sessionID, container string) (*models.TerminalSession, error) {
 	session := models.TerminalSession{
 		UserID:    userID,
 		HostID:    hostID,
 		SessionID: sessionID,
+		Container: container,
 		StartTime: time.Now(),
 		Status:    "active",
 	}
@@ -65,6 +68,77 @@ func (a *AuditService) RecordOperation(sessionID string, opType, content string)
 	return result.Error
 }
 
+// 记录运维操作，hostID为0表示与主机无关
+func (a *AuditService) RecordAction(userID, hostID uint, action, target, detail string, actionErr error) {
+	entry := models.AuditLog{
+		UserID: userID,
+		Action: action,
+		Target: target,
+		Detail: detail,
+		Status: "success",
+	}
+	if hostID != 0 {
+		entry.HostID = &hostID
+	}
+	if actionErr != nil {
+		entry.Status = "failed"
+		if entry.Detail != "" {
+			entry.Detail += "; "
+		}
+		entry.Detail += actionErr.Error()
+	}
+
+	if err := config.DB.Create(&entry).Error; err != nil {
+		log.Printf("Failed to record audit log %s: %v", action, err)
+	}
+}
+
+// 获取运维操作审计日志
+func (a *AuditService) GetAuditLogs(req models.AuditQueryRequest, action string) ([]models.AuditLog, int64, error) {
+	var logs []models.AuditLog
+	var total int64
+
+	query := config.DB.Model(&models.AuditLog{}).
+		Preload("User").
+		Preload("Host")
+
+	if req.UserID != nil {
+		query = query.Where("user_id = ?", *req.UserID)
+	}
+	if req.HostID != nil {
+		query = query.Where("host_id = ?", *req.HostID)
+	}
+	if req.StartTime != nil {
+		query = query.Where("created_at >= ?", *req.StartTime)
+	}
+	if req.EndTime != nil {
+		query = query.Where("created_at <= ?", *req.EndTime)
+	}
+	if action != "" {
+		// 支持按前缀过滤，如 tunnel 匹配 tunnel.open、tunnel.close
+		query = query.Where("action = ? OR action LIKE ?", action, action+".%")
+	}
+
+	query.Count(&total)
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	result := query.Order("created_at DESC").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&logs)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return logs, total, nil
+}
+
 // 获取会话列表
 func (a *AuditService) GetSessions(req models.AuditQueryRequest) (*models.AuditQueryResponse, error) {
 	var sessions []models.TerminalSession
@@ -117,6 +191,54 @@ func (a *AuditService) GetSessions(req models.AuditQueryRequest) (*models.AuditQ
 	}, nil
 }
 
+// 搜索所有会话中执行过的命令，keyword 按子串匹配命令行
+func (a *AuditService) SearchCommands(req models.AuditQueryRequest, sessionID *uint, keyword string) ([]models.TerminalCommand, int64, error) {
+	var commands []models.TerminalCommand
+	var total int64
+
+	query := config.DB.Model(&models.TerminalCommand{}).
+		Preload("User").
+		Preload("Host")
+
+	if req.UserID != nil {
+		query = query.Where("user_id = ?", *req.UserID)
+	}
+	if req.HostID != nil {
+		query = query.Where("host_id = ?", *req.HostID)
+	}
+	if sessionID != nil {
+		query = query.Where("session_id = ?", *sessionID)
+	}
+	if req.StartTime != nil {
+		query = query.Where("timestamp >= ?", *req.StartTime)
+	}
+	if req.EndTime != nil {
+		query = query.Where("timestamp <= ?", *req.EndTime)
+	}
+	if keyword != "" {
+		query = query.Where("command LIKE ?", "%"+keyword+"%")
+	}
+
+	query.Count(&total)
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	result := query.Order("timestamp DESC, id DESC").
+		Offset((req.Page - 1) * req.PageSize).
+		Limit(req.PageSize).
+		Find(&commands)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	return commands, total, nil
+}
+
 // 获取会话操作记录
 func (a *AuditService) GetSessionOperations(sessionID uint) ([]models.TerminalOperation, error) {
 	var operations []models.TerminalOperation
@@ -131,10 +253,27 @@ func (a *AuditService) GetSessionOperations(sessionID uint) ([]models.TerminalOp
 	return operations, nil
 }
 
-// 删除会话（软删除）
+// 获取单个会话
+func (a *AuditService) GetSession(sessionID uint) (*models.TerminalSession, error) {
+	var session models.TerminalSession
+	if err := config.DB.First(&session, sessionID).Error; err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// 删除会话（软删除），录像文件一并删除
 func (a *AuditService) DeleteSession(sessionID uint) error {
-	// 删除操作记录
+	var session models.TerminalSession
+	if err := config.DB.First(&session, sessionID).Error; err == nil {
+		if path := a.SessionCastPath(&session); path != "" {
+			os.Remove(path)
+		}
+	}
+
+	// 删除操作记录和还原出的命令
 	config.DB.Where("session_id = ?", sessionID).Delete(&models.TerminalOperation{})
+	config.DB.Where("session_id = ?", sessionID).Delete(&models.TerminalCommand{})
 
 	// 删除会话
 	result := config.DB.Delete(&models.TerminalSession{}, sessionID)
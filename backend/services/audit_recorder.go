@@ -0,0 +1,287 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+)
+
+const (
+	recorderQueueSize         = 4096                   // 每个会话待写入输出记录的上限
+	recorderPriorityQueueSize = 512                    // 每个会话待写入输入等其他记录的上限
+	recorderBatchSize         = 200                    // 单次批量插入的最大条数
+	recorderBatchBytes        = 1024 * 1024            // 待写入内容超过该长度时立即写入，缩短单次写入的耗时
+	recorderFlushInterval     = 500 * time.Millisecond // 定时写入间隔
+	recorderCoalesceBytes     = 64 * 1024              // 合并后单条输出记录的最大长度
+	recorderCoalesceSpan      = 200 * time.Millisecond // 只合并该时间内的连续输出，保留回放所需的时间信息
+)
+
+// SessionRecorder 终端会话的审计记录器
+// 操作先进入有界队列，由后台协程合并连续的输出后批量写入，不阻塞终端数据转发。
+// 输入等其他记录使用单独的小队列，不会被大量输出挤占；任一队列满时记录直接丢弃，不阻塞调用方，
+// 丢弃的数量以 audit_dropped 记录写入会话，保证审计中可以看到缺失。
+// 同时以 asciicast v2 格式把输入、输出和终端大小变化按实际时间写入录像文件，录像不受队列丢弃影响；
+// 执行的命令从输入输出中还原后随批量写入保存到 TerminalCommand
+type SessionRecorder struct {
+	sessionID uint
+	userID    uint
+	hostID    uint
+	queue     chan models.TerminalOperation // 输出记录
+	priority  chan models.TerminalOperation // 输入、终端大小调整等其他记录
+	done      chan struct{}
+	cast      *castWriter
+	commands  *commandExtractor
+
+	mu     sync.RWMutex
+	closed bool
+
+	dropped      int64
+	droppedBytes int64
+}
+
+// NewSessionRecorder 为审计会话创建记录器，title 为录像标题，会话结束时必须调用 Close
+func (a *AuditService) NewSessionRecorder(session *models.TerminalSession, title string) *SessionRecorder {
+	r := &SessionRecorder{
+		sessionID: session.ID,
+		userID:    session.UserID,
+		hostID:    session.HostID,
+		commands:  newCommandExtractor(),
+		queue:     make(chan models.TerminalOperation, recorderQueueSize),
+		priority:  make(chan models.TerminalOperation, recorderPriorityQueueSize),
+		done:      make(chan struct{}),
+	}
+
+	// 录像文件创建失败时只记录数据库审计
+	castFile := session.SessionID + ".cast"
+	cast, err := newCastWriter(filepath.Join(RecordingDir(), castFile), session.StartTime, title)
+	if err != nil {
+		log.Printf("Failed to create recording for session %s: %v", session.SessionID, err)
+	} else {
+		r.cast = cast
+		config.DB.Model(session).Update("cast_file", castFile)
+	}
+
+	go r.run()
+	return r
+}
+
+// Record 记录一条终端操作，记录器为 nil（审计会话创建失败）或已关闭时忽略
+func (r *SessionRecorder) Record(opType, content string) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	r.enqueue(opType, content, 0, time.Now())
+}
+
+// RecordInput 记录某个用户的输入，共享会话中每个参与者的输入分别归属
+func (r *SessionRecorder) RecordInput(userID uint, content string) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+	r.enqueue("input", content, userID, time.Now())
+}
+
+// RecordResize 记录终端大小调整，录像中为 r 事件
+func (r *SessionRecorder) RecordResize(cols, rows int) {
+	if r == nil {
+		return
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return
+	}
+
+	now := time.Now()
+	if r.cast != nil {
+		r.cast.resize(now, cols, rows)
+	}
+	r.enqueue("resize", fmt.Sprintf("Terminal resized to %dx%d", cols, rows), 0, now)
+}
+
+// 写入录像并放入写库队列，userID 为输入的用户（其他记录为0），调用方持有读锁
+func (r *SessionRecorder) enqueue(opType, content string, userID uint, now time.Time) {
+	if r.cast != nil {
+		switch opType {
+		case "output":
+			r.cast.output(now, content)
+		case "input":
+			r.cast.input(now, content)
+		}
+	}
+	switch opType {
+	case "output":
+		r.commands.output(content)
+	case "input":
+		r.commands.input(now, userID, content)
+	}
+
+	op := models.TerminalOperation{
+		SessionID: r.sessionID,
+		Type:      opType,
+		Content:   content,
+		Timestamp: now,
+	}
+	if userID != 0 {
+		op.UserID = &userID
+	}
+	queue := r.queue
+	if opType != "output" {
+		queue = r.priority
+	}
+	select {
+	case queue <- op:
+		return
+	default:
+	}
+
+	atomic.AddInt64(&r.dropped, 1)
+	atomic.AddInt64(&r.droppedBytes, int64(len(content)))
+	auditDroppedOperations.inc(labelPairs("type", opType))
+}
+
+// Close 停止接收记录，写入队列中剩余的记录后返回
+func (r *SessionRecorder) Close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		<-r.done
+		return
+	}
+	r.closed = true
+	close(r.queue)
+	close(r.priority)
+	r.mu.Unlock()
+	<-r.done
+
+	if r.cast != nil {
+		size, err := r.cast.close()
+		if err != nil {
+			log.Printf("Failed to write recording for session %d: %v", r.sessionID, err)
+		}
+		config.DB.Model(&models.TerminalSession{}).Where("id = ?", r.sessionID).Update("cast_size", size)
+	}
+}
+
+func (r *SessionRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(recorderFlushInterval)
+	defer ticker.Stop()
+
+	var batch []models.TerminalOperation
+	var batchBytes int
+	flush := func() {
+		if n := atomic.SwapInt64(&r.dropped, 0); n > 0 {
+			bytes := atomic.SwapInt64(&r.droppedBytes, 0)
+			batch = append(batch, models.TerminalOperation{
+				SessionID: r.sessionID,
+				Type:      "audit_dropped",
+				Content:   fmt.Sprintf("审计队列已满，丢弃了%d条记录（%d字节）", n, bytes),
+				Timestamp: time.Now(),
+			})
+		}
+		if commands := r.commands.take(); len(commands) > 0 {
+			for i := range commands {
+				commands[i].SessionID = r.sessionID
+				if commands[i].UserID == 0 {
+					commands[i].UserID = r.userID
+				}
+				commands[i].HostID = r.hostID
+			}
+			if err := config.DB.CreateInBatches(commands, recorderBatchSize).Error; err != nil {
+				log.Printf("Failed to write %d commands for session %d: %v", len(commands), r.sessionID, err)
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+		if err := config.DB.CreateInBatches(batch, recorderBatchSize).Error; err != nil {
+			log.Printf("Failed to write %d audit operations for session %d: %v", len(batch), r.sessionID, err)
+		}
+		batch = nil
+		batchBytes = 0
+	}
+
+	add := func(op models.TerminalOperation) {
+		batch = coalesceOperation(batch, op)
+		batchBytes += len(op.Content)
+		if len(batch) >= recorderBatchSize || batchBytes >= recorderBatchBytes {
+			flush()
+		}
+	}
+	// 处理输出前先取出已在队列中的其他记录，尽量保持两个队列之间的先后顺序
+	drainPriority := func() {
+		for {
+			select {
+			case op, ok := <-r.priority:
+				if !ok {
+					return
+				}
+				add(op)
+			default:
+				return
+			}
+		}
+	}
+
+	queue, priority := r.queue, r.priority
+	for queue != nil || priority != nil {
+		select {
+		case op, ok := <-priority:
+			if !ok {
+				priority = nil
+				continue
+			}
+			add(op)
+		case op, ok := <-queue:
+			if !ok {
+				queue = nil
+				continue
+			}
+			if priority != nil {
+				drainPriority()
+			}
+			add(op)
+		case <-ticker.C:
+			flush()
+			if r.cast != nil {
+				r.cast.flush()
+			}
+		}
+	}
+	flush()
+}
+
+// 连续的输出在时间和长度限制内合并为一条，时间戳取第一段输出的时间
+func coalesceOperation(batch []models.TerminalOperation, op models.TerminalOperation) []models.TerminalOperation {
+	if op.Type == "output" && len(batch) > 0 {
+		last := &batch[len(batch)-1]
+		if last.Type == "output" &&
+			len(last.Content)+len(op.Content) <= recorderCoalesceBytes &&
+			op.Timestamp.Sub(last.Timestamp) <= recorderCoalesceSpan {
+			last.Content += op.Content
+			return batch
+		}
+	}
+	return append(batch, op)
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"gorm.io/gorm"
+)
+
+// 操作内容的检索方式
+const (
+	operationSearchFTS5     = "fts5"     // SQLite FTS5 trigram 索引
+	operationSearchFulltext = "fulltext" // MySQL FULLTEXT ngram 索引
+	operationSearchLike     = "like"     // 没有可用的全文索引时逐行匹配
+)
+
+// 搜索结果摘要中匹配内容前后保留的字符数
+const operationSnippetContext = 60
+
+// 摘要中去掉终端控制序列
+var terminalControlPattern = regexp.MustCompile(`\x1b\[[0-9;?<>=]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[()][0-9A-Za-z]|\x1b[=>78]|[\x00-\x08\x0b-\x1f\x7f]`)
+
+var operationSearchMode = operationSearchLike
+
+// InitOperationSearch 为终端操作内容建立全文索引，需在数据库迁移后调用。
+// SQLite 使用 FTS5（需以 -tags sqlite_fts5 编译）外部内容表并由触发器同步，MySQL 使用 ngram 解析器的 FULLTEXT 索引；
+// 创建失败时退回逐行匹配
+func InitOperationSearch() {
+	var err error
+	switch config.DB.Dialector.Name() {
+	case "sqlite":
+		err = initOperationFTS5(config.DB)
+		if err == nil {
+			operationSearchMode = operationSearchFTS5
+		}
+	case "mysql":
+		err = initOperationFulltext(config.DB)
+		if err == nil {
+			operationSearchMode = operationSearchFulltext
+		}
+	}
+	if err != nil {
+		log.Printf("Full-text index for terminal operations unavailable, falling back to LIKE search: %v", err)
+	}
+}
+
+func initOperationFTS5(db *gorm.DB) error {
+	var enabled int
+	db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&enabled)
+	if enabled == 0 {
+		return errors.New("SQLite 未启用 FTS5，需以 -tags sqlite_fts5 编译")
+	}
+
+	var exists int64
+	db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'terminal_operations_fts'").Scan(&exists)
+
+	// trigram 分词支持任意子串（含中文）且不区分大小写，适合搜索命令和输出片段
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS terminal_operations_fts USING fts5(content, content='terminal_operations', content_rowid='id', tokenize='trigram')`,
+		`CREATE TRIGGER IF NOT EXISTS terminal_operations_fts_insert AFTER INSERT ON terminal_operations BEGIN
+			INSERT INTO terminal_operations_fts(rowid, content) VALUES (new.id, new.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS terminal_operations_fts_delete AFTER DELETE ON terminal_operations BEGIN
+			INSERT INTO terminal_operations_fts(terminal_operations_fts, rowid, content) VALUES ('delete', old.id, old.content);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS terminal_operations_fts_update AFTER UPDATE OF content ON terminal_operations BEGIN
+			INSERT INTO terminal_operations_fts(terminal_operations_fts, rowid, content) VALUES ('delete', old.id, old.content);
+			INSERT INTO terminal_operations_fts(rowid, content) VALUES (new.id, new.content);
+		END`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// 首次创建时为已有记录建立索引
+	if exists == 0 {
+		log.Println("Building full-text index for terminal operations...")
+		if err := db.Exec("INSERT INTO terminal_operations_fts(terminal_operations_fts) VALUES ('rebuild')").Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func initOperationFulltext(db *gorm.DB) error {
+	var exists int64
+	db.Raw(`SELECT COUNT(*) FROM information_schema.statistics
+		WHERE table_schema = DATABASE() AND table_name = 'terminal_operations' AND index_name = 'idx_terminal_operations_content'`).Scan(&exists)
+	if exists > 0 {
+		return nil
+	}
+
+	// ngram 解析器按字符切分，可以匹配命令片段和中文
+	log.Println("Building full-text index for terminal operations...")
+	return db.Exec("ALTER TABLE terminal_operations ADD FULLTEXT INDEX idx_terminal_operations_content (content) WITH PARSER ngram").Error
+}
+
+// 按检索方式添加内容匹配条件，全文索引不支持的过短关键字退回逐行匹配
+func matchOperationContent(query *gorm.DB, keyword string) *gorm.DB {
+	phrase := `"` + strings.ReplaceAll(keyword, `"`, `""`) + `"`
+	length := utf8.RuneCountInString(keyword)
+
+	switch {
+	case operationSearchMode == operationSearchFTS5 && length >= 3:
+		return query.Where("terminal_operations.id IN (SELECT rowid FROM terminal_operations_fts WHERE terminal_operations_fts MATCH ?)", phrase)
+	case operationSearchMode == operationSearchFulltext && length >= 2 && !strings.Contains(keyword, `"`):
+		// 短语匹配可能因分词产生误报，再用 LIKE 确认
+		return query.Where("MATCH (terminal_operations.content) AGAINST (? IN BOOLEAN MODE) AND terminal_operations.content LIKE ?",
+			`"`+keyword+`"`, "%"+keyword+"%")
+	}
+	return query.Where("terminal_operations.content LIKE ?", "%"+keyword+"%")
+}
+
+// SearchOperations 在所有会话的终端输入输出和还原出的命令中搜索关键字，opType 为 input、output 或 command 时只搜索该类记录。
+// 输入按键逐条记录，input 只能匹配一次粘贴的内容，查找执行过的命令应搜索 command。
+// bySession 为 true 时每个会话只返回第一处匹配，用于查找出现过某内容的会话
+func (a *AuditService) SearchOperations(req models.AuditQueryRequest, keyword, opType string, bySession bool) ([]models.OperationSearchResult, int64, error) {
+	var total int64
+
+	// 各来源只取 id、会话、类型和时间，合并后统一排序分页
+	var sources []interface{}
+	if opType != "command" {
+		query := config.DB.Model(&models.TerminalOperation{}).
+			Select("terminal_operations.id AS id, terminal_operations.session_id AS session_id, terminal_operations.type AS type, terminal_operations.timestamp AS timestamp").
+			Joins("JOIN terminal_sessions ON terminal_sessions.id = terminal_operations.session_id AND terminal_sessions.deleted_at IS NULL")
+		query = filterSearchSessions(query, req, "terminal_operations.timestamp")
+		if opType != "" {
+			query = query.Where("terminal_operations.type = ?", opType)
+		} else {
+			query = query.Where("terminal_operations.type IN ?", []string{"input", "output"})
+		}
+		sources = append(sources, matchOperationContent(query, keyword))
+	}
+	if opType == "" || opType == "command" {
+		query := config.DB.Model(&models.TerminalCommand{}).
+			Select("terminal_commands.id AS id, terminal_commands.session_id AS session_id, 'command' AS type, terminal_commands.timestamp AS timestamp").
+			Joins("JOIN terminal_sessions ON terminal_sessions.id = terminal_commands.session_id AND terminal_sessions.deleted_at IS NULL")
+		query = filterSearchSessions(query, req, "terminal_commands.timestamp")
+		sources = append(sources, query.Where("terminal_commands.command LIKE ?", "%"+keyword+"%"))
+	}
+	matches := sources[0]
+	if len(sources) > 1 {
+		matches = config.DB.Raw("? UNION ALL ?", sources...)
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.PageSize <= 0 {
+		req.PageSize = 20
+	}
+
+	// 只扫描 id 和类型，时间等字段从原表加载（合并后的时间列在 SQLite 中会丢失类型）
+	type searchMatch struct {
+		ID        uint
+		SessionID uint
+		Type      string
+	}
+	var page []searchMatch
+	if bySession {
+		firsts := config.DB.Table("(?) AS matches", matches).
+			Select("session_id, MIN(timestamp) AS first").
+			Group("session_id")
+		config.DB.Table("(?) AS firsts", firsts).Count(&total)
+
+		// 先按会话分页，再取各会话最早的匹配；同一时间有多处匹配时只保留一条
+		firstPage := firsts.Session(&gorm.Session{}).
+			Order("first DESC, session_id DESC").
+			Offset((req.Page - 1) * req.PageSize).
+			Limit(req.PageSize)
+		var rows []searchMatch
+		result := config.DB.Table("(?) AS matches", matches).
+			Select("matches.id, matches.session_id, matches.type").
+			Joins("JOIN (?) AS firsts ON firsts.session_id = matches.session_id AND firsts.first = matches.timestamp", firstPage).
+			Order("firsts.first DESC, matches.session_id DESC, matches.type, matches.id").
+			Scan(&rows)
+		if result.Error != nil {
+			return nil, 0, result.Error
+		}
+		seen := make(map[uint]bool, len(rows))
+		for _, row := range rows {
+			if !seen[row.SessionID] {
+				seen[row.SessionID] = true
+				page = append(page, row)
+			}
+		}
+	} else {
+		config.DB.Table("(?) AS matches", matches).Count(&total)
+
+		result := config.DB.Table("(?) AS matches", matches).
+			Select("id, session_id, type").
+			Order("timestamp DESC, id DESC").
+			Offset((req.Page - 1) * req.PageSize).
+			Limit(req.PageSize).
+			Scan(&page)
+		if result.Error != nil {
+			return nil, 0, result.Error
+		}
+	}
+
+	// 加载匹配的记录和涉及的会话
+	var operationIDs, commandIDs, sessionIDs []uint
+	for _, m := range page {
+		if m.Type == "command" {
+			commandIDs = append(commandIDs, m.ID)
+		} else {
+			operationIDs = append(operationIDs, m.ID)
+		}
+		sessionIDs = append(sessionIDs, m.SessionID)
+	}
+
+	operationMap := make(map[uint]*models.TerminalOperation, len(operationIDs))
+	if len(operationIDs) > 0 {
+		var operations []models.TerminalOperation
+		if err := config.DB.Where("id IN ?", operationIDs).Find(&operations).Error; err != nil {
+			return nil, 0, err
+		}
+		for i := range operations {
+			operationMap[operations[i].ID] = &operations[i]
+		}
+	}
+	commandMap := make(map[uint]*models.TerminalCommand, len(commandIDs))
+	if len(commandIDs) > 0 {
+		var commands []models.TerminalCommand
+		if err := config.DB.Where("id IN ?", commandIDs).Find(&commands).Error; err != nil {
+			return nil, 0, err
+		}
+		for i := range commands {
+			commandMap[commands[i].ID] = &commands[i]
+		}
+	}
+
+	var sessions []models.TerminalSession
+	if len(sessionIDs) > 0 {
+		if err := config.DB.Preload("User").Preload("Host").Where("id IN ?", sessionIDs).Find(&sessions).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+	sessionMap := make(map[uint]*models.TerminalSession, len(sessions))
+	for i := range sessions {
+		sessionMap[sessions[i].ID] = &sessions[i]
+	}
+
+	results := make([]models.OperationSearchResult, 0, len(page))
+	for _, m := range page {
+		result := models.OperationSearchResult{
+			SessionID: m.SessionID,
+			Session:   sessionMap[m.SessionID],
+			Type:      m.Type,
+		}
+		if m.Type == "command" {
+			command, ok := commandMap[m.ID]
+			if !ok {
+				continue
+			}
+			result.CommandID = command.ID
+			result.Timestamp = command.Timestamp
+			result.WorkingDir = command.WorkingDir
+			result.Snippet = operationSnippet(command.Command, keyword)
+		} else {
+			op, ok := operationMap[m.ID]
+			if !ok {
+				continue
+			}
+			result.OperationID = op.ID
+			result.Timestamp = op.Timestamp
+			result.Snippet = operationSnippet(op.Content, keyword)
+		}
+		if result.Session != nil {
+			result.Offset = max(result.Timestamp.Sub(result.Session.StartTime).Seconds(), 0)
+		}
+		results = append(results, result)
+	}
+
+	return results, total, nil
+}
+
+// 按会话发起人、主机和时间范围过滤搜索结果
+func filterSearchSessions(query *gorm.DB, req models.AuditQueryRequest, timestampColumn string) *gorm.DB {
+	if req.UserID != nil {
+		query = query.Where("terminal_sessions.user_id = ?", *req.UserID)
+	}
+	if req.HostID != nil {
+		query = query.Where("terminal_sessions.host_id = ?", *req.HostID)
+	}
+	if req.StartTime != nil {
+		query = query.Where(timestampColumn+" >= ?", *req.StartTime)
+	}
+	if req.EndTime != nil {
+		query = query.Where(timestampColumn+" <= ?", *req.EndTime)
+	}
+	return query
+}
+
+// 取匹配位置前后的内容作为摘要，去掉终端控制序列
+func operationSnippet(content, keyword string) string {
+	text := terminalControlPattern.ReplaceAllString(content, "")
+	runes := []rune(text)
+
+	start, end := 0, len(runes)
+	if i := strings.Index(strings.ToLower(text), strings.ToLower(keyword)); i >= 0 && len(strings.ToLower(text)) == len(text) {
+		pos := utf8.RuneCountInString(text[:i])
+		start = max(pos-operationSnippetContext, 0)
+		end = min(pos+utf8.RuneCountInString(keyword)+operationSnippetContext, len(runes))
+	} else if end > 2*operationSnippetContext {
+		end = 2 * operationSnippetContext
+	}
+
+	snippet := string(runes[start:end])
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(runes) {
+		snippet += "…"
+	}
+	return strings.TrimSpace(snippet)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"time"
 
 	"host-manager/config"
 	"host-manager/models"
@@ -11,6 +12,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// token有效期
+const tokenTTL = 7 * 24 * time.Hour
+
 type AuthService struct{}
 
 func NewAuthService() *AuthService {
@@ -55,9 +59,44 @@ func (a *AuthService) Login(username, password string) (*models.User, string, er
 		return nil, "", errors.New("生成token失败")
 	}
 
+	record := models.UserToken{
+		Token:     token,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(tokenTTL),
+	}
+	if err := config.DB.Create(&record).Error; err != nil {
+		return nil, "", errors.New("保存token失败")
+	}
+
 	return &user, token, nil
 }
 
+// 校验token并返回对应的用户
+func (a *AuthService) ValidateToken(token string) (*models.User, error) {
+	if token == "" {
+		return nil, errors.New("未提供认证token")
+	}
+
+	var record models.UserToken
+	result := config.DB.Where("token = ? AND expires_at > ?", token, time.Now()).First(&record)
+	if result.Error != nil {
+		return nil, errors.New("无效的token")
+	}
+
+	var user models.User
+	result = config.DB.Where("id = ? AND status = ?", record.UserID, "active").First(&user)
+	if result.Error != nil {
+		return nil, errors.New("用户不存在或已被禁用")
+	}
+
+	return &user, nil
+}
+
+// 注销token
+func (a *AuthService) RevokeToken(token string) error {
+	return config.DB.Where("token = ?", token).Delete(&models.UserToken{}).Error
+}
+
 // 创建用户
 func (a *AuthService) CreateUser(username, password, email string) (*models.User, error) {
 	hashedPassword, err := a.HashPassword(password)
@@ -100,6 +139,7 @@ func (a *AuthService) DeleteUser(userID string) error {
 	if result.RowsAffected == 0 {
 		return errors.New("用户不存在")
 	}
+	config.DB.Where("user_id = ?", userID).Delete(&models.UserToken{})
 	return nil
 }
 
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"host-manager/config"
+	"host-manager/models"
+)
+
+// 终端初始大小，与请求PTY时一致，前端连接后会立即发送实际大小
+const (
+	castDefaultWidth  = 80
+	castDefaultHeight = 24
+)
+
+// asciicast v2 文件头
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// RecordingDir 会话录像（.cast 文件）的存放目录，由 RECORDING_DIR 指定
+func RecordingDir() string {
+	if dir := os.Getenv("RECORDING_DIR"); dir != "" {
+		return dir
+	}
+	return "recordings"
+}
+
+// 以 asciicast v2 格式写入会话录像，每行一个事件 [秒数, 类型, 数据]
+type castWriter struct {
+	mu      sync.Mutex
+	file    *os.File
+	buf     *bufio.Writer
+	start   time.Time
+	header  *castHeader // 第一个事件前的大小调整直接写入文件头，之后置为 nil
+	partial []byte      // 输出末尾不完整的 UTF-8 字节，与下一段输出拼接后再写入
+	err     error
+}
+
+func newCastWriter(path string, start time.Time, title string) (*castWriter, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	return &castWriter{
+		file:  file,
+		buf:   bufio.NewWriterSize(file, 64*1024),
+		start: start,
+		header: &castHeader{
+			Version:   2,
+			Width:     castDefaultWidth,
+			Height:    castDefaultHeight,
+			Timestamp: start.Unix(),
+			Title:     title,
+			Env:       map[string]string{"TERM": "xterm"},
+		},
+	}, nil
+}
+
+func (w *castWriter) writeHeader() {
+	if w.header == nil {
+		return
+	}
+	header, _ := json.Marshal(w.header)
+	w.buf.Write(header)
+	w.buf.WriteByte('\n')
+	w.header = nil
+}
+
+func (w *castWriter) event(t time.Time, code, data string) {
+	w.writeHeader()
+	line, _ := json.Marshal([]interface{}{castTime(t.Sub(w.start)), code, data})
+	w.buf.Write(line)
+	w.buf.WriteByte('\n')
+}
+
+// 写入输出事件，被截断的多字节字符留到下一段输出
+func (w *castWriter) output(t time.Time, data string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	chunk := append(w.partial, data...)
+	cut := len(chunk)
+	// 最多回退3个字节寻找未完整的 UTF-8 序列起点
+	for i := len(chunk) - 1; i >= 0 && i >= len(chunk)-3; i-- {
+		if utf8.RuneStart(chunk[i]) {
+			if !utf8.FullRune(chunk[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	w.partial = append([]byte(nil), chunk[cut:]...)
+	if cut > 0 {
+		w.event(t, "o", string(chunk[:cut]))
+	}
+}
+
+func (w *castWriter) input(t time.Time, data string) {
+	w.mu.Lock()
+	w.event(t, "i", data)
+	w.mu.Unlock()
+}
+
+func (w *castWriter) resize(t time.Time, cols, rows int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.header != nil {
+		w.header.Width, w.header.Height = cols, rows
+		return
+	}
+	w.event(t, "r", fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// 将缓冲写入文件，录像在会话进行中也可以下载
+func (w *castWriter) flush() {
+	w.mu.Lock()
+	w.writeHeader()
+	if err := w.buf.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	w.mu.Unlock()
+}
+
+// 关闭文件，返回录像大小
+func (w *castWriter) close() (int64, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.partial) > 0 {
+		w.event(time.Now(), "o", string(w.partial))
+		w.partial = nil
+	}
+	w.writeHeader()
+	err := w.buf.Flush()
+	if w.err != nil {
+		err = w.err
+	}
+	info, statErr := w.file.Stat()
+	if closeErr := w.file.Close(); err == nil {
+		err = closeErr
+	}
+	if statErr != nil {
+		return 0, err
+	}
+	return info.Size(), err
+}
+
+// 秒数保留到微秒
+func castTime(d time.Duration) json.Number {
+	return json.Number(strconv.FormatFloat(d.Seconds(), 'f', 6, 64))
+}
+
+// SessionCastPath 返回会话录像文件的路径，会话没有录像文件时返回空
+func (a *AuditService) SessionCastPath(session *models.TerminalSession) string {
+	if session.CastFile == "" {
+		return ""
+	}
+	path := filepath.Join(RecordingDir(), session.CastFile)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+var resizeOperationPattern = regexp.MustCompile(`(\d+)x(\d+)`)
+
+// BuildSessionCast 由数据库中的操作记录生成 asciicast，用于没有录像文件的旧会话；
+// 连续的输出已合并，时间精度不如录像文件
+func (a *AuditService) BuildSessionCast(session *models.TerminalSession) ([]byte, error) {
+	var operations []models.TerminalOperation
+	if err := config.DB.Where("session_id = ?", session.ID).Order("timestamp ASC, id ASC").Find(&operations).Error; err != nil {
+		return nil, err
+	}
+
+	header := castHeader{
+		Version:   2,
+		Width:     castDefaultWidth,
+		Height:    castDefaultHeight,
+		Timestamp: session.StartTime.Unix(),
+		Env:       map[string]string{"TERM": "xterm"},
+	}
+	var events []byte
+	event := func(op models.TerminalOperation, code, data string) {
+		offset := op.Timestamp.Sub(session.StartTime)
+		if offset < 0 {
+			offset = 0
+		}
+		line, _ := json.Marshal([]interface{}{castTime(offset), code, data})
+		events = append(append(events, line...), '\n')
+	}
+	for _, op := range operations {
+		switch op.Type {
+		case "output":
+			event(op, "o", op.Content)
+		case "input":
+			event(op, "i", op.Content)
+		case "resize":
+			m := resizeOperationPattern.FindStringSubmatch(op.Content)
+			if m == nil {
+				continue
+			}
+			// 第一个事件之前的大小调整作为初始大小
+			if len(events) == 0 {
+				header.Width, _ = strconv.Atoi(m[1])
+				header.Height, _ = strconv.Atoi(m[2])
+				continue
+			}
+			event(op, "r", m[1]+"x"+m[2])
+		}
+	}
+
+	out, _ := json.Marshal(header)
+	out = append(out, '\n')
+	return append(out, events...), nil
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"gorm.io/gorm"
+)
+
+// 单条命令的最大长度，超出部分截断
+const maxCommandLength = 4096
+
+// 当前行（提示符加命令）的最大长度，控制序列中的列号和重复次数都限制在此范围内，
+// 避免输出中恶意的超大参数导致分配过大的内存
+const maxLineLength = maxCommandLength * 2
+
+// 常见提示符中的工作目录：Debian/Ubuntu 的 user@host:dir$ 和 RHEL 的 [user@host dir]$
+var (
+	promptDirPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`[\w.-]+@[\w.-]+:\s*([^\s$#]+)\s*[$#]\s*$`),
+		regexp.MustCompile(`\[[\w.-]+@[\w.-]+ ([^\]]+)\][$#]\s*$`),
+	}
+	// 输入早于提示符显示（预先键入）时，按常见格式去掉行首的提示符
+	promptPattern = regexp.MustCompile(`^(\[[\w.-]+@[\w.-]+ [^\]]+\]|[\w.-]+@[\w.-]+:\s*\S*)\s*[$#]\s`)
+	// xterm 标题 "user@host: dir"
+	titleDirPattern = regexp.MustCompile(`^[\w.-]+@[\w.-]+:\s*(\S.*)$`)
+)
+
+// 命令还原器：在一行终端内容上重放输出（回显、退格、光标移动、清除等控制序列），
+// 用户按下回车后，在 shell 回显换行时取该行去掉提示符后的内容作为命令。
+// 因为取的是 shell 实际显示的内容，Tab 补全、历史命令和行编辑都能正确还原，
+// 关闭回显的输入（如密码）不会被记录；全屏程序（vim、less 等备用屏幕）中的按键不视为命令
+type commandExtractor struct {
+	mu sync.Mutex
+
+	line   []rune // 当前行内容
+	cursor int
+
+	// 输出的解析状态
+	partial []byte // 被截断的 UTF-8 字节
+	escape  int    // 0 普通，1 ESC 后，2 CSI，3 OSC，4 OSC 中的 ESC，5 字符集选择
+	params  []byte // CSI 参数或 OSC 内容
+	dropped bool   // 控制序列超过长度上限，已丢弃，等待其结束
+
+	altScreen bool
+	prompt    string // 本行开始输入时已显示的内容，视为提示符
+	hasPrompt bool
+	entered   bool // 已按下回车、尚未回显换行
+	enterAt   time.Time
+	enterBy   uint   // 按下回车的用户，共享会话中命令归属于该用户
+	osc7Dir   string // shell 通过 OSC 7 上报的工作目录
+	titleDir  string // 终端标题中的工作目录
+
+	commands []models.TerminalCommand
+}
+
+func newCommandExtractor() *commandExtractor {
+	return &commandExtractor{}
+}
+
+// 处理用户输入：本行第一次输入时记下提示符，回车等待回显换行后再取命令；userID 为0时命令归属会话发起人
+func (e *commandExtractor) input(t time.Time, userID uint, data string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.altScreen || data == "" {
+		return
+	}
+	if !e.hasPrompt {
+		e.prompt = string(e.line[:min(e.cursor, len(e.line))])
+		e.hasPrompt = true
+	}
+	// 一次粘贴多行时无法区分后续行是命令还是前一条命令的输出，只取第一行
+	if !e.entered && strings.ContainsAny(data, "\r\n") {
+		e.entered, e.enterAt, e.enterBy = true, t, userID
+	}
+}
+
+// 处理终端输出
+func (e *commandExtractor) output(data string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	buf := append(e.partial, data...)
+	e.partial = nil
+	for len(buf) > 0 {
+		r, size := utf8.DecodeRune(buf)
+		if r == utf8.RuneError && size <= 1 && !utf8.FullRune(buf) {
+			e.partial = append([]byte(nil), buf...)
+			return
+		}
+		buf = buf[size:]
+		e.feed(r)
+	}
+}
+
+func (e *commandExtractor) feed(r rune) {
+	switch e.escape {
+	case 1:
+		switch r {
+		case '[':
+			e.escape, e.params, e.dropped = 2, e.params[:0], false
+		case ']':
+			e.escape, e.params, e.dropped = 3, e.params[:0], false
+		case '(', ')', '*', '+':
+			e.escape = 5
+		default:
+			e.escape = 0
+		}
+		return
+	case 2:
+		if r >= 0x40 && r <= 0x7e {
+			e.escape = 0
+			if !e.dropped {
+				e.csi(r, string(e.params))
+			}
+			return
+		}
+		e.appendParam(r)
+		return
+	case 3:
+		switch r {
+		case 0x07:
+			e.escape = 0
+			if !e.dropped {
+				e.osc(string(e.params))
+			}
+		case 0x1b:
+			e.escape = 4
+		default:
+			e.appendParam(r)
+		}
+		return
+	case 4:
+		e.escape = 0
+		if !e.dropped {
+			e.osc(string(e.params))
+		}
+		return
+	case 5:
+		e.escape = 0
+		return
+	}
+
+	switch r {
+	case 0x1b:
+		e.escape = 1
+	case '\r':
+		e.cursor = 0
+	case '\n':
+		e.newline()
+	case '\b':
+		if e.cursor > 0 {
+			e.cursor--
+		}
+	case '\t':
+		e.put(' ')
+		for e.cursor%8 != 0 && e.cursor < maxLineLength {
+			e.put(' ')
+		}
+	default:
+		if r >= 0x20 && r != 0x7f {
+			e.put(r)
+		}
+	}
+}
+
+// 记录控制序列的参数，超过长度上限的序列整个丢弃，避免没有结束符的序列无限占用内存
+func (e *commandExtractor) appendParam(r rune) {
+	if e.dropped {
+		return
+	}
+	if len(e.params) >= maxLineLength {
+		e.params, e.dropped = e.params[:0], true
+		return
+	}
+	e.params = utf8.AppendRune(e.params, r)
+}
+
+// 在光标处写入字符（覆盖）
+func (e *commandExtractor) put(r rune) {
+	if e.altScreen || e.cursor >= maxLineLength {
+		return
+	}
+	for len(e.line) < e.cursor {
+		e.line = append(e.line, ' ')
+	}
+	if e.cursor < len(e.line) {
+		e.line[e.cursor] = r
+	} else {
+		e.line = append(e.line, r)
+	}
+	e.cursor++
+}
+
+func (e *commandExtractor) csi(final rune, params string) {
+	private := strings.HasPrefix(params, "?")
+	n := 1
+	var args []int
+	for _, p := range strings.Split(strings.TrimLeft(params, "?>="), ";") {
+		// 溢出时 Atoi 返回最大值，统一限制在行长度内
+		v, _ := strconv.Atoi(p)
+		args = append(args, min(max(v, 0), maxLineLength))
+	}
+	if len(args) > 0 && args[0] > 0 {
+		n = args[0]
+	}
+
+	switch final {
+	case 'h', 'l':
+		if private {
+			for _, mode := range args {
+				if mode == 1049 || mode == 1047 || mode == 47 {
+					e.altScreen = final == 'h'
+					e.line, e.cursor = e.line[:0], 0
+					e.hasPrompt, e.prompt = false, ""
+					e.entered = false
+				}
+			}
+		}
+	case 'C':
+		e.cursor = min(e.cursor+n, maxLineLength)
+	case 'D':
+		e.cursor = max(e.cursor-n, 0)
+	case 'G':
+		e.cursor = n - 1
+	case 'H', 'f':
+		// 只关心列，行的变化无法在单行模型中表示
+		e.cursor = 0
+		if len(args) > 1 && args[1] > 0 {
+			e.cursor = args[1] - 1
+		}
+	case 'K':
+		switch args[0] {
+		case 0:
+			if e.cursor < len(e.line) {
+				e.line = e.line[:e.cursor]
+			}
+		case 1:
+			for i := 0; i <= e.cursor && i < len(e.line); i++ {
+				e.line[i] = ' '
+			}
+		case 2:
+			e.line = e.line[:0]
+		}
+	case 'J':
+		if args[0] == 0 && e.cursor < len(e.line) {
+			e.line = e.line[:e.cursor]
+		} else if args[0] >= 2 {
+			e.line = e.line[:0]
+		}
+	case 'P':
+		if e.cursor < len(e.line) {
+			end := min(e.cursor+n, len(e.line))
+			e.line = append(e.line[:e.cursor], e.line[end:]...)
+		}
+	case '@':
+		if e.cursor < len(e.line) {
+			spaces := []rune(strings.Repeat(" ", n))
+			e.line = append(e.line[:e.cursor], append(spaces, e.line[e.cursor:]...)...)
+			e.line = e.line[:min(len(e.line), maxLineLength)]
+		}
+	case 'X':
+		for i := e.cursor; i < e.cursor+n && i < len(e.line); i++ {
+			e.line[i] = ' '
+		}
+	}
+}
+
+// OSC 7 上报工作目录，OSC 0/2 设置终端标题
+func (e *commandExtractor) osc(content string) {
+	code, value, ok := strings.Cut(content, ";")
+	if !ok {
+		return
+	}
+	switch code {
+	case "7":
+		if u, err := url.Parse(value); err == nil && u.Path != "" {
+			e.osc7Dir = u.Path
+		}
+	case "0", "2":
+		if m := titleDirPattern.FindStringSubmatch(value); m != nil {
+			e.titleDir = strings.TrimSpace(m[1])
+		}
+	}
+}
+
+// 输出换行：有等待回显的回车时，当前行去掉提示符即为执行的命令
+func (e *commandExtractor) newline() {
+	if e.entered && !e.altScreen {
+		e.entered = false
+
+		text := string(e.line)
+		command := text
+		if e.prompt != "" && strings.HasPrefix(text, e.prompt) {
+			command = text[len(e.prompt):]
+		} else if loc := promptPattern.FindStringIndex(text); loc != nil {
+			if e.prompt == "" {
+				e.prompt = text[:loc[1]]
+			}
+			command = text[loc[1]:]
+		}
+		command = strings.TrimSpace(command)
+		if command != "" {
+			if len(command) > maxCommandLength {
+				// 在字符边界截断，截出无效的 UTF-8 会导致 MySQL（utf8mb4）拒绝整批写入
+				n := maxCommandLength
+				for n > 0 && !utf8.RuneStart(command[n]) {
+					n--
+				}
+				command = command[:n]
+			}
+			e.commands = append(e.commands, models.TerminalCommand{
+				UserID:     e.enterBy,
+				Command:    command,
+				WorkingDir: e.workingDir(),
+				Timestamp:  e.enterAt,
+			})
+		}
+		// 支持的 shell 每次显示提示符都会重新上报，避免嵌套的 shell（如 sudo -i）沿用外层的目录
+		e.osc7Dir, e.titleDir = "", ""
+	}
+	e.line, e.cursor = e.line[:0], 0
+	e.hasPrompt, e.prompt = false, ""
+}
+
+// 按可信程度依次取 OSC 7、提示符和终端标题中的工作目录
+func (e *commandExtractor) workingDir() string {
+	if e.osc7Dir != "" {
+		return e.osc7Dir
+	}
+	if e.prompt != "" {
+		for _, pattern := range promptDirPatterns {
+			if m := pattern.FindStringSubmatch(e.prompt); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return e.titleDir
+}
+
+// 取出已还原的命令
+func (e *commandExtractor) take() []models.TerminalCommand {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	commands := e.commands
+	e.commands = nil
+	return commands
+}
+
+// RebuildSessionCommands 从会话录像重新还原命令，替换已有的记录，返回还原出的命令数。
+// 用于录像早于命令还原功能的会话，没有录像文件时由操作记录生成；
+// 录像中没有输入者信息，共享会话中还原出的命令均归属会话发起人
+func (a *AuditService) RebuildSessionCommands(session *models.TerminalSession) (int, error) {
+	playback, err := a.OpenPlayback(session)
+	if err != nil {
+		return 0, err
+	}
+	defer playback.Close()
+	if err := playback.rewind(); err != nil {
+		return 0, err
+	}
+
+	// 录像中的事件时间相对于会话开始时间
+	start := session.StartTime
+	e := newCommandExtractor()
+	for {
+		ev, err := playback.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+		switch ev.Code {
+		case "o":
+			e.output(ev.Data)
+		case "i":
+			e.input(start.Add(time.Duration(ev.Time*float64(time.Second))), 0, ev.Data)
+		}
+	}
+
+	commands := e.take()
+	for i := range commands {
+		commands[i].SessionID = session.ID
+		commands[i].UserID = session.UserID
+		commands[i].HostID = session.HostID
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", session.ID).Delete(&models.TerminalCommand{}).Error; err != nil {
+			return err
+		}
+		if len(commands) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(commands, recorderBatchSize).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(commands), nil
+}
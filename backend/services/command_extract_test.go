@@ -0,0 +1,168 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+const testPrompt = "root@web-1:~# "
+
+// 模拟一次交互：显示提示符，用户键入并回车，shell 回显输入和换行
+func runCommand(e *commandExtractor, echo string) {
+	e.input(time.Now(), 0, "x")
+	e.output(echo)
+	e.input(time.Now(), 0, "\r")
+	e.output("\r\n")
+}
+
+func takeCommands(e *commandExtractor) []string {
+	var commands []string
+	for _, c := range e.take() {
+		commands = append(commands, c.Command)
+	}
+	return commands
+}
+
+func TestCommandExtractorLineEditing(t *testing.T) {
+	cases := []struct {
+		name string
+		echo string
+		want string
+	}{
+		{"plain", "ls -la", "ls -la"},
+		{"backspace", "lss\b \b", "ls"},
+		{"cursor left and insert", "rm -f x\x1b[3D\x1b[1@r", "rm -rf x"},
+		{"delete chars", "echo hello\x1b[5D\x1b[2P", "echo llo"},
+		{"tab completion", "cat /etc/hos\atts", "cat /etc/hostts"},
+		{"erase line and retype", "wrong\r\x1b[K" + testPrompt + "uptime", "uptime"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newCommandExtractor()
+			e.output(testPrompt)
+			runCommand(e, tc.echo)
+
+			got := takeCommands(e)
+			if len(got) != 1 || got[0] != tc.want {
+				t.Fatalf("commands = %q, want [%q]", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCommandExtractorWorkingDir(t *testing.T) {
+	e := newCommandExtractor()
+	e.output("\x1b]7;file://web-1/srv/app\x07" + testPrompt)
+	runCommand(e, "make")
+
+	commands := e.take()
+	if len(commands) != 1 || commands[0].WorkingDir != "/srv/app" {
+		t.Fatalf("commands = %+v, want working dir /srv/app", commands)
+	}
+}
+
+func TestCommandExtractorAltScreen(t *testing.T) {
+	e := newCommandExtractor()
+	e.output(testPrompt + "vim a.txt\r\n\x1b[?1049h")
+	e.input(time.Now(), 0, "ihello\r")
+	e.output("hello\r\n")
+	e.output("\x1b[?1049l")
+
+	if got := takeCommands(e); len(got) != 0 {
+		t.Fatalf("commands = %q, want none from the alternate screen", got)
+	}
+}
+
+// 输出中的超大控制序列参数不能导致 panic 或分配超长的行
+func TestCommandExtractorHostileSequences(t *testing.T) {
+	cases := []struct {
+		name   string
+		output string
+	}{
+		{"insert overflowing int", "abc\r\x1b[99999999999999999999999@"},
+		{"insert huge count", "abc\r\x1b[50000000@"},
+		{"cursor forward huge", "\x1b[50000000Cx"},
+		{"cursor forward overflowing int", "\x1b[99999999999999999999999Cx"},
+		{"column huge", "\x1b[50000000Gx"},
+		{"position huge", "\x1b[1;50000000Hx"},
+		{"negative count", "abc\x1b[-5D\x1b[-5@x"},
+		{"repeated forward", strings.Repeat("\x1b[1000C", 1000) + "x"},
+		{"repeated insert", "abc\r" + strings.Repeat("\x1b[5000@", 1000)},
+		{"tab past cap", "\x1b[50000000C\t\tx"},
+		{"erase huge", "abc\x1b[50000000X\x1b[50000000P"},
+		{"unterminated osc", "\x1b]0;" + strings.Repeat("a", 5*maxLineLength)},
+		{"unterminated csi", "\x1b[" + strings.Repeat("1;", 5*maxLineLength)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			e := newCommandExtractor()
+			e.output(testPrompt)
+			e.input(time.Now(), 0, "x")
+			e.output(tc.output)
+
+			if len(e.line) > maxLineLength {
+				t.Errorf("line length = %d, want at most %d", len(e.line), maxLineLength)
+			}
+			if e.cursor < 0 || e.cursor > maxLineLength {
+				t.Errorf("cursor = %d, want within [0, %d]", e.cursor, maxLineLength)
+			}
+			if len(e.params) > maxLineLength {
+				t.Errorf("escape sequence buffer = %d bytes, want at most %d", len(e.params), maxLineLength)
+			}
+
+			e.input(time.Now(), 0, "\r")
+			e.output("\r\n")
+			for _, c := range e.take() {
+				if len(c.Command) > maxCommandLength {
+					t.Errorf("command length = %d, want at most %d", len(c.Command), maxCommandLength)
+				}
+			}
+		})
+	}
+}
+
+// 超长的控制序列整个丢弃，结束后恢复正常解析
+func TestCommandExtractorOversizedSequenceDropped(t *testing.T) {
+	e := newCommandExtractor()
+	e.output("\x1b]7;file://web-1/" + strings.Repeat("a", 2*maxLineLength) + "\x07")
+	e.output("\x1b]7;file://web-1/srv\x07" + testPrompt)
+	runCommand(e, "ls")
+
+	commands := e.take()
+	if len(commands) != 1 || commands[0].Command != "ls" || commands[0].WorkingDir != "/srv" {
+		t.Fatalf("commands = %+v, want ls in /srv", commands)
+	}
+}
+
+func TestCommandExtractorLongCommandTruncated(t *testing.T) {
+	e := newCommandExtractor()
+	e.output(testPrompt)
+	runCommand(e, "echo "+strings.Repeat("a", 3*maxCommandLength))
+
+	got := takeCommands(e)
+	if len(got) != 1 || len(got[0]) != maxCommandLength || !strings.HasPrefix(got[0], "echo aaa") {
+		t.Fatalf("got %d commands, want one truncated to %d bytes", len(got), maxCommandLength)
+	}
+}
+
+func TestCommandExtractorTruncatesOnRuneBoundary(t *testing.T) {
+	e := newCommandExtractor()
+	e.output(testPrompt)
+	// "echo " 占5字节，之后每个汉字3字节，maxCommandLength 处落在字符中间
+	runCommand(e, "echo "+strings.Repeat("中", maxCommandLength))
+
+	got := takeCommands(e)
+	if len(got) != 1 {
+		t.Fatalf("got %d commands, want 1", len(got))
+	}
+	if !utf8.ValidString(got[0]) {
+		t.Errorf("truncated command is not valid UTF-8")
+	}
+	if len(got[0]) > maxCommandLength || len(got[0]) < maxCommandLength-utf8.UTFMax {
+		t.Errorf("command length = %d, want just under %d", len(got[0]), maxCommandLength)
+	}
+}
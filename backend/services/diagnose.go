@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 诊断各步骤的超时
+const diagnoseTimeout = 10 * time.Second
+
+// 记录诊断步骤的辅助结构
+type diagnosis struct {
+	result *models.HostDiagnostic
+}
+
+func (d *diagnosis) step(name string, start time.Time, detail string, err error) bool {
+	step := models.DiagnosticStep{
+		Name:       name,
+		Status:     "ok",
+		Detail:     detail,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		step.Status = "failed"
+		step.Error = err.Error()
+		if d.result.FailedStep == "" {
+			d.result.FailedStep = name
+		}
+	}
+	d.result.Steps = append(d.result.Steps, step)
+	return err == nil
+}
+
+// 无需执行的步骤，detail 说明原因
+func (d *diagnosis) skipped(name, detail string) {
+	d.result.Steps = append(d.result.Steps, models.DiagnosticStep{Name: name, Status: "skipped", Detail: detail})
+}
+
+// 前面的步骤失败后，其余步骤标记为跳过
+func (d *diagnosis) skip(names ...string) {
+	for _, name := range names {
+		d.result.Steps = append(d.result.Steps, models.DiagnosticStep{Name: name, Status: "skipped"})
+	}
+}
+
+// DiagnoseConnection 逐步检测到主机的连接：DNS解析、跳板机、TCP连接、SSH握手、认证和执行命令，
+// 每一步分别给出结果和耗时，某一步失败后其余步骤跳过
+func (s *SSHService) DiagnoseConnection(host *models.Host) *models.HostDiagnostic {
+	d := &diagnosis{result: &models.HostDiagnostic{HostID: host.ID, CheckedAt: time.Now()}}
+	addr := fmt.Sprintf("%s:%d", host.IPAddress, host.Port)
+
+	start := time.Now()
+	chain, err := resolveJumpChain(host)
+	if err != nil {
+		d.step("jump", start, "", err)
+		d.skip("dns", "tcp", "handshake", "auth", "shell")
+		return d.result
+	}
+
+	// 经由跳板机时由最后一跳解析和连接目标地址，否则本地解析后直接拨号或经由代理
+	var via *ssh.Client
+	if len(chain) > 1 {
+		start = time.Now()
+		via, err = s.connectChain(chain[:len(chain)-1])
+		names := make([]string, 0, len(chain)-1)
+		for _, hop := range chain[:len(chain)-1] {
+			names = append(names, hop.Name)
+		}
+		if !d.step("jump", start, strings.Join(names, " -> "), err) {
+			d.skip("dns", "tcp", "handshake", "auth", "shell")
+			return d.result
+		}
+		defer via.Close()
+		d.skipped("dns", "由跳板机 "+chain[len(chain)-2].Name+" 解析")
+	} else if !s.diagnoseDNS(d, host) {
+		d.skip("tcp", "handshake", "auth", "shell")
+		return d.result
+	}
+
+	start = time.Now()
+	var conn net.Conn
+	if via != nil {
+		conn, err = dialThroughTimeout(via, addr, diagnoseTimeout)
+	} else {
+		conn, err = dialTCP(host, addr, diagnoseTimeout)
+	}
+	detail := ""
+	if err == nil {
+		if via != nil {
+			detail = "经由跳板机 " + chain[len(chain)-2].Name + " 连接"
+		} else {
+			detail = "已连接 " + conn.RemoteAddr().String()
+		}
+	}
+	if !d.step("tcp", start, detail, err) {
+		d.skip("handshake", "auth", "shell")
+		return d.result
+	}
+
+	client := s.diagnoseSSH(d, host, conn, addr)
+	if client == nil {
+		return d.result
+	}
+	defer client.Close()
+
+	start = time.Now()
+	output, err := diagnoseShell(client)
+	d.step("shell", start, output, err)
+
+	d.result.Success = d.result.FailedStep == ""
+	return d.result
+}
+
+// 解析目标地址；经由代理时由代理解析，IP 地址无需解析
+func (s *SSHService) diagnoseDNS(d *diagnosis, host *models.Host) bool {
+	start := time.Now()
+	if net.ParseIP(host.IPAddress) != nil {
+		d.skipped("dns", "IP 地址无需解析")
+		return true
+	}
+	proxyURL, err := proxyURLForHost(host)
+	if err != nil {
+		return d.step("dns", start, "", err)
+	}
+	if proxyURL != nil {
+		d.skipped("dns", "由代理 "+proxyURL.Host+" 解析")
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), diagnoseTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host.IPAddress)
+	if err == nil && len(addrs) == 0 {
+		err = errors.New("没有解析到地址")
+	}
+	return d.step("dns", start, strings.Join(addrs, ", "), err)
+}
+
+// 在已建立的TCP连接上完成SSH握手和认证，主机密钥校验通过即视为握手成功
+func (s *SSHService) diagnoseSSH(d *diagnosis, host *models.Host, conn net.Conn, addr string) *ssh.Client {
+	cfg, keyErr := s.clientConfig(host)
+	if keyErr != nil {
+		// 私钥无效时仍然检测握手，认证步骤报告私钥错误
+		cfg = &ssh.ClientConfig{User: host.Username, HostKeyCallback: hostKeyCallback(host)}
+	}
+	cfg.Timeout = diagnoseTimeout
+
+	var handshakeDone bool
+	var banner string
+	verify := cfg.HostKeyCallback
+	cfg.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		d.result.HostKey = ssh.FingerprintSHA256(key)
+		if err := verify(hostname, remote, key); err != nil {
+			return err
+		}
+		handshakeDone = true
+		return nil
+	}
+	cfg.BannerCallback = func(message string) error {
+		banner = strings.TrimSpace(message)
+		return nil
+	}
+
+	// 经由跳板机的连接不支持 SetDeadline，超时后直接关闭连接
+	start := time.Now()
+	timer := time.AfterFunc(diagnoseTimeout, func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if !timer.Stop() && err != nil {
+		err = fmt.Errorf("握手超时: %v", err)
+	}
+	if err != nil {
+		conn.Close()
+	}
+
+	if !handshakeDone {
+		d.step("handshake", start, "", err)
+		d.skip("auth", "shell")
+		return nil
+	}
+	d.step("handshake", start, d.result.HostKey, nil)
+
+	start = time.Now()
+	if keyErr != nil {
+		if c != nil {
+			c.Close()
+		}
+		d.step("auth", start, "", keyErr)
+		d.skip("shell")
+		return nil
+	}
+	detail := host.Username
+	if banner != "" {
+		detail += "，登录提示: " + banner
+	}
+	if err != nil {
+		err = errors.New(strings.TrimPrefix(err.Error(), "ssh: handshake failed: "))
+	}
+	if !d.step("auth", start, detail, err) {
+		d.skip("shell")
+		return nil
+	}
+	return ssh.NewClient(c, chans, reqs)
+}
+
+// 打开会话执行简单命令，确认账号可以正常使用 shell
+func diagnoseShell(client *ssh.Client) (string, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	type result struct {
+		output []byte
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		output, err := session.CombinedOutput(`echo "shell: ${SHELL:-$0}"; uname -srm`)
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		output := strings.Join(strings.Fields(strings.ReplaceAll(string(r.output), "\n", "; ")), " ")
+		if r.err != nil {
+			return output, fmt.Errorf("执行命令失败: %v %s", r.err, output)
+		}
+		return strings.TrimSuffix(output, ";"), nil
+	case <-time.After(diagnoseTimeout):
+		return "", errors.New("执行命令超时")
+	}
+}
+
+// CheckHost 诊断主机连接并记录结果，更新主机在线状态
+func (s *SSHService) CheckHost(host *models.Host) *models.HostDiagnostic {
+	result := s.DiagnoseConnection(host)
+
+	check := models.HostCheck{Success: result.Success, FailedStep: result.FailedStep, CheckedAt: &result.CheckedAt}
+	for _, step := range result.Steps {
+		if step.Status == "failed" {
+			check.Error = step.Error
+			break
+		}
+	}
+	host.Check = check
+	host.Status = "offline"
+	if result.Success {
+		host.Status = "online"
+	}
+
+	if host.ID != 0 {
+		config.DB.Model(&models.Host{}).Where("id = ?", host.ID).Updates(map[string]interface{}{
+			"status":            host.Status,
+			"check_success":     check.Success,
+			"check_failed_step": check.FailedStep,
+			"check_error":       check.Error,
+			"check_checked_at":  check.CheckedAt,
+		})
+	}
+	return result
+}
@@ -0,0 +1,349 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const dockerTimeout = 2 * time.Minute
+
+var (
+	// 容器名或ID
+	dockerContainerPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+	// docker exec -u 接受的 user[:group]
+	dockerUserPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+(:[A-Za-z0-9_.-]+)?$`)
+	// 容器内shell的绝对路径
+	dockerShellPattern = regexp.MustCompile(`^/[A-Za-z0-9/_.-]+$`)
+	// docker logs --since 接受的时间写法，如 "2024-01-01T10:00:00"、"10m"
+	dockerTimePattern = regexp.MustCompile(`^[0-9A-Za-z:.+\-]+$`)
+)
+
+// 容器操作对应的 docker 子命令
+var dockerActions = map[string]string{
+	"start":   "start",
+	"stop":    "stop",
+	"restart": "restart",
+	"remove":  "rm",
+}
+
+// ErrDockerNotInstalled 主机上没有 docker 命令
+var ErrDockerNotInstalled = errors.New("主机未安装 Docker")
+
+// ValidateDockerContainer 校验容器名或ID
+func ValidateDockerContainer(container string) error {
+	if len(container) > 128 || !dockerContainerPattern.MatchString(container) {
+		return fmt.Errorf("无效的容器名: %s", container)
+	}
+	return nil
+}
+
+// ValidDockerAction 判断是否为支持的容器操作
+func ValidDockerAction(action string) bool {
+	_, ok := dockerActions[action]
+	return ok
+}
+
+// 执行 docker 命令；主机启用 use_sudo 时通过 sudo 执行（登录用户不在 docker 组时需要）
+func (s *SSHService) runDocker(host *models.Host, command string) (string, error) {
+	output, err := s.RunPrivileged(host, "command -v docker >/dev/null 2>&1 || { echo '@@NO_DOCKER'; exit 127; }; "+command, dockerTimeout)
+	if strings.HasPrefix(output, "@@NO_DOCKER") {
+		return "", ErrDockerNotInstalled
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(output); msg != "" {
+			return output, errors.New(msg)
+		}
+		return output, err
+	}
+	return output, nil
+}
+
+// 执行 --format '{{json .}}' 形式的列表命令，每行一个对象。
+// 不同版本的 docker 个别字段可能不是字符串，统一转为字符串
+func (s *SSHService) dockerList(host *models.Host, command string) ([]map[string]string, error) {
+	output, err := s.runDocker(host, command+" --format '{{json .}}'")
+	if err != nil {
+		return nil, err
+	}
+
+	var items []map[string]string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var raw map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			continue
+		}
+		item := make(map[string]string, len(raw))
+		for key, value := range raw {
+			switch v := value.(type) {
+			case string:
+				item[key] = v
+			case nil:
+			default:
+				item[key] = fmt.Sprint(v)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ListDockerContainers 列出主机上的容器（包括已停止的）
+func (s *SSHService) ListDockerContainers(host *models.Host) ([]models.DockerContainer, error) {
+	items, err := s.dockerList(host, "docker ps -a --no-trunc")
+	if err != nil {
+		return nil, err
+	}
+	containers := make([]models.DockerContainer, 0, len(items))
+	for _, item := range items {
+		containers = append(containers, models.DockerContainer{
+			ID:        item["ID"],
+			Name:      item["Names"],
+			Image:     item["Image"],
+			Command:   strings.Trim(item["Command"], `"`),
+			State:     item["State"],
+			Status:    item["Status"],
+			Ports:     item["Ports"],
+			Networks:  item["Networks"],
+			Mounts:    item["Mounts"],
+			Labels:    item["Labels"],
+			CreatedAt: item["CreatedAt"],
+		})
+	}
+	return containers, nil
+}
+
+// ListDockerImages 列出主机上的镜像
+func (s *SSHService) ListDockerImages(host *models.Host) ([]models.DockerImage, error) {
+	items, err := s.dockerList(host, "docker images --digests --no-trunc")
+	if err != nil {
+		return nil, err
+	}
+	images := make([]models.DockerImage, 0, len(items))
+	for _, item := range items {
+		images = append(images, models.DockerImage{
+			ID:         item["ID"],
+			Repository: item["Repository"],
+			Tag:        item["Tag"],
+			Digest:     item["Digest"],
+			Size:       item["Size"],
+			CreatedAt:  item["CreatedAt"],
+		})
+	}
+	return images, nil
+}
+
+// ListDockerVolumes 列出主机上的卷
+func (s *SSHService) ListDockerVolumes(host *models.Host) ([]models.DockerVolume, error) {
+	items, err := s.dockerList(host, "docker volume ls")
+	if err != nil {
+		return nil, err
+	}
+	volumes := make([]models.DockerVolume, 0, len(items))
+	for _, item := range items {
+		volumes = append(volumes, models.DockerVolume{
+			Name:       item["Name"],
+			Driver:     item["Driver"],
+			Scope:      item["Scope"],
+			Mountpoint: item["Mountpoint"],
+			Labels:     item["Labels"],
+		})
+	}
+	return volumes, nil
+}
+
+// ListDockerNetworks 列出主机上的网络
+func (s *SSHService) ListDockerNetworks(host *models.Host) ([]models.DockerNetwork, error) {
+	items, err := s.dockerList(host, "docker network ls --no-trunc")
+	if err != nil {
+		return nil, err
+	}
+	networks := make([]models.DockerNetwork, 0, len(items))
+	for _, item := range items {
+		networks = append(networks, models.DockerNetwork{
+			ID:        item["ID"],
+			Name:      item["Name"],
+			Driver:    item["Driver"],
+			Scope:     item["Scope"],
+			Internal:  item["Internal"],
+			IPv6:      item["IPv6"],
+			CreatedAt: item["CreatedAt"],
+		})
+	}
+	return networks, nil
+}
+
+// DockerStats 采样运行中容器的资源使用情况，container 为空时返回所有运行中的容器
+func (s *SSHService) DockerStats(host *models.Host, container string) ([]models.DockerContainerStats, error) {
+	command := "docker stats --no-stream --no-trunc"
+	if container != "" {
+		if err := ValidateDockerContainer(container); err != nil {
+			return nil, err
+		}
+		command += " " + shellQuote(container)
+	}
+
+	items, err := s.dockerList(host, command)
+	if err != nil {
+		return nil, err
+	}
+	stats := make([]models.DockerContainerStats, 0, len(items))
+	for _, item := range items {
+		stat := models.DockerContainerStats{
+			ID:       item["ID"],
+			Name:     item["Name"],
+			CPU:      parsePercent(item["CPUPerc"]),
+			Memory:   parsePercent(item["MemPerc"]),
+			MemUsage: item["MemUsage"],
+			NetIO:    item["NetIO"],
+			BlockIO:  item["BlockIO"],
+		}
+		stat.PIDs, _ = strconv.Atoi(item["PIDs"])
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+// 解析 "12.34%" 形式的百分比，无法解析（如 "--"）时返回0
+func parsePercent(value string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	return v
+}
+
+// DockerAction 对容器执行 start/stop/restart/remove，返回操作后的状态；force 为 true 时强制删除运行中的容器
+func (s *SSHService) DockerAction(host *models.Host, container, action string, force bool) (models.DockerActionResult, error) {
+	result := models.DockerActionResult{Container: container, Action: action}
+	if err := ValidateDockerContainer(container); err != nil {
+		return result, err
+	}
+	subcommand, ok := dockerActions[action]
+	if !ok {
+		return result, fmt.Errorf("不支持的操作: %s", action)
+	}
+
+	quoted := shellQuote(container)
+	command := "docker " + subcommand
+	if action == "remove" && force {
+		command += " -f"
+	}
+	command += " " + quoted
+	if action != "remove" {
+		// 无论操作是否成功都输出当前状态，以标记与操作输出区分
+		command = fmt.Sprintf("%s; rc=$?; echo; echo @@STATE $(docker inspect -f '{{.State.Status}}' %s 2>/dev/null); exit $rc", command, quoted)
+	}
+
+	output, err := s.runDocker(host, command)
+	if i := strings.LastIndex(output, "@@STATE"); i >= 0 {
+		result.State = strings.TrimSpace(output[i+len("@@STATE"):])
+		output = output[:i]
+	}
+	result.Output = strings.TrimSpace(output)
+	if err != nil && result.Output != "" {
+		err = errors.New(result.Output)
+	}
+	return result, err
+}
+
+// DockerLogOptions docker logs 查询参数
+type DockerLogOptions struct {
+	Lines      int    // 最多返回的行数
+	Since      string // 开始时间，如 "2024-01-01T10:00:00" 或 "10m"
+	Timestamps bool   // 每行前加时间戳
+}
+
+// DockerLogs 获取容器的最近日志（标准输出和标准错误合并）
+func (s *SSHService) DockerLogs(host *models.Host, container string, opts DockerLogOptions) (string, error) {
+	if err := ValidateDockerContainer(container); err != nil {
+		return "", err
+	}
+	if opts.Lines <= 0 {
+		opts.Lines = 200
+	}
+
+	args := []string{"docker", "logs", "--tail", strconv.Itoa(opts.Lines)}
+	if opts.Since != "" {
+		if !dockerTimePattern.MatchString(opts.Since) {
+			return "", fmt.Errorf("无效的开始时间")
+		}
+		args = append(args, "--since", shellQuote(opts.Since))
+	}
+	if opts.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	args = append(args, shellQuote(container), "2>&1")
+
+	return s.runDocker(host, strings.Join(args, " "))
+}
+
+// 生成在容器中启动交互式shell的 docker exec 命令，shell 为空时优先使用 bash
+func dockerExecCommand(container, user, shell string) (string, error) {
+	if err := ValidateDockerContainer(container); err != nil {
+		return "", err
+	}
+	args := []string{"docker", "exec", "-it", "-e", "TERM=xterm"}
+	if user != "" {
+		if !dockerUserPattern.MatchString(user) {
+			return "", fmt.Errorf("无效的容器用户: %s", user)
+		}
+		args = append(args, "-u", shellQuote(user))
+	}
+	args = append(args, shellQuote(container))
+	if shell == "" {
+		args = append(args, "sh", "-c", shellQuote("if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"))
+	} else {
+		if !dockerShellPattern.MatchString(shell) {
+			return "", fmt.Errorf("无效的shell路径: %s", shell)
+		}
+		args = append(args, shellQuote(shell))
+	}
+	return strings.Join(args, " "), nil
+}
+
+// CreateDockerExecSession 创建用于 docker exec 的终端会话，需与 StartDockerExec 配合使用。
+// 需要 sudo 密码时关闭终端回显，避免写入的密码出现在输出和审计记录中
+func (s *SSHService) CreateDockerExecSession(host *models.Host) (*ssh.Client, *ssh.Session, error) {
+	echo := uint32(1)
+	if _, password := privilegedCommand(host, ""); password != "" {
+		echo = 0
+	}
+	return s.createTerminalSession(host, echo)
+}
+
+// StartDockerExec 在终端会话中启动 docker exec；stdin 为会话的标准输入。
+// 带PTY时 sudo 从终端读取密码，因此先在同一终端上用 sudo -S -v 验证密码，再以 sudo -n 执行 docker exec
+func (s *SSHService) StartDockerExec(session *ssh.Session, stdin io.Writer, host *models.Host, container, user, shell string) error {
+	command, err := dockerExecCommand(container, user, shell)
+	if err != nil {
+		return err
+	}
+
+	wrapped, password := privilegedCommand(host, command)
+	if password != "" {
+		command = `IFS= read -r p; stty echo; printf '%s\n' "$p" | sudo -S -p '' -v; unset p; exec sudo -n ` + command
+	} else {
+		command = "exec " + wrapped
+	}
+
+	if err := session.Start(command); err != nil {
+		return err
+	}
+	if password != "" {
+		if _, err := stdin.Write([]byte(password)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+)
+
+const factsTimeout = 30 * time.Second
+
+// 与 statsScript 相同，只依赖 /proc、/sys 和 BusyBox 也具备的工具，缺失的项输出为空
+const factsScript = `export LC_ALL=C
+echo '==OSRELEASE=='; cat /etc/os-release 2>/dev/null || cat /usr/lib/os-release 2>/dev/null
+echo '==KERNEL=='; uname -r
+echo '==ARCH=='; uname -m
+echo '==CPUINFO=='; grep -E '^(model name|Model|Hardware|cpu model|Processor)[[:space:]]*:' /proc/cpuinfo 2>/dev/null | sort -u
+echo '==NPROC=='; nproc 2>/dev/null || grep -c '^processor' /proc/cpuinfo
+echo '==MEMINFO=='; grep '^MemTotal:' /proc/meminfo
+echo '==HOSTNAME=='; hostname 2>/dev/null || cat /proc/sys/kernel/hostname
+echo '==FQDN=='; hostname -f 2>/dev/null
+echo '==ADDRS=='; ip -o addr show 2>/dev/null || hostname -i 2>/dev/null
+echo '==VIRT=='; systemd-detect-virt 2>/dev/null
+echo '==DMI=='; cat /sys/class/dmi/id/product_name /sys/class/dmi/id/sys_vendor 2>/dev/null
+echo '==CONTAINER=='; [ -f /.dockerenv ] && echo docker; [ -f /run/.containerenv ] && echo podman; cat /proc/1/cgroup 2>/dev/null | head -n 20
+echo '==HYPERVISOR=='; grep -m1 -o -w hypervisor /proc/cpuinfo 2>/dev/null
+echo '==PKG=='; for pm in apt-get dnf yum zypper pacman apk emerge xbps-install opkg; do command -v $pm >/dev/null 2>&1 && echo $pm; done
+echo '==END=='
+`
+
+// 包管理器命令与展示名称，按优先级排列（如同时存在 dnf 和 yum 时取 dnf）
+var packageManagers = map[string]string{
+	"apt-get":      "apt",
+	"dnf":          "dnf",
+	"yum":          "yum",
+	"zypper":       "zypper",
+	"pacman":       "pacman",
+	"apk":          "apk",
+	"emerge":       "portage",
+	"xbps-install": "xbps",
+	"opkg":         "opkg",
+}
+
+// DMI 产品名或厂商中的关键字与虚拟化类型，命名与 systemd-detect-virt 一致
+var dmiVirtualization = []struct{ keyword, virt string }{
+	{"VirtualBox", "oracle"},
+	{"VMware", "vmware"},
+	{"KVM", "kvm"},
+	{"QEMU", "qemu"},
+	{"HVM domU", "xen"},
+	{"Xen", "xen"},
+	{"Virtual Machine", "microsoft"},
+	{"Bochs", "bochs"},
+	{"Parallels", "parallels"},
+	{"Amazon EC2", "amazon"},
+	{"Google Compute Engine", "google"},
+}
+
+// CollectFacts 采集主机的清单信息
+func (s *SSHService) CollectFacts(host *models.Host) (models.HostFacts, error) {
+	output, err := s.RunCommand(host, "sh -s", strings.NewReader(factsScript), factsTimeout)
+	sections := splitStatsSections(output)
+	if _, ok := sections["END"]; !ok {
+		if err == nil {
+			err = errors.New("采集脚本未正常结束")
+		}
+		return models.HostFacts{}, err
+	}
+	return parseFacts(sections), nil
+}
+
+// 由脚本输出的各段解析清单信息
+func parseFacts(sections map[string][]string) models.HostFacts {
+	var facts models.HostFacts
+
+	osRelease := parseOSRelease(sections["OSRELEASE"])
+	facts.OS = osRelease["ID"]
+	facts.OSName = osRelease["PRETTY_NAME"]
+	if facts.OSName == "" {
+		facts.OSName = strings.TrimSpace(osRelease["NAME"] + " " + osRelease["VERSION"])
+	}
+	facts.OSVersion = osRelease["VERSION_ID"]
+
+	facts.Kernel = firstLine(sections["KERNEL"])
+	facts.Arch = firstLine(sections["ARCH"])
+	facts.CPUModel = parseCPUModel(sections["CPUINFO"])
+	facts.CPUCount, _ = strconv.Atoi(firstLine(sections["NPROC"]))
+	facts.MemoryTotal = parseMeminfoValues(sections["MEMINFO"])["MemTotal"]
+
+	facts.Hostname = firstLine(sections["HOSTNAME"])
+	facts.FQDN = firstLine(sections["FQDN"])
+	// 未配置域名时 hostname -f 可能输出 localhost 或与主机名相同
+	if facts.FQDN == "" || strings.HasPrefix(facts.FQDN, "localhost") {
+		facts.FQDN = facts.Hostname
+	}
+
+	facts.IPAddresses = strings.Join(parseAddresses(sections["ADDRS"]), ",")
+	facts.Virtualization = detectVirtualization(sections)
+
+	for _, line := range sections["PKG"] {
+		if name, ok := packageManagers[strings.TrimSpace(line)]; ok {
+			facts.PackageManager = name
+			break
+		}
+	}
+	return facts
+}
+
+// 解析 os-release 的 KEY=value 行，去掉值两侧的引号
+func parseOSRelease(lines []string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range lines {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || strings.HasPrefix(key, "#") {
+			continue
+		}
+		values[key] = strings.Trim(value, `"'`)
+	}
+	return values
+}
+
+func firstLine(lines []string) string {
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// x86 取 "model name"，ARM 依次取 "Model"、"Hardware"、"Processor"
+func parseCPUModel(lines []string) string {
+	values := make(map[string]string)
+	for _, line := range lines {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if _, exists := values[key]; !exists {
+			values[key] = strings.TrimSpace(value)
+		}
+	}
+	for _, key := range []string{"model name", "cpu model", "Model", "Hardware", "Processor"} {
+		if v := values[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// 解析 ip -o addr show 的输出（"2: eth0    inet 10.0.0.5/24 brd ..."），
+// 没有 ip 命令时为 hostname -i 输出的空格分隔的地址。忽略回环和链路本地地址
+func parseAddresses(lines []string) []string {
+	var addresses []string
+	seen := make(map[string]bool)
+	add := func(value string) {
+		ip := net.ParseIP(strings.SplitN(value, "/", 2)[0])
+		if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+			return
+		}
+		if addr := ip.String(); !seen[addr] {
+			seen[addr] = true
+			addresses = append(addresses, addr)
+		}
+	}
+
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		found := false
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "inet" || fields[i] == "inet6" {
+				add(fields[i+1])
+				found = true
+			}
+		}
+		if !found {
+			for _, field := range fields {
+				add(field)
+			}
+		}
+	}
+	return addresses
+}
+
+// 优先使用 systemd-detect-virt，否则依次根据容器标记、DMI 信息和 CPU 的 hypervisor 标志判断
+func detectVirtualization(sections map[string][]string) string {
+	if virt := firstLine(sections["VIRT"]); virt != "" {
+		return virt
+	}
+
+	for _, line := range sections["CONTAINER"] {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "docker" || line == "podman":
+			return line
+		case strings.Contains(line, "/docker"):
+			return "docker"
+		case strings.Contains(line, "/lxc"):
+			return "lxc"
+		case strings.Contains(line, "/kubepods"):
+			return "container-other"
+		}
+	}
+
+	dmi := strings.Join(sections["DMI"], " ")
+	for _, item := range dmiVirtualization {
+		if strings.Contains(dmi, item.keyword) {
+			return item.virt
+		}
+	}
+
+	if firstLine(sections["HYPERVISOR"]) != "" {
+		return "vm-other"
+	}
+	return "none"
+}
+
+// RefreshHostFacts 采集并保存主机的清单信息，失败时只记录原因，保留上次采集的结果
+func (s *SSHService) RefreshHostFacts(host *models.Host) (models.HostFacts, error) {
+	facts, err := s.CollectFacts(host)
+	if err != nil {
+		host.Facts.Error = err.Error()
+		config.DB.Model(&models.Host{}).Where("id = ?", host.ID).Update("facts_error", host.Facts.Error)
+		return host.Facts, err
+	}
+
+	now := time.Now()
+	facts.CollectedAt = &now
+	host.Facts = facts
+	if err := config.DB.Model(&models.Host{}).Where("id = ?", host.ID).
+		Select(factsColumns).Updates(&models.Host{Facts: facts}).Error; err != nil {
+		log.Printf("Failed to save facts for host %d: %v", host.ID, err)
+		return facts, err
+	}
+	return facts, nil
+}
+
+// 清单信息对应的列，整体更新以清除上次采集到而本次没有的值
+var factsColumns = []string{
+	"facts_os", "facts_os_name", "facts_os_version", "facts_kernel", "facts_arch",
+	"facts_cpu_model", "facts_cpu_count", "facts_memory_total", "facts_hostname", "facts_fqdn",
+	"facts_ip_addresses", "facts_virtualization", "facts_package_manager", "facts_error", "facts_collected_at",
+}
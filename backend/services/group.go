@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"gorm.io/gorm"
+)
+
+// NormalizeTagName 去掉首尾空白并校验标签名
+func NormalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("标签名不能为空")
+	}
+	if utf8.RuneCountInString(name) > 64 || strings.ContainsAny(name, ",\n\r\t") {
+		return "", fmt.Errorf("无效的标签名: %s", name)
+	}
+	return name, nil
+}
+
+// ResolveTags 按名称查找标签，不存在的自动创建；重复的名称只保留一个
+func ResolveTags(db *gorm.DB, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		name, err := NormalizeTagName(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var tag models.Tag
+		if err := db.Where(models.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// 所有分组按ID索引
+func loadGroupMap() (map[uint]*models.HostGroup, error) {
+	var groups []models.HostGroup
+	if err := config.DB.Order("name ASC").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.HostGroup, len(groups))
+	for i := range groups {
+		byID[groups[i].ID] = &groups[i]
+	}
+	return byID, nil
+}
+
+// GroupDescendantIDs 返回分组自身及其所有子孙分组的ID
+func GroupDescendantIDs(id uint) ([]uint, error) {
+	byID, err := loadGroupMap()
+	if err != nil {
+		return nil, err
+	}
+	children := make(map[uint][]uint)
+	for _, group := range byID {
+		if group.ParentID != nil {
+			children[*group.ParentID] = append(children[*group.ParentID], group.ID)
+		}
+	}
+
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	return ids, nil
+}
+
+// ValidateGroupParent 校验分组的上级：上级必须存在，且不能是分组自身或其子孙（id 为0表示新建分组）
+func ValidateGroupParent(id uint, parentID *uint) error {
+	if parentID == nil {
+		return nil
+	}
+	byID, err := loadGroupMap()
+	if err != nil {
+		return err
+	}
+	if _, ok := byID[*parentID]; !ok {
+		return errors.New("上级分组不存在")
+	}
+	seen := make(map[uint]bool)
+	for current := parentID; current != nil && !seen[*current]; {
+		if *current == id {
+			return errors.New("不能将分组移动到自身或其子分组下")
+		}
+		seen[*current] = true
+		group, ok := byID[*current]
+		if !ok {
+			break
+		}
+		current = group.ParentID
+	}
+	return nil
+}
+
+// ListHostGroups 返回所有分组（带完整路径和主机数），按路径排序
+func ListHostGroups() ([]models.HostGroup, error) {
+	byID, err := loadGroupMap()
+	if err != nil {
+		return nil, err
+	}
+
+	type groupCount struct {
+		GroupID uint
+		Count   int64
+	}
+	var counts []groupCount
+	config.DB.Model(&models.Host{}).Select("group_id, COUNT(*) AS count").
+		Where("group_id IS NOT NULL").Group("group_id").Scan(&counts)
+	for _, count := range counts {
+		if group, ok := byID[count.GroupID]; ok {
+			group.HostCount = count.Count
+		}
+	}
+
+	groups := make([]models.HostGroup, 0, len(byID))
+	for _, group := range byID {
+		group.Path = groupPath(byID, group)
+		groups = append(groups, *group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Path < groups[j].Path })
+	return groups, nil
+}
+
+// 由上级链拼出分组路径，上级缺失或成环时在该处截断
+func groupPath(byID map[uint]*models.HostGroup, group *models.HostGroup) string {
+	parts := []string{group.Name}
+	seen := map[uint]bool{group.ID: true}
+	for parentID := group.ParentID; parentID != nil; {
+		parent, ok := byID[*parentID]
+		if !ok || seen[parent.ID] {
+			break
+		}
+		seen[parent.ID] = true
+		parts = append([]string{parent.Name}, parts...)
+		parentID = parent.ParentID
+	}
+	return strings.Join(parts, "/")
+}
+
+// BuildGroupTree 将 ListHostGroups 返回的分组组织为树
+func BuildGroupTree(groups []models.HostGroup) []models.HostGroup {
+	children := make(map[uint][]models.HostGroup)
+	exists := make(map[uint]bool, len(groups))
+	for _, group := range groups {
+		exists[group.ID] = true
+	}
+	var roots []models.HostGroup
+	for _, group := range groups {
+		if group.ParentID == nil || !exists[*group.ParentID] {
+			roots = append(roots, group)
+		} else {
+			children[*group.ParentID] = append(children[*group.ParentID], group)
+		}
+	}
+
+	var attach func(nodes []models.HostGroup) []models.HostGroup
+	attach = func(nodes []models.HostGroup) []models.HostGroup {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	return attach(roots)
+}
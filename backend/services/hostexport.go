@@ -0,0 +1,119 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CSV 导出的列，与导入支持的列名一致
+var exportCSVColumns = []string{
+	"name", "ip_address", "port", "username", "password", "private_key", "jump_host",
+	"proxy_url", "use_sudo", "sudo_password", "group", "tags",
+}
+
+// HostExportRows 将主机转换为导入格式的行；includeSecrets 为 false 时不包含密码和私钥
+func HostExportRows(hosts []models.Host, includeSecrets bool) []models.HostImportRow {
+	var all []models.Host
+	config.DB.Select("id", "name").Find(&all)
+	names := make(map[uint]string, len(all))
+	for _, host := range all {
+		names[host.ID] = host.Name
+	}
+
+	groups, _ := ListHostGroups()
+	paths := make(map[uint]string, len(groups))
+	for _, group := range groups {
+		paths[group.ID] = group.Path
+	}
+
+	rows := make([]models.HostImportRow, 0, len(hosts))
+	for _, host := range hosts {
+		useSudo := host.UseSudo
+		row := models.HostImportRow{
+			Name:      host.Name,
+			IPAddress: host.IPAddress,
+			Port:      host.Port,
+			Username:  host.Username,
+			ProxyURL:  host.ProxyURL,
+			UseSudo:   &useSudo,
+		}
+		if includeSecrets {
+			row.Password = host.Password
+			row.PrivateKey = host.PrivateKey
+			row.SudoPassword = host.SudoPassword
+		}
+		if host.JumpHostID != nil {
+			row.JumpHost = names[*host.JumpHostID]
+		}
+		if host.GroupID != nil {
+			row.Group = paths[*host.GroupID]
+		}
+		for _, tag := range host.Tags {
+			row.Tags = append(row.Tags, tag.Name)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// EncodeHostExport 按格式编码导出的行，返回内容、Content-Type 和文件扩展名
+func EncodeHostExport(rows []models.HostImportRow, format string) ([]byte, string, string, error) {
+	switch strings.ToLower(format) {
+	case "csv":
+		var buf bytes.Buffer
+		writer := csv.NewWriter(&buf)
+		writer.Write(exportCSVColumns)
+		for _, row := range rows {
+			writer.Write([]string{
+				row.Name, row.IPAddress, strconv.Itoa(row.Port), row.Username, row.Password,
+				strings.ReplaceAll(row.PrivateKey, "\n", `\n`), row.JumpHost, row.ProxyURL,
+				strconv.FormatBool(row.UseSudo != nil && *row.UseSudo), row.SudoPassword, row.Group, strings.Join(row.Tags, ";"),
+			})
+		}
+		writer.Flush()
+		return buf.Bytes(), "text/csv; charset=utf-8", "csv", writer.Error()
+	case "yaml", "yml":
+		data, err := yaml.Marshal(map[string]interface{}{"hosts": rows})
+		return data, "application/x-yaml; charset=utf-8", "yaml", err
+	case "json":
+		data, err := json.MarshalIndent(map[string]interface{}{"hosts": rows}, "", "  ")
+		return data, "application/json; charset=utf-8", "json", err
+	case "ssh_config", "ssh-config", "sshconfig":
+		return encodeSSHConfig(rows), "text/plain; charset=utf-8", "conf", nil
+	default:
+		return nil, "", "", fmt.Errorf("不支持的导出格式: %s，可选 csv、yaml、json、ssh_config", format)
+	}
+}
+
+// 以主机名为 Host 别名输出 OpenSSH 客户端配置，凭据不会写入
+func encodeSSHConfig(rows []models.HostImportRow) []byte {
+	alias := func(name string) string {
+		return strings.Join(strings.Fields(name), "-")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("# Generated by host-manager\n")
+	for _, row := range rows {
+		fmt.Fprintf(&buf, "\nHost %s\n", alias(row.Name))
+		fmt.Fprintf(&buf, "    HostName %s\n", row.IPAddress)
+		if row.Username != "" {
+			fmt.Fprintf(&buf, "    User %s\n", row.Username)
+		}
+		if row.Port != 0 && row.Port != 22 {
+			fmt.Fprintf(&buf, "    Port %d\n", row.Port)
+		}
+		if row.JumpHost != "" {
+			fmt.Fprintf(&buf, "    ProxyJump %s\n", alias(row.JumpHost))
+		}
+	}
+	return buf.Bytes()
+}
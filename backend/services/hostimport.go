@@ -0,0 +1,906 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+const (
+	// 一次最多导入的主机数
+	maxImportRows = 5000
+	// 导入时同时测试连接的主机数
+	importTestConcurrency = 20
+)
+
+// 解析后的一行及其在源文件中的位置
+type importSource struct {
+	line     int
+	row      models.HostImportRow
+	warnings []string
+}
+
+// ParseHostImport 按格式解析导入文件，返回各行及与具体行无关的提示
+func ParseHostImport(format, content string) ([]importSource, []string, error) {
+	var (
+		sources  []importSource
+		warnings []string
+		err      error
+	)
+	switch strings.ToLower(format) {
+	case "csv":
+		sources, warnings, err = parseImportCSV(content)
+	case "yaml", "yml":
+		sources, err = parseImportYAML(content)
+	case "json":
+		sources, err = parseImportJSON(content)
+	case "ssh_config", "ssh-config", "sshconfig":
+		sources, warnings, err = parseImportSSHConfig(content)
+	default:
+		return nil, nil, fmt.Errorf("不支持的导入格式: %s，可选 csv、yaml、json、ssh_config", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sources) == 0 {
+		return nil, nil, errors.New("文件中没有主机")
+	}
+	if len(sources) > maxImportRows {
+		return nil, nil, fmt.Errorf("一次最多导入%d台主机", maxImportRows)
+	}
+	return sources, warnings, nil
+}
+
+// CSV 列名（小写）与字段的对应关系，包含常见别名
+var importCSVColumns = map[string]string{
+	"name": "name", "ip_address": "ip_address", "ip": "ip_address", "address": "ip_address", "host": "ip_address", "hostname": "ip_address",
+	"port": "port", "username": "username", "user": "username", "password": "password",
+	"private_key": "private_key", "identity_file": "identity_file",
+	"jump_host": "jump_host", "proxy_jump": "jump_host", "proxyjump": "jump_host",
+	"proxy_url": "proxy_url", "use_sudo": "use_sudo", "sudo_password": "sudo_password",
+	"group": "group", "tags": "tags",
+}
+
+// 第一行为表头；tags 列用逗号、分号或竖线分隔
+func parseImportCSV(content string) ([]importSource, []string, error) {
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, "\ufeff")))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取CSV表头失败: %v", err)
+	}
+	var warnings []string
+	columns := make([]string, len(header))
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if field, ok := importCSVColumns[key]; ok {
+			columns[i] = field
+		} else if key != "" {
+			warnings = append(warnings, fmt.Sprintf("忽略不支持的列: %s", name))
+		}
+	}
+
+	var sources []importSource
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("第%d行CSV格式错误: %v", line, err)
+		}
+
+		src := importSource{line: line}
+		empty := true
+		for i, value := range record {
+			value = strings.TrimSpace(value)
+			if i >= len(columns) || columns[i] == "" || value == "" {
+				continue
+			}
+			empty = false
+			if err := setImportField(&src.row, columns[i], value); err != nil {
+				src.warnings = append(src.warnings, err.Error())
+			}
+		}
+		if !empty {
+			sources = append(sources, src)
+		}
+	}
+	return sources, warnings, nil
+}
+
+// 将 CSV 中的字符串值写入对应字段
+func setImportField(row *models.HostImportRow, field, value string) error {
+	switch field {
+	case "name":
+		row.Name = value
+	case "ip_address":
+		row.IPAddress = value
+	case "port":
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			// 保留无效值交由校验报错
+			row.Port = -1
+			return nil
+		}
+		row.Port = port
+	case "username":
+		row.Username = value
+	case "password":
+		row.Password = value
+	case "private_key":
+		// CSV 单元格中的私钥换行可能写成 \n
+		row.PrivateKey = strings.ReplaceAll(value, `\n`, "\n")
+	case "identity_file":
+		row.IdentityFile = value
+	case "jump_host":
+		row.JumpHost = value
+	case "proxy_url":
+		row.ProxyURL = value
+	case "use_sudo":
+		useSudo := false
+		row.UseSudo = &useSudo
+		switch strings.ToLower(value) {
+		case "1", "true", "yes", "y", "是":
+			useSudo = true
+		case "0", "false", "no", "n", "否":
+		default:
+			return fmt.Errorf("无法识别的 use_sudo 值 %q，按 false 处理", value)
+		}
+	case "sudo_password":
+		row.SudoPassword = value
+	case "group":
+		row.Group = value
+	case "tags":
+		row.Tags = models.SplitTagList(value)
+	}
+	return nil
+}
+
+// YAML 为主机列表，或带 hosts 键的对象
+func parseImportYAML(content string) ([]importSource, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &root); err != nil {
+		return nil, fmt.Errorf("YAML格式错误: %v", err)
+	}
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+
+	list := root.Content[0]
+	if list.Kind == yaml.MappingNode {
+		list = nil
+		for i := 0; i+1 < len(root.Content[0].Content); i += 2 {
+			if root.Content[0].Content[i].Value == "hosts" {
+				list = root.Content[0].Content[i+1]
+			}
+		}
+	}
+	if list == nil || list.Kind != yaml.SequenceNode {
+		return nil, errors.New("YAML 应为主机列表或包含 hosts 列表的对象")
+	}
+
+	sources := make([]importSource, 0, len(list.Content))
+	for _, item := range list.Content {
+		var row models.HostImportRow
+		if err := item.Decode(&row); err != nil {
+			return nil, fmt.Errorf("第%d行格式错误: %v", item.Line, err)
+		}
+		sources = append(sources, importSource{line: item.Line, row: row})
+	}
+	return sources, nil
+}
+
+// JSON 为主机数组，或带 hosts 字段的对象；行号为数组中的序号（从1开始）
+func parseImportJSON(content string) ([]importSource, error) {
+	var rows []models.HostImportRow
+	data := []byte(strings.TrimSpace(content))
+	if bytes.HasPrefix(data, []byte("{")) {
+		var wrapper struct {
+			Hosts []models.HostImportRow `json:"hosts"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, fmt.Errorf("JSON格式错误: %v", err)
+		}
+		rows = wrapper.Hosts
+	} else if err := json.Unmarshal(data, &rows); err != nil {
+		return nil, fmt.Errorf("JSON格式错误: %v", err)
+	}
+
+	sources := make([]importSource, 0, len(rows))
+	for i, row := range rows {
+		sources = append(sources, importSource{line: i + 1, row: row})
+	}
+	return sources, nil
+}
+
+// ssh_config 中的一个 Host 段
+type sshConfigBlock struct {
+	line     int
+	patterns []string
+	options  [][2]string // 关键字（小写）和值，按出现顺序
+}
+
+// 解析 OpenSSH 客户端配置：每个不含通配符的 Host 别名为一台主机，
+// 与 ssh 相同按顺序匹配所有 Host 段（包括 Host *），每个选项取第一次出现的值
+func parseImportSSHConfig(content string) ([]importSource, []string, error) {
+	var (
+		blocks   []*sshConfigBlock
+		warnings []string
+		current  = &sshConfigBlock{patterns: []string{"*"}} // 第一个 Host 之前的选项对所有主机生效
+		inMatch  bool
+	)
+	blocks = append(blocks, current)
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, args := splitSSHConfigLine(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		switch key {
+		case "host":
+			current = &sshConfigBlock{line: lineNo, patterns: args}
+			blocks = append(blocks, current)
+			inMatch = false
+		case "match":
+			warnings = append(warnings, fmt.Sprintf("第%d行：不支持 Match，已忽略该段", lineNo))
+			inMatch = true
+		case "include":
+			warnings = append(warnings, fmt.Sprintf("第%d行：不支持 Include，请将被包含的文件一并粘贴", lineNo))
+		default:
+			if !inMatch {
+				current.options = append(current.options, [2]string{key, strings.Join(args, " ")})
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	var sources []importSource
+	seen := make(map[string]bool)
+	for _, block := range blocks {
+		for _, alias := range block.patterns {
+			if strings.ContainsAny(alias, "*?!") || seen[alias] {
+				continue
+			}
+			seen[alias] = true
+			sources = append(sources, sshConfigHost(blocks, block.line, alias))
+		}
+	}
+	return sources, warnings, nil
+}
+
+// 拆分 "Keyword value"、"Keyword=value" 形式的一行，值可用双引号包裹
+func splitSSHConfigLine(line string) (string, []string) {
+	i := strings.IndexAny(line, " \t=")
+	if i < 0 {
+		return strings.ToLower(line), nil
+	}
+	key := strings.ToLower(line[:i])
+	rest := strings.TrimLeft(line[i:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	var args []string
+	for rest != "" {
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				args = append(args, rest[1:])
+				break
+			}
+			args = append(args, rest[1:end+1])
+			rest = strings.TrimLeft(rest[end+2:], " \t")
+			continue
+		}
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			args = append(args, rest)
+			break
+		}
+		args = append(args, rest[:end])
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+	return key, args
+}
+
+// 判断 Host 段的模式列表是否匹配别名，匹配任一否定模式时不匹配
+func sshConfigMatches(patterns []string, alias string) bool {
+	matched := false
+	for _, pattern := range patterns {
+		negate := strings.HasPrefix(pattern, "!")
+		ok, _ := path.Match(strings.TrimPrefix(pattern, "!"), alias)
+		if ok && negate {
+			return false
+		}
+		if ok {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// 计算别名的最终配置
+func sshConfigHost(blocks []*sshConfigBlock, line int, alias string) importSource {
+	src := importSource{line: line}
+	values := make(map[string]string)
+	for _, block := range blocks {
+		if !sshConfigMatches(block.patterns, alias) {
+			continue
+		}
+		for _, option := range block.options {
+			if _, exists := values[option[0]]; !exists {
+				values[option[0]] = option[1]
+			}
+		}
+	}
+
+	src.row.Name = alias
+	src.row.IPAddress = alias
+	if hostname := values["hostname"]; hostname != "" {
+		hostname = strings.ReplaceAll(hostname, "%h", alias)
+		src.row.IPAddress = strings.ReplaceAll(hostname, "%%", "%")
+	}
+	src.row.Username = values["user"]
+	if port := values["port"]; port != "" {
+		if src.row.Port, _ = strconv.Atoi(port); src.row.Port == 0 {
+			src.row.Port = -1
+		}
+	}
+	src.row.IdentityFile = values["identityfile"]
+
+	if jump := values["proxyjump"]; jump != "" && !strings.EqualFold(jump, "none") {
+		hops := strings.Split(jump, ",")
+		last := strings.TrimSpace(hops[len(hops)-1])
+		if len(hops) > 1 {
+			src.warnings = append(src.warnings, fmt.Sprintf("多级 ProxyJump 只使用最后一跳 %s，请确认其自身已配置前面的跳板机", last))
+		}
+		// user@host:port 形式只取主机部分，用于匹配别名或已有主机
+		if i := strings.LastIndex(last, "@"); i >= 0 {
+			last = last[i+1:]
+		}
+		if host, _, ok := strings.Cut(last, ":"); ok && !strings.Contains(host, "[") {
+			last = host
+		}
+		src.row.JumpHost = last
+	}
+	if values["proxycommand"] != "" && !strings.EqualFold(values["proxycommand"], "none") {
+		src.warnings = append(src.warnings, "不支持 ProxyCommand，已忽略")
+	}
+	return src
+}
+
+// 导入计划中的一台主机
+type importPlan struct {
+	result   *models.HostImportRowResult
+	row      models.HostImportRow
+	host     models.Host
+	tags     []string
+	jumpPlan *importPlan // 跳板机为同批导入的主机
+}
+
+// ImportHosts 解析、校验导入文件，可选测试连接；非预览且所有行有效时在一个事务中写入
+func (s *SSHService) ImportHosts(req models.HostImportRequest) (*models.HostImportResponse, error) {
+	switch req.OnConflict {
+	case "":
+		req.OnConflict = "error"
+	case "error", "skip", "update":
+	default:
+		return nil, errors.New("on_conflict 只能为 error、skip 或 update")
+	}
+
+	sources, warnings, err := ParseHostImport(req.Format, req.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := buildImportPlans(sources, &req)
+
+	if req.TestConnection {
+		s.testImportConnections(plans)
+	}
+
+	resp := &models.HostImportResponse{DryRun: req.DryRun, Total: len(plans), Warnings: warnings}
+	for _, plan := range plans {
+		plan.result.Valid = len(plan.result.Errors) == 0
+		if plan.result.Valid {
+			resp.Valid++
+		} else {
+			resp.Invalid++
+		}
+	}
+
+	if !req.DryRun && resp.Invalid == 0 {
+		if err := commitImport(plans); err != nil {
+			return nil, fmt.Errorf("写入失败，已全部回滚: %v", err)
+		}
+		resp.Committed = true
+	}
+
+	for _, plan := range plans {
+		switch plan.result.Action {
+		case "create":
+			resp.Created++
+		case "update":
+			resp.Updated++
+		case "skip":
+			resp.Skipped++
+		}
+		resp.Rows = append(resp.Rows, *plan.result)
+	}
+	return resp, nil
+}
+
+// 套用默认值和私钥文件，校验每一行并解析跳板机、重名等关系
+func buildImportPlans(sources []importSource, req *models.HostImportRequest) []*importPlan {
+	var existing []models.Host
+	config.DB.Find(&existing)
+	existingByName := make(map[string][]*models.Host)
+	for i := range existing {
+		existingByName[existing[i].Name] = append(existingByName[existing[i].Name], &existing[i])
+	}
+
+	plans := make([]*importPlan, 0, len(sources))
+	byName := make(map[string]*importPlan)
+	for _, src := range sources {
+		row := src.row
+		applyImportDefaults(&row, &req.Defaults)
+
+		plan := &importPlan{
+			row: row,
+			result: &models.HostImportRowResult{
+				Row:       src.line,
+				Name:      row.Name,
+				IPAddress: row.IPAddress,
+				Action:    "create",
+				Warnings:  src.warnings,
+			},
+		}
+		plans = append(plans, plan)
+		addError := func(format string, args ...interface{}) {
+			plan.result.Errors = append(plan.result.Errors, fmt.Sprintf(format, args...))
+		}
+
+		if row.Name == "" {
+			addError("缺少主机名")
+		} else if byName[row.Name] != nil {
+			addError("与第%d行主机名重复", byName[row.Name].result.Row)
+		} else {
+			byName[row.Name] = plan
+		}
+
+		// 与已有主机重名
+		var current *models.Host
+		if matches := existingByName[row.Name]; len(matches) > 0 && row.Name != "" {
+			switch {
+			case len(matches) > 1:
+				addError("已有多台名为 %s 的主机", row.Name)
+			case req.OnConflict == "skip":
+				plan.result.Action = "skip"
+				plan.result.HostID = matches[0].ID
+				continue
+			case req.OnConflict == "update":
+				plan.result.Action = "update"
+				plan.result.HostID = matches[0].ID
+				current = matches[0]
+			default:
+				addError("已存在同名主机（可设置 on_conflict 为 skip 或 update）")
+			}
+		}
+
+		if row.IdentityFile != "" && row.PrivateKey == "" {
+			if key, ok := lookupIdentityFile(req.IdentityFiles, row.IdentityFile); ok {
+				row.PrivateKey = key
+			} else {
+				plan.result.Warnings = append(plan.result.Warnings, fmt.Sprintf("未提供私钥文件 %s 的内容", row.IdentityFile))
+			}
+		}
+		if row.Port == 0 {
+			row.Port = 22
+		}
+		plan.row = row
+
+		if current != nil {
+			// 更新时保留主机的其他信息，未填写的凭据、代理、sudo、跳板机和分组沿用原值
+			plan.host = *current
+			if current.IPAddress != row.IPAddress || current.Port != row.Port {
+				plan.host.HostKey = ""
+			}
+			if row.Password != "" || row.PrivateKey != "" {
+				plan.host.Password = row.Password
+				plan.host.PrivateKey = row.PrivateKey
+			}
+			if row.SudoPassword != "" {
+				plan.host.SudoPassword = row.SudoPassword
+			}
+		} else {
+			plan.host = models.Host{Password: row.Password, PrivateKey: row.PrivateKey, SudoPassword: row.SudoPassword}
+		}
+		plan.host.Name = row.Name
+		plan.host.IPAddress = row.IPAddress
+		plan.host.Port = row.Port
+		plan.host.Username = row.Username
+		if current == nil || row.ProxyURL != "" {
+			plan.host.ProxyURL = row.ProxyURL
+		}
+		if row.UseSudo != nil {
+			plan.host.UseSudo = *row.UseSudo
+		}
+		if current == nil || row.JumpHost != "" {
+			plan.host.JumpHostID = nil
+		}
+
+		if row.IPAddress == "" || strings.ContainsAny(row.IPAddress, " \t/") {
+			addError("缺少或无效的地址")
+		}
+		if row.Port < 1 || row.Port > 65535 {
+			addError("无效的端口")
+		}
+		if row.Username == "" {
+			addError("缺少用户名")
+		}
+		if plan.host.Password == "" && plan.host.PrivateKey == "" {
+			addError("缺少密码或私钥")
+		}
+		if row.PrivateKey != "" {
+			if _, err := ssh.ParsePrivateKey([]byte(row.PrivateKey)); err != nil {
+				addError("私钥无效: %v", err)
+			}
+		}
+		if err := ValidateProxyURL(&plan.host); err != nil {
+			addError("%v", err)
+		}
+		for _, name := range row.Tags {
+			if normalized, err := NormalizeTagName(name); err != nil {
+				addError("%v", err)
+			} else {
+				plan.tags = append(plan.tags, normalized)
+			}
+		}
+		if row.Group != "" && normalizeGroupPath(row.Group) == "" {
+			addError("无效的分组路径: %s", row.Group)
+		}
+	}
+
+	// 跳板机优先匹配同批导入的主机，其次匹配已有主机
+	for _, plan := range plans {
+		jump := plan.row.JumpHost
+		if jump == "" || plan.result.Action == "skip" {
+			continue
+		}
+		if target := byName[jump]; target != nil && target.result.Action != "skip" {
+			if target == plan {
+				plan.result.Errors = append(plan.result.Errors, "不能将主机自身设为跳板机")
+				continue
+			}
+			plan.jumpPlan = target
+			continue
+		}
+		matches := existingByName[jump]
+		if len(matches) == 0 {
+			for i := range existing {
+				if existing[i].IPAddress == jump {
+					matches = append(matches, &existing[i])
+				}
+			}
+		}
+		switch len(matches) {
+		case 0:
+			plan.result.Errors = append(plan.result.Errors, fmt.Sprintf("跳板机 %s 不存在", jump))
+		case 1:
+			id := matches[0].ID
+			plan.host.JumpHostID = &id
+			if err := ValidateJumpHost(&plan.host); err != nil {
+				plan.result.Errors = append(plan.result.Errors, err.Error())
+			}
+		default:
+			plan.result.Errors = append(plan.result.Errors, fmt.Sprintf("跳板机 %s 匹配到多台主机", jump))
+		}
+	}
+
+	// 同批主机之间的跳板机不能成环
+	for _, plan := range plans {
+		seen := map[*importPlan]bool{plan: true}
+		for p := plan.jumpPlan; p != nil; p = p.jumpPlan {
+			if seen[p] {
+				plan.result.Errors = append(plan.result.Errors, "跳板机配置存在循环引用")
+				plan.jumpPlan = nil
+				break
+			}
+			seen[p] = true
+		}
+	}
+	// 更新的主机沿用原跳板机时，跳板机链可能经过同批更新的主机，按更新后的配置检查
+	updated := make(map[uint]*importPlan)
+	for _, plan := range plans {
+		if plan.result.Action == "update" {
+			updated[plan.result.HostID] = plan
+		}
+	}
+	for _, plan := range plans {
+		seen := map[*importPlan]bool{}
+		p := plan
+		for p != nil && !seen[p] {
+			seen[p] = true
+			next := p.jumpPlan
+			if next == nil && p.host.JumpHostID != nil {
+				next = updated[*p.host.JumpHostID]
+			}
+			p = next
+		}
+		if p == plan && len(plan.result.Errors) == 0 {
+			plan.result.Errors = append(plan.result.Errors, "跳板机配置存在循环引用")
+		}
+	}
+	// 跳板机所在行无效时，依赖它的行也无法导入
+	for _, plan := range plans {
+		if plan.jumpPlan != nil && len(plan.jumpPlan.result.Errors) > 0 {
+			plan.result.Errors = append(plan.result.Errors, fmt.Sprintf("跳板机 %s 所在行无效", plan.jumpPlan.row.Name))
+		}
+	}
+	return plans
+}
+
+func applyImportDefaults(row, defaults *models.HostImportRow) {
+	if row.Name == "" {
+		row.Name = row.IPAddress
+	}
+	if row.Port == 0 {
+		row.Port = defaults.Port
+	}
+	if row.Username == "" {
+		row.Username = defaults.Username
+	}
+	if row.Password == "" && row.PrivateKey == "" && row.IdentityFile == "" {
+		row.Password = defaults.Password
+		row.PrivateKey = defaults.PrivateKey
+		row.IdentityFile = defaults.IdentityFile
+	}
+	if row.JumpHost == "" {
+		row.JumpHost = defaults.JumpHost
+	}
+	if row.ProxyURL == "" {
+		row.ProxyURL = defaults.ProxyURL
+	}
+	if row.UseSudo == nil {
+		row.UseSudo = defaults.UseSudo
+	}
+	if row.SudoPassword == "" {
+		row.SudoPassword = defaults.SudoPassword
+	}
+	if row.Group == "" {
+		row.Group = defaults.Group
+	}
+	row.Tags = append(append(models.TagList{}, defaults.Tags...), row.Tags...)
+}
+
+// 按完整路径查找私钥内容，找不到时按文件名匹配
+func lookupIdentityFile(files map[string]string, file string) (string, bool) {
+	if key, ok := files[file]; ok {
+		return key, true
+	}
+	base := path.Base(file)
+	for name, key := range files {
+		if path.Base(name) == base {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// 分组路径各级去掉首尾空白，空路径或含空级别时返回空
+func normalizeGroupPath(groupPath string) string {
+	parts := strings.Split(strings.Trim(strings.TrimSpace(groupPath), "/"), "/")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+		if parts[i] == "" {
+			return ""
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// 并发测试所有有效行的连接，跳板机为同批主机时经由其连接
+func (s *SSHService) testImportConnections(plans []*importPlan) {
+	// 先构造所有跳板机链，测试期间各协程只访问自己链上的副本，不读写 plan.host
+	type importTest struct {
+		plan  *importPlan
+		chain []*models.Host
+	}
+	var tests []importTest
+	for _, plan := range plans {
+		if len(plan.result.Errors) > 0 || plan.result.Action == "skip" {
+			continue
+		}
+		chain, err := importJumpChain(plan)
+		if err != nil {
+			plan.result.Connection = err.Error()
+			plan.result.Errors = append(plan.result.Errors, "连接失败: "+err.Error())
+			continue
+		}
+
+		// 目标主机用不带ID的副本测试，预览时不把主机密钥写入已有主机
+		target := plan.host
+		target.ID = 0
+		chain[len(chain)-1] = &target
+		tests = append(tests, importTest{plan: plan, chain: chain})
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, importTestConcurrency)
+	for _, test := range tests {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(test importTest) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if err := s.testChain(test.chain); err != nil {
+				test.plan.result.Connection = err.Error()
+				test.plan.result.Errors = append(test.plan.result.Errors, "连接失败: "+err.Error())
+				return
+			}
+			test.plan.result.Connection = "ok"
+		}(test)
+	}
+	wg.Wait()
+
+	// 测试时记录的主机密钥随主机一起保存，连接成功的主机标记为在线
+	for _, test := range tests {
+		if test.plan.result.Connection == "ok" {
+			test.plan.host.HostKey = test.chain[len(test.chain)-1].HostKey
+			test.plan.host.Status = "online"
+		}
+	}
+}
+
+// 构造测试连接用的跳板机链，同批导入的跳板机使用副本，以免并发测试时互相写入主机密钥
+func importJumpChain(plan *importPlan) ([]*models.Host, error) {
+	chain := []*models.Host{&plan.host}
+	last := plan
+	for p := plan.jumpPlan; p != nil; p = p.jumpPlan {
+		host := p.host
+		chain = append([]*models.Host{&host}, chain...)
+		last = p
+		if len(chain) > maxJumpHops+1 {
+			return nil, fmt.Errorf("跳板机链超过%d跳", maxJumpHops)
+		}
+	}
+	if last.host.JumpHostID != nil {
+		dbChain, err := resolveJumpChain(&last.host)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(dbChain[:len(dbChain)-1], chain...)
+	}
+	return chain, nil
+}
+
+// 连接跳板机链并执行一个简单命令
+func (s *SSHService) testChain(chain []*models.Host) error {
+	client, err := s.connectChain(chain)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+	return session.Run("true")
+}
+
+// 在一个事务中创建分组、标签和主机，跳板机先于依赖它的主机写入
+func commitImport(plans []*importPlan) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		groupIDs := make(map[string]*uint)
+		written := make(map[*importPlan]bool)
+
+		var write func(plan *importPlan) error
+		write = func(plan *importPlan) error {
+			if written[plan] || plan.result.Action == "skip" {
+				return nil
+			}
+			written[plan] = true
+			if plan.jumpPlan != nil {
+				if err := write(plan.jumpPlan); err != nil {
+					return err
+				}
+				plan.host.JumpHostID = &plan.jumpPlan.host.ID
+			}
+
+			if groupPath := normalizeGroupPath(plan.row.Group); groupPath != "" {
+				id, ok := groupIDs[groupPath]
+				if !ok {
+					var err error
+					if id, err = ensureGroupPath(tx, groupPath); err != nil {
+						return err
+					}
+					groupIDs[groupPath] = id
+				}
+				plan.host.GroupID = id
+			}
+
+			if plan.host.Status == "" {
+				plan.host.Status = "unknown"
+			}
+			if err := tx.Save(&plan.host).Error; err != nil {
+				return fmt.Errorf("写入主机 %s 失败: %v", plan.host.Name, err)
+			}
+			plan.result.HostID = plan.host.ID
+
+			if len(plan.tags) > 0 || plan.result.Action == "create" {
+				tags, err := ResolveTags(tx, plan.tags)
+				if err != nil {
+					return err
+				}
+				if err := tx.Model(&plan.host).Association("Tags").Replace(tags); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for _, plan := range plans {
+			if err := write(plan); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 按路径逐级查找分组，不存在的自动创建，返回最后一级的ID
+func ensureGroupPath(tx *gorm.DB, groupPath string) (*uint, error) {
+	var parentID *uint
+	for _, name := range strings.Split(groupPath, "/") {
+		query := tx.Where("name = ?", name)
+		if parentID == nil {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", *parentID)
+		}
+
+		var group models.HostGroup
+		result := query.Limit(1).Find(&group)
+		err := result.Error
+		if err == nil && result.RowsAffected == 0 {
+			group = models.HostGroup{Name: name, ParentID: parentID}
+			err = tx.Create(&group).Error
+		}
+		if err != nil {
+			return nil, fmt.Errorf("创建分组 %s 失败: %v", groupPath, err)
+		}
+		id := group.ID
+		parentID = &id
+	}
+	return parentID, nil
+}
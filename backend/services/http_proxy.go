@@ -0,0 +1,483 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// 主机SSH连接空闲多久后关闭
+	httpProxyIdleTimeout = 5 * time.Minute
+	// 同一用户访问同一服务时，审计日志的记录间隔
+	httpProxyAuditInterval = 30 * time.Minute
+	// 代理链接中的一次性token有效期，打开后换成会话Cookie
+	httpProxyLinkTTL = time.Minute
+	// 代理会话Cookie的有效期，过期后需重新从管理界面打开
+	httpProxySessionTTL = time.Hour
+)
+
+// 代理token的用途
+const (
+	proxyTokenLink    = "link"
+	proxyTokenSession = "session"
+)
+
+// HTTPProxyService 经SSH将HTTP(S)/WebSocket请求反向代理到主机回环地址上的服务
+type HTTPProxyService struct {
+	sshService   *SSHService
+	auditService *AuditService
+
+	mu        sync.Mutex
+	clients   map[uint]*proxyClient
+	lastAudit map[string]time.Time
+}
+
+type proxyClient struct {
+	client    *ssh.Client
+	transport *http.Transport
+	lastUsed  time.Time
+}
+
+var (
+	httpProxyOnce     sync.Once
+	httpProxyInstance *HTTPProxyService
+)
+
+// GetHTTPProxyService 返回全局反向代理服务
+func GetHTTPProxyService() *HTTPProxyService {
+	httpProxyOnce.Do(func() {
+		httpProxyInstance = &HTTPProxyService{
+			sshService:   NewSSHService(),
+			auditService: NewAuditService(),
+			clients:      make(map[uint]*proxyClient),
+			lastAudit:    make(map[string]time.Time),
+		}
+		go httpProxyInstance.reapIdle()
+	})
+	return httpProxyInstance
+}
+
+// ProxyTarget 解析后的代理目标
+type ProxyTarget struct {
+	HostID uint
+	Port   int
+	HTTPS  bool
+	Prefix string // 代理路径前缀，如 /proxy/1/3000
+}
+
+// ProxyDomain 反向代理使用的独立域名（可带端口，如 proxy.example.com:3000），
+// 被代理的页面只在该域名下提供，与管理界面不同源，无法读取本系统的登录token或调用API；为空表示未启用反向代理
+func ProxyDomain() string {
+	return strings.TrimSuffix(strings.ToLower(os.Getenv("PROXY_DOMAIN")), ".")
+}
+
+// IsProxyHost 请求的 Host 是否为反向代理域名（不比较端口）
+func IsProxyHost(requestHost string) bool {
+	domain := ProxyDomain()
+	if domain == "" {
+		return false
+	}
+	return hostnameOf(strings.ToLower(requestHost)) == hostnameOf(domain)
+}
+
+func hostnameOf(hostport string) string {
+	if host, _, err := net.SplitHostPort(hostport); err == nil {
+		return host
+	}
+	return strings.Trim(hostport, "[]")
+}
+
+// ParseProxyTarget 解析路径中的主机ID和端口，端口可写作 https-8443 表示目标为HTTPS
+func ParseProxyTarget(hostID, port string) (*ProxyTarget, error) {
+	id, err := strconv.ParseUint(hostID, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("无效的主机ID")
+	}
+
+	target := &ProxyTarget{HostID: uint(id), Prefix: fmt.Sprintf("/proxy/%s/%s", hostID, port)}
+	if strings.HasPrefix(port, "https-") {
+		target.HTTPS = true
+		port = strings.TrimPrefix(port, "https-")
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p <= 0 || p > 65535 {
+		return nil, fmt.Errorf("无效的端口")
+	}
+	target.Port = p
+	return target, nil
+}
+
+// NewProxyTarget 校验端口并创建代理目标
+func NewProxyTarget(hostID uint, port int, https bool) (*ProxyTarget, error) {
+	portPart := strconv.Itoa(port)
+	if https {
+		portPart = "https-" + portPart
+	}
+	return ParseProxyTarget(strconv.FormatUint(uint64(hostID), 10), portPart)
+}
+
+// 代理token的签名密钥，未配置 PROXY_SECRET 时每次启动随机生成（重启后已签发的token失效）
+var (
+	proxySecretMu sync.Mutex
+	proxySecret   []byte
+)
+
+func proxyTokenSecret() ([]byte, error) {
+	proxySecretMu.Lock()
+	defer proxySecretMu.Unlock()
+
+	if proxySecret != nil {
+		return proxySecret, nil
+	}
+	if v := os.Getenv("PROXY_SECRET"); v != "" {
+		proxySecret = []byte(v)
+		return proxySecret, nil
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("生成代理token密钥失败: %v", err)
+	}
+	proxySecret = secret
+	return proxySecret, nil
+}
+
+// 计算代理token内容的签名
+func signProxyToken(payload []byte) ([]byte, error) {
+	secret, err := proxyTokenSecret()
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+// 代理token的内容，只对一个主机端口有效，并绑定签发时使用的登录token，退出登录后随之失效
+type proxyTokenClaims struct {
+	Kind         string `json:"k"`
+	UserID       uint   `json:"u"`
+	LoginTokenID uint   `json:"t"`
+	HostID       uint   `json:"h"`
+	Port         int    `json:"p"`
+	HTTPS        bool   `json:"s,omitempty"`
+	Expires      int64  `json:"e"`
+}
+
+// 签发只对该代理目标有效的短期token
+func issueProxyToken(claims proxyTokenClaims, target *ProxyTarget, ttl time.Duration) (string, time.Time, error) {
+	expires := time.Now().Add(ttl)
+	claims.HostID, claims.Port, claims.HTTPS = target.HostID, target.Port, target.HTTPS
+	claims.Expires = expires.Unix()
+	payload, _ := json.Marshal(claims)
+
+	signature, err := signProxyToken(payload)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(signature), expires, nil
+}
+
+// 校验token的签名、用途、有效期和代理目标，以及签发给的用户和登录token是否仍然有效
+func verifyProxyToken(token string, target *ProxyTarget, kind string) (*models.User, *proxyTokenClaims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, nil, errors.New("无效的代理token")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, nil, errors.New("无效的代理token")
+	}
+	sum, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, nil, errors.New("无效的代理token")
+	}
+	expected, err := signProxyToken(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !hmac.Equal(sum, expected) {
+		return nil, nil, errors.New("无效的代理token")
+	}
+
+	var claims proxyTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, nil, errors.New("无效的代理token")
+	}
+	if claims.Kind != kind || claims.HostID != target.HostID || claims.Port != target.Port || claims.HTTPS != target.HTTPS {
+		return nil, nil, errors.New("代理token与访问的服务不匹配")
+	}
+	if time.Now().Unix() > claims.Expires {
+		return nil, nil, errors.New("代理token已过期")
+	}
+
+	// 与登录token一样只接受启用状态的用户；退出登录或禁用后，已签发的代理token立即失效
+	var loginToken models.UserToken
+	if err := config.DB.Where("id = ? AND user_id = ? AND expires_at > ?", claims.LoginTokenID, claims.UserID, time.Now()).First(&loginToken).Error; err != nil {
+		return nil, nil, errors.New("登录已失效，请重新登录后打开")
+	}
+	var user models.User
+	if err := config.DB.Where("id = ? AND status = ?", claims.UserID, "active").First(&user).Error; err != nil {
+		return nil, nil, errors.New("用户不存在或已被禁用")
+	}
+	return &user, &claims, nil
+}
+
+// ProxyLink 用当前的登录token生成打开代理目标的一次性链接，scheme 为代理域名使用的协议
+func ProxyLink(loginToken string, target *ProxyTarget, scheme string) (string, time.Time, error) {
+	var record models.UserToken
+	if err := config.DB.Where("token = ?", loginToken).First(&record).Error; err != nil {
+		return "", time.Time{}, errors.New("无效的token")
+	}
+
+	token, expires, err := issueProxyToken(proxyTokenClaims{
+		Kind:         proxyTokenLink,
+		UserID:       record.UserID,
+		LoginTokenID: record.ID,
+	}, target, httpProxyLinkTTL)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fmt.Sprintf("%s://%s%s/?proxy_token=%s", scheme, ProxyDomain(), target.Prefix, url.QueryEscape(token)), expires, nil
+}
+
+// ExchangeProxyLink 校验链接中的token，换成保存在只对该目标路径有效的Cookie中的会话token
+func ExchangeProxyLink(linkToken string, target *ProxyTarget) (string, time.Time, error) {
+	_, claims, err := verifyProxyToken(linkToken, target, proxyTokenLink)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claims.Kind = proxyTokenSession
+	return issueProxyToken(*claims, target, httpProxySessionTTL)
+}
+
+// VerifyProxySession 校验代理会话token，返回访问的用户
+func VerifyProxySession(sessionToken string, target *ProxyTarget) (*models.User, error) {
+	user, _, err := verifyProxyToken(sessionToken, target, proxyTokenSession)
+	return user, err
+}
+
+// 获取（或建立）到主机的SSH连接及对应的HTTP传输层
+func (h *HTTPProxyService) transportFor(host *models.Host) (*http.Transport, error) {
+	h.mu.Lock()
+	if pc, ok := h.clients[host.ID]; ok {
+		pc.lastUsed = time.Now()
+		h.mu.Unlock()
+		return pc.transport, nil
+	}
+	h.mu.Unlock()
+
+	// 建立连接耗时较长，不持有锁
+	client, err := h.sshService.createConnection(host)
+	if err != nil {
+		return nil, err
+	}
+
+	pc := &proxyClient{client: client, lastUsed: time.Now()}
+	pc.transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return client.Dial("tcp", addr)
+		},
+		// 内网服务多为自签名证书
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+		MaxIdleConnsPerHost: 8,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	h.mu.Lock()
+	if existing, ok := h.clients[host.ID]; ok {
+		// 并发请求已建立了连接
+		h.mu.Unlock()
+		client.Close()
+		return existing.transport, nil
+	}
+	h.clients[host.ID] = pc
+	h.mu.Unlock()
+
+	// SSH连接断开后移除缓存，下次请求重新建立
+	go func() {
+		client.Wait()
+		h.mu.Lock()
+		if h.clients[host.ID] == pc {
+			delete(h.clients, host.ID)
+		}
+		h.mu.Unlock()
+		pc.transport.CloseIdleConnections()
+	}()
+
+	return pc.transport, nil
+}
+
+func (h *HTTPProxyService) reapIdle() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.mu.Lock()
+		for id, pc := range h.clients {
+			if time.Since(pc.lastUsed) > httpProxyIdleTimeout {
+				delete(h.clients, id)
+				pc.transport.CloseIdleConnections()
+				pc.client.Close()
+			}
+		}
+		for key, t := range h.lastAudit {
+			if time.Since(t) > httpProxyAuditInterval {
+				delete(h.lastAudit, key)
+			}
+		}
+		h.mu.Unlock()
+	}
+}
+
+// 记录代理访问审计，同一用户对同一服务在间隔内只记录一次，WebSocket每次都记录
+func (h *HTTPProxyService) audit(user *models.User, target *ProxyTarget, r *http.Request, websocket bool) {
+	action := "proxy.session"
+	if websocket {
+		action = "proxy.websocket"
+	} else {
+		key := fmt.Sprintf("%d:%d:%d", user.ID, target.HostID, target.Port)
+		h.mu.Lock()
+		last, ok := h.lastAudit[key]
+		if ok && time.Since(last) < httpProxyAuditInterval {
+			h.mu.Unlock()
+			return
+		}
+		h.lastAudit[key] = time.Now()
+		h.mu.Unlock()
+	}
+
+	h.auditService.RecordAction(user.ID, target.HostID, action, fmt.Sprintf("127.0.0.1:%d", target.Port),
+		fmt.Sprintf("%s %s from %s", r.Method, r.URL.Path, r.RemoteAddr), nil)
+}
+
+// ServeHTTP 将请求代理到目标主机，path为去掉前缀后的路径
+func (h *HTTPProxyService) ServeHTTP(w http.ResponseWriter, r *http.Request, user *models.User, target *ProxyTarget, path string) {
+	var host models.Host
+	if err := config.DB.First(&host, target.HostID).Error; err != nil {
+		http.Error(w, "主机不存在", http.StatusNotFound)
+		return
+	}
+
+	transport, err := h.transportFor(&host)
+	if err != nil {
+		http.Error(w, "连接主机失败: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	isWebSocket := strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+	h.audit(user, target, r, isWebSocket)
+
+	scheme := "http"
+	if target.HTTPS {
+		scheme = "https"
+	}
+	upstream := &url.URL{Scheme: scheme, Host: fmt.Sprintf("127.0.0.1:%d", target.Port)}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = upstream.Scheme
+			req.URL.Host = upstream.Host
+			req.URL.Path = path
+			req.URL.RawPath = ""
+			req.Host = upstream.Host
+			req.Header.Set("X-Forwarded-Prefix", target.Prefix)
+			req.Header.Set("X-Forwarded-User", user.Username)
+		},
+		Transport: transport,
+		ModifyResponse: func(resp *http.Response) error {
+			rewriteProxyResponse(resp, upstream, target.Prefix)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, req *http.Request, err error) {
+			http.Error(w, "代理请求失败: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// 将重定向地址和Cookie路径改写到代理前缀下
+func rewriteProxyResponse(resp *http.Response, upstream *url.URL, prefix string) {
+	if location := resp.Header.Get("Location"); location != "" {
+		if u, err := url.Parse(location); err == nil {
+			if u.Host == "" && strings.HasPrefix(u.Path, "/") {
+				resp.Header.Set("Location", prefix+location)
+			} else if isLoopbackURL(u, upstream) {
+				u.Scheme = ""
+				u.Host = ""
+				u.Path = prefix + u.Path
+				resp.Header.Set("Location", u.String())
+			}
+		}
+	}
+
+	cookies := resp.Header.Values("Set-Cookie")
+	if len(cookies) > 0 {
+		resp.Header.Del("Set-Cookie")
+		for _, cookie := range cookies {
+			resp.Header.Add("Set-Cookie", rewriteCookiePath(cookie, prefix))
+		}
+	}
+}
+
+func isLoopbackURL(u, upstream *url.URL) bool {
+	hostname := u.Hostname()
+	if hostname != "127.0.0.1" && hostname != "localhost" {
+		return false
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return port == upstream.Port()
+}
+
+func rewriteCookiePath(cookie, prefix string) string {
+	parts := strings.Split(cookie, ";")
+	found := false
+	for i, part := range parts {
+		trimmed := strings.TrimSpace(part)
+		if len(trimmed) >= 5 && strings.EqualFold(trimmed[:5], "path=") {
+			parts[i] = " Path=" + prefix + trimmed[5:]
+			found = true
+		}
+		// 目标服务的Domain属性对代理域名无效
+		if len(trimmed) >= 7 && strings.EqualFold(trimmed[:7], "domain=") {
+			parts[i] = ""
+		}
+	}
+	if !found {
+		parts = append(parts, " Path="+prefix+"/")
+	}
+
+	var kept []string
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, ";")
+}
@@ -0,0 +1,263 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"host-manager/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// 一次最多同时跟踪的日志源（主机 × 文件/单元）
+	maxLogTailSources = 20
+	// 待发送行的缓冲，客户端处理不过来时超出部分丢弃
+	logTailBuffer = 2000
+	// 单行最大长度，超过部分拆成多行
+	logTailMaxLine = 64 * 1024
+)
+
+// LogTailOptions 日志跟踪参数，文件和单元会在每台主机上分别跟踪
+type LogTailOptions struct {
+	Files      []string
+	Units      []string
+	Containers []string       // Docker 容器
+	Lines      int            // 开始时先输出的历史行数
+	Grep       *regexp.Regexp // 只转发匹配的行，为空不过滤
+	Invert     bool           // 只转发不匹配的行
+	Prefix     bool           // 每行前加 [主机:文件] 前缀
+}
+
+// LogTail 一组正在跟踪的日志源，各源的输出合并到同一个通道
+type LogTail struct {
+	lines   chan string
+	dropped int64
+
+	mu       sync.Mutex
+	clients  []*ssh.Client
+	sessions []*ssh.Session
+	stdins   []io.WriteCloser
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// 单个日志源
+type logSource struct {
+	host    *models.Host
+	label   string // 前缀中的文件或单元名
+	command string
+}
+
+// ValidateLogFile 校验日志文件路径
+func ValidateLogFile(file string) error {
+	if !path.IsAbs(file) || len(file) > 4096 {
+		return fmt.Errorf("日志文件必须为绝对路径: %s", file)
+	}
+	for _, r := range file {
+		if r < 0x20 {
+			return fmt.Errorf("无效的日志文件路径: %q", file)
+		}
+	}
+	return nil
+}
+
+// 远程命令：跟踪命令在后台运行，标准输入关闭（即SSH会话关闭）时将其杀掉，
+// 不依赖PTY的SIGHUP和服务端是否支持 signal 请求。
+// 非交互 shell 会把后台命令的标准输入重定向到 /dev/null，因此先通过 fd 3 保留会话的标准输入
+func logTailCommand(command string) string {
+	return fmt.Sprintf("exec 3<&0; %s 2>&1 </dev/null & pid=$!; (cat <&3 >/dev/null; kill $pid) >/dev/null 2>&1 & wait $pid", command)
+}
+
+// StartLogTail 在多台主机上开始跟踪日志，每台主机一条SSH连接，每个日志源一个会话
+func (s *SSHService) StartLogTail(hosts []models.Host, opts LogTailOptions) (*LogTail, error) {
+	perHost := len(opts.Files) + len(opts.Units) + len(opts.Containers)
+	if perHost == 0 {
+		return nil, errors.New("至少需要指定一个日志文件、systemd 单元或容器")
+	}
+	if len(hosts)*perHost > maxLogTailSources {
+		return nil, fmt.Errorf("同时跟踪的日志源不能超过%d个", maxLogTailSources)
+	}
+	if opts.Lines < 0 {
+		opts.Lines = 0
+	}
+	lines := strconv.Itoa(opts.Lines)
+
+	var sources []logSource
+	for i := range hosts {
+		host := &hosts[i]
+		for _, file := range opts.Files {
+			if err := ValidateLogFile(file); err != nil {
+				return nil, err
+			}
+			sources = append(sources, logSource{
+				host:    host,
+				label:   file,
+				command: "tail -F -n " + lines + " -- " + shellQuote(file),
+			})
+		}
+		for _, unit := range opts.Units {
+			if err := ValidateSystemdUnit(unit); err != nil {
+				return nil, err
+			}
+			sources = append(sources, logSource{
+				host:    host,
+				label:   unit,
+				command: "journalctl -f --no-pager -o short-iso -n " + lines + " -u " + shellQuote(unit),
+			})
+		}
+		for _, container := range opts.Containers {
+			if err := ValidateDockerContainer(container); err != nil {
+				return nil, err
+			}
+			sources = append(sources, logSource{
+				host:    host,
+				label:   "docker:" + container,
+				command: "docker logs -f --tail " + lines + " " + shellQuote(container),
+			})
+		}
+	}
+
+	t := &LogTail{lines: make(chan string, logTailBuffer)}
+
+	clients := make(map[uint]*ssh.Client)
+	for _, src := range sources {
+		client, ok := clients[src.host.ID]
+		if !ok {
+			var err error
+			client, err = s.createConnection(src.host)
+			if err != nil {
+				t.Close()
+				return nil, fmt.Errorf("连接主机 %s 失败: %v", src.host.Name, err)
+			}
+			clients[src.host.ID] = client
+			t.clients = append(t.clients, client)
+		}
+
+		if err := t.start(client, src, opts); err != nil {
+			t.Close()
+			return nil, fmt.Errorf("在主机 %s 上跟踪 %s 失败: %v", src.host.Name, src.label, err)
+		}
+	}
+
+	// 所有日志源结束后关闭通道
+	go func() {
+		t.wg.Wait()
+		close(t.lines)
+	}()
+
+	return t, nil
+}
+
+func (t *LogTail) start(client *ssh.Client, src logSource, opts LogTailOptions) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return err
+	}
+
+	command, password := privilegedCommand(src.host, logTailCommand(src.command))
+	if err := session.Start(command); err != nil {
+		session.Close()
+		return err
+	}
+	if password != "" {
+		stdin.Write([]byte(password))
+	}
+
+	t.mu.Lock()
+	t.sessions = append(t.sessions, session)
+	t.stdins = append(t.stdins, stdin)
+	t.mu.Unlock()
+
+	prefix := ""
+	if opts.Prefix {
+		prefix = fmt.Sprintf("[%s:%s] ", src.host.Name, src.label)
+	}
+
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer session.Close()
+
+		reader := bufio.NewReaderSize(stdout, logTailMaxLine)
+		for {
+			line, err := reader.ReadSlice('\n')
+			if len(line) > 0 {
+				text := string(trimNewline(line))
+				if opts.Grep == nil || opts.Grep.MatchString(text) != opts.Invert {
+					t.push(prefix + text)
+				}
+			}
+			if err != nil && err != bufio.ErrBufferFull {
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func trimNewline(b []byte) []byte {
+	if n := len(b); n > 0 && b[n-1] == '\n' {
+		b = b[:n-1]
+		if n := len(b); n > 0 && b[n-1] == '\r' {
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// 缓冲已满说明客户端处理不过来，丢弃并计数，不阻塞远程命令的输出
+func (t *LogTail) push(line string) {
+	select {
+	case t.lines <- line:
+	default:
+		atomic.AddInt64(&t.dropped, 1)
+	}
+}
+
+// Lines 合并后的输出，所有日志源结束后关闭
+func (t *LogTail) Lines() <-chan string {
+	return t.lines
+}
+
+// TakeDropped 返回自上次调用以来丢弃的行数
+func (t *LogTail) TakeDropped() int64 {
+	return atomic.SwapInt64(&t.dropped, 0)
+}
+
+// Close 关闭标准输入使远程跟踪进程退出，然后关闭会话和连接
+func (t *LogTail) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return
+	}
+	t.closed = true
+
+	for _, stdin := range t.stdins {
+		stdin.Close()
+	}
+	for _, session := range t.sessions {
+		session.Close()
+	}
+	for _, client := range t.clients {
+		client.Close()
+	}
+}
@@ -0,0 +1,372 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMetricsInterval     = 60 * time.Second
+	defaultMetricsRawRetention = 24 * time.Hour
+	defaultMetricsRetention    = 30 * 24 * time.Hour
+	// 原始采样超过保留期后按该粒度求平均
+	metricsRollupResolution = 300
+	// 同时采集的主机数上限
+	metricsConcurrency = 10
+	// 降采样每批处理的时间窗口
+	metricsRollupWindow = time.Hour
+)
+
+// 可存储和查询的指标及其取值方式
+var metricExtractors = map[string]func(*models.HostStats) float64{
+	"cpu_usage":        func(s *models.HostStats) float64 { return s.CPUUsage },
+	"memory_usage":     func(s *models.HostStats) float64 { return s.MemoryUsage },
+	"memory_used":      func(s *models.HostStats) float64 { return float64(s.MemoryUsed) },
+	"swap_usage":       func(s *models.HostStats) float64 { return s.SwapUsage },
+	"disk_usage":       func(s *models.HostStats) float64 { return s.DiskUsage },
+	"disk_used":        func(s *models.HostStats) float64 { return float64(s.DiskUsed) },
+	"network_in_rate":  func(s *models.HostStats) float64 { return s.NetworkInRate },
+	"network_out_rate": func(s *models.HostStats) float64 { return s.NetworkOutRate },
+	"load1":            func(s *models.HostStats) float64 { return s.Load1 },
+	"load5":            func(s *models.HostStats) float64 { return s.Load5 },
+	"load15":           func(s *models.HostStats) float64 { return s.Load15 },
+	"process_count":    func(s *models.HostStats) float64 { return float64(s.ProcessCount) },
+	"logged_in_users":  func(s *models.HostStats) float64 { return float64(s.LoggedInUsers) },
+}
+
+// 指标采集失败时不写入的对应错误键
+var metricErrorKeys = map[string]string{
+	"cpu_usage":        "cpu",
+	"memory_usage":     "memory",
+	"memory_used":      "memory",
+	"swap_usage":       "swap",
+	"disk_usage":       "disk",
+	"disk_used":        "disk",
+	"network_in_rate":  "network",
+	"network_out_rate": "network",
+	"load1":            "load",
+	"load5":            "load",
+	"load15":           "load",
+	"process_count":    "processes",
+	"logged_in_users":  "users",
+}
+
+// MetricNames 返回支持的指标名称
+func MetricNames() []string {
+	names := make([]string, 0, len(metricExtractors))
+	for name := range metricExtractors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MetricsCollector 定时采集所有主机的统计信息并写入数据库
+type MetricsCollector struct {
+	sshService *SSHService
+
+	interval     time.Duration
+	rawRetention time.Duration
+	retention    time.Duration
+
+	mu     sync.RWMutex
+	latest map[uint]*models.HostStats
+}
+
+var (
+	metricsCollectorOnce     sync.Once
+	metricsCollectorInstance *MetricsCollector
+)
+
+// GetMetricsCollector 返回全局指标采集器
+func GetMetricsCollector() *MetricsCollector {
+	metricsCollectorOnce.Do(func() {
+		metricsCollectorInstance = &MetricsCollector{
+			sshService:   NewSSHService(),
+			interval:     envDuration("METRICS_INTERVAL", time.Second, defaultMetricsInterval),
+			rawRetention: envDuration("METRICS_RAW_RETENTION", time.Hour, defaultMetricsRawRetention),
+			retention:    envDuration("METRICS_RETENTION", 24*time.Hour, defaultMetricsRetention),
+			latest:       make(map[uint]*models.HostStats),
+		}
+	})
+	return metricsCollectorInstance
+}
+
+// 读取整数环境变量并按单位换算，未设置或无效时使用默认值；显式设置为0表示禁用
+func envDuration(name string, unit, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("Invalid %s=%q, using default %v", name, v, def)
+		return def
+	}
+	return time.Duration(n) * unit
+}
+
+// Start 启动采集（每轮采集后评估告警规则）和降采样循环，METRICS_INTERVAL=0 时不启动
+func (m *MetricsCollector) Start() {
+	if m.interval <= 0 {
+		log.Println("Metrics collector disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			m.collectAll()
+			GetAlertManager().Evaluate()
+			<-ticker.C
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			m.compact()
+			<-ticker.C
+		}
+	}()
+
+	log.Printf("Metrics collector started (interval %v)", m.interval)
+}
+
+// Interval 返回采集间隔
+func (m *MetricsCollector) Interval() time.Duration {
+	return m.interval
+}
+
+// Latest 返回主机最近一次采集到的统计信息
+func (m *MetricsCollector) Latest(hostID uint) *models.HostStats {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.latest[hostID]
+}
+
+func (m *MetricsCollector) collectAll() {
+	var hosts []models.Host
+	if err := config.DB.Find(&hosts).Error; err != nil {
+		log.Printf("Failed to load hosts for metrics: %v", err)
+		return
+	}
+
+	// 清理已删除主机的缓存
+	present := make(map[uint]bool, len(hosts))
+	for _, host := range hosts {
+		present[host.ID] = true
+	}
+	m.mu.Lock()
+	for id := range m.latest {
+		if !present[id] {
+			delete(m.latest, id)
+		}
+	}
+	m.mu.Unlock()
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, metricsConcurrency)
+	for i := range hosts {
+		host := hosts[i]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			m.collect(&host)
+		}()
+	}
+	wg.Wait()
+}
+
+func (m *MetricsCollector) collect(host *models.Host) {
+	stats, err := m.sshService.GetHostStats(host)
+
+	status := "online"
+	if err != nil {
+		status = "offline"
+	}
+	if host.Status != status {
+		config.DB.Model(&models.Host{}).Where("id = ?", host.ID).Update("status", status)
+	}
+
+	if err != nil {
+		m.mu.Lock()
+		delete(m.latest, host.ID)
+		m.mu.Unlock()
+		return
+	}
+
+	m.mu.Lock()
+	m.latest[host.ID] = stats
+	m.mu.Unlock()
+
+	samples := make([]models.MetricSample, 0, len(metricExtractors))
+	for name, extract := range metricExtractors {
+		if _, failed := stats.Errors[metricErrorKeys[name]]; failed {
+			continue
+		}
+		samples = append(samples, models.MetricSample{
+			HostID:    host.ID,
+			Metric:    name,
+			Timestamp: stats.UpdatedAt,
+			Value:     extract(stats),
+		})
+	}
+	if len(samples) == 0 {
+		return
+	}
+	if err := config.DB.CreateInBatches(samples, 100).Error; err != nil {
+		log.Printf("Failed to store metrics for host %d: %v", host.ID, err)
+	}
+}
+
+type rollupKey struct {
+	hostID uint
+	metric string
+	bucket int64
+}
+
+// 将超过原始保留期的采样降采样为5分钟平均值，并删除超过总保留期的数据
+func (m *MetricsCollector) compact() {
+	cutoff := time.Now().Add(-m.rawRetention).Truncate(metricsRollupResolution * time.Second)
+
+	for {
+		var oldest models.MetricSample
+		result := config.DB.Where("resolution = ? AND timestamp < ?", 0, cutoff).
+			Order("timestamp ASC").Limit(1).Find(&oldest)
+		if result.Error != nil || result.RowsAffected == 0 {
+			break
+		}
+
+		// 窗口按降采样粒度对齐，保证同一个桶只在一批中处理
+		windowEnd := oldest.Timestamp.Truncate(metricsRollupResolution * time.Second).Add(metricsRollupWindow)
+		if windowEnd.After(cutoff) {
+			windowEnd = cutoff
+		}
+
+		var raw []models.MetricSample
+		if err := config.DB.Where("resolution = ? AND timestamp < ?", 0, windowEnd).Find(&raw).Error; err != nil {
+			log.Printf("Failed to load metrics for rollup: %v", err)
+			return
+		}
+
+		sums := make(map[rollupKey]float64)
+		counts := make(map[rollupKey]int)
+		for _, sample := range raw {
+			key := rollupKey{sample.HostID, sample.Metric, sample.Timestamp.Unix() / metricsRollupResolution}
+			sums[key] += sample.Value
+			counts[key]++
+		}
+
+		rollups := make([]models.MetricSample, 0, len(sums))
+		for key, sum := range sums {
+			rollups = append(rollups, models.MetricSample{
+				HostID:     key.hostID,
+				Metric:     key.metric,
+				Resolution: metricsRollupResolution,
+				Timestamp:  time.Unix(key.bucket*metricsRollupResolution, 0),
+				Value:      sum / float64(counts[key]),
+			})
+		}
+
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			if len(rollups) > 0 {
+				if err := tx.CreateInBatches(rollups, 500).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Where("resolution = ? AND timestamp < ?", 0, windowEnd).Delete(&models.MetricSample{}).Error
+		})
+		if err != nil {
+			log.Printf("Failed to roll up metrics: %v", err)
+			return
+		}
+	}
+
+	result := config.DB.Where("timestamp < ?", time.Now().Add(-m.retention)).Delete(&models.MetricSample{})
+	if result.Error != nil {
+		log.Printf("Failed to apply metrics retention: %v", result.Error)
+	}
+}
+
+// History 查询主机指标历史，按step秒聚合为平均值
+func (m *MetricsCollector) History(hostID uint, metrics []string, from, to time.Time, step int) (*models.MetricHistoryResponse, error) {
+	for _, metric := range metrics {
+		if _, ok := metricExtractors[metric]; !ok {
+			return nil, fmt.Errorf("不支持的指标 %q，可选: %s", metric, strings.Join(MetricNames(), ", "))
+		}
+	}
+	if !to.After(from) {
+		return nil, fmt.Errorf("结束时间必须晚于开始时间")
+	}
+
+	// 未指定步长时按约300个点自动计算，且不小于采集间隔
+	if step <= 0 {
+		step = int(to.Sub(from).Seconds() / 300)
+		if minStep := int(m.interval.Seconds()); step < minStep {
+			step = minStep
+		}
+		if step <= 0 {
+			step = 60
+		}
+	}
+	if to.Sub(from)/time.Duration(step)/time.Second > 10000 {
+		return nil, fmt.Errorf("步长过小，单个序列最多返回10000个点")
+	}
+
+	response := &models.MetricHistoryResponse{
+		HostID: hostID,
+		From:   from,
+		To:     to,
+		Step:   step,
+	}
+
+	for _, metric := range metrics {
+		var samples []models.MetricSample
+		err := config.DB.Where("host_id = ? AND metric = ? AND timestamp >= ? AND timestamp <= ?", hostID, metric, from, to).
+			Order("timestamp ASC").
+			Find(&samples).Error
+		if err != nil {
+			return nil, err
+		}
+
+		// 原始数据和降采样数据统一按步长分桶求平均
+		sums := make(map[int64]float64)
+		counts := make(map[int64]int)
+		var buckets []int64
+		for _, sample := range samples {
+			bucket := sample.Timestamp.Unix() / int64(step)
+			if _, ok := counts[bucket]; !ok {
+				buckets = append(buckets, bucket)
+			}
+			sums[bucket] += sample.Value
+			counts[bucket]++
+		}
+
+		series := models.MetricSeries{Metric: metric, Points: []models.MetricPoint{}}
+		for _, bucket := range buckets {
+			series.Points = append(series.Points, models.MetricPoint{
+				Timestamp: time.Unix(bucket*int64(step), 0),
+				Value:     sums[bucket] / float64(counts[bucket]),
+			})
+		}
+		response.Series = append(response.Series, series)
+	}
+
+	return response, nil
+}
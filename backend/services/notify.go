@@ -0,0 +1,284 @@
+package services
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"host-manager/models"
+)
+
+const notifyTimeout = 15 * time.Second
+
+// 通知渠道的地址由用户填写，连接时拒绝回环和链路本地地址（含云主机元数据服务），
+// 避免借通知渠道访问本机或元数据接口；在解析后的地址上检查，域名指向这些地址同样拒绝
+var notifyDialControl = func(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	return checkNotifyIP(net.ParseIP(host))
+}
+
+func checkNotifyIP(ip net.IP) error {
+	if ip == nil {
+		return nil
+	}
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("不允许连接回环或链路本地地址: %s", ip)
+	}
+	return nil
+}
+
+// 保存渠道时先拒绝明显指向本机或链路本地的地址，域名在连接时再检查
+func checkNotifyHost(host string) error {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return fmt.Errorf("不允许连接回环或链路本地地址: %s", host)
+	}
+	return checkNotifyIP(net.ParseIP(strings.Trim(host, "[]")))
+}
+
+func notifyDialer() *net.Dialer {
+	return &net.Dialer{Timeout: notifyTimeout, Control: notifyDialControl}
+}
+
+// AlertNotification 发送给通知渠道的告警内容，webhook 渠道直接以JSON形式发送
+type AlertNotification struct {
+	Status      string     `json:"status"` // firing、resolved、test
+	AlertID     uint       `json:"alert_id"`
+	RuleID      uint       `json:"rule_id"`
+	RuleName    string     `json:"rule_name"`
+	Description string     `json:"description"`
+	Severity    string     `json:"severity"`
+	HostID      uint       `json:"host_id"`
+	HostName    string     `json:"host_name"`
+	HostAddress string     `json:"host_address"`
+	Metric      string     `json:"metric"`
+	Operator    string     `json:"operator"`
+	Threshold   float64    `json:"threshold"`
+	Value       float64    `json:"value"`
+	StartsAt    time.Time  `json:"starts_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// Title 通知标题
+func (n *AlertNotification) Title() string {
+	switch n.Status {
+	case "resolved":
+		return fmt.Sprintf("[已恢复] %s - %s", n.RuleName, n.HostName)
+	case "test":
+		return "[测试] host-manager 告警通知"
+	default:
+		return fmt.Sprintf("[%s] %s - %s", strings.ToUpper(n.Severity), n.RuleName, n.HostName)
+	}
+}
+
+// Text 通知正文
+func (n *AlertNotification) Text() string {
+	if n.Status == "test" {
+		return "这是一条测试通知，收到说明通知渠道配置正确。"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "主机: %s (%s)\n", n.HostName, n.HostAddress)
+	fmt.Fprintf(&b, "条件: %s %s %s\n", n.Metric, n.Operator, strconv.FormatFloat(n.Threshold, 'f', -1, 64))
+	fmt.Fprintf(&b, "当前值: %s\n", strconv.FormatFloat(n.Value, 'f', 2, 64))
+	fmt.Fprintf(&b, "开始时间: %s\n", n.StartsAt.Format("2006-01-02 15:04:05"))
+	if n.ResolvedAt != nil {
+		fmt.Fprintf(&b, "恢复时间: %s\n", n.ResolvedAt.Format("2006-01-02 15:04:05"))
+	}
+	if n.Description != "" {
+		fmt.Fprintf(&b, "说明: %s\n", n.Description)
+	}
+	return b.String()
+}
+
+// SendNotification 通过指定渠道发送告警通知
+func SendNotification(channel *models.NotificationChannel, n *AlertNotification) error {
+	switch channel.Type {
+	case "webhook":
+		return postJSON(channel.URL, n)
+	case "chat":
+		return postJSON(channel.URL, chatPayload(channel.ChatFormat, n.Title()+"\n"+n.Text()))
+	case "email":
+		return sendEmail(channel, n.Title(), n.Text())
+	default:
+		return fmt.Errorf("不支持的通知渠道类型: %s", channel.Type)
+	}
+}
+
+// ValidateNotificationChannel 校验通知渠道配置
+func ValidateNotificationChannel(channel *models.NotificationChannel) error {
+	switch channel.Type {
+	case "webhook", "chat":
+		if !strings.HasPrefix(channel.URL, "http://") && !strings.HasPrefix(channel.URL, "https://") {
+			return fmt.Errorf("URL必须以 http:// 或 https:// 开头")
+		}
+		u, err := url.Parse(channel.URL)
+		if err != nil || u.Hostname() == "" {
+			return fmt.Errorf("无效的URL")
+		}
+		if err := checkNotifyHost(u.Hostname()); err != nil {
+			return err
+		}
+		switch channel.ChatFormat {
+		case "", "slack", "dingtalk", "feishu", "wecom":
+		default:
+			return fmt.Errorf("不支持的消息格式: %s", channel.ChatFormat)
+		}
+	case "email":
+		if channel.SMTPHost == "" || channel.EmailFrom == "" || channel.EmailTo == "" {
+			return fmt.Errorf("SMTP服务器、发件人和收件人不能为空")
+		}
+		if channel.SMTPPort <= 0 || channel.SMTPPort > 65535 {
+			return fmt.Errorf("无效的SMTP端口")
+		}
+		if err := checkNotifyHost(channel.SMTPHost); err != nil {
+			return err
+		}
+		switch channel.SMTPSecurity {
+		case "", "starttls", "tls", "none":
+		default:
+			return fmt.Errorf("不支持的SMTP加密方式: %s", channel.SMTPSecurity)
+		}
+	default:
+		return fmt.Errorf("不支持的通知渠道类型: %s", channel.Type)
+	}
+	return nil
+}
+
+// 各聊天工具机器人webhook的消息格式
+func chatPayload(format, text string) interface{} {
+	switch format {
+	case "dingtalk", "wecom":
+		return map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": text},
+		}
+	case "feishu":
+		return map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": text},
+		}
+	default:
+		// Slack、Mattermost、Rocket.Chat 等兼容格式
+		return map[string]string{"text": text}
+	}
+}
+
+func postJSON(url string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{
+		Timeout: notifyTimeout,
+		Transport: &http.Transport{
+			Proxy:       http.ProxyFromEnvironment,
+			DialContext: notifyDialer().DialContext,
+		},
+	}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("返回状态码 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func sendEmail(channel *models.NotificationChannel, subject, body string) error {
+	addr := net.JoinHostPort(channel.SMTPHost, strconv.Itoa(channel.SMTPPort))
+	tlsConfig := &tls.Config{ServerName: channel.SMTPHost}
+
+	var conn net.Conn
+	var err error
+	dialer := notifyDialer()
+	if channel.SMTPSecurity == "tls" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("连接SMTP服务器失败: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(notifyTimeout))
+
+	client, err := smtp.NewClient(conn, channel.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP握手失败: %v", err)
+	}
+	defer client.Close()
+
+	if channel.SMTPSecurity == "" || channel.SMTPSecurity == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS失败: %v", err)
+			}
+		} else if channel.SMTPSecurity == "starttls" {
+			return fmt.Errorf("SMTP服务器不支持STARTTLS")
+		}
+	}
+
+	if channel.SMTPUsername != "" {
+		// smtp.PlainAuth 只允许在TLS或本机连接上发送密码
+		auth := smtp.PlainAuth("", channel.SMTPUsername, channel.SMTPPassword, channel.SMTPHost)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP认证失败: %v", err)
+		}
+	}
+
+	var recipients []string
+	for _, to := range strings.Split(channel.EmailTo, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			recipients = append(recipients, to)
+		}
+	}
+
+	if err := client.Mail(channel.EmailFrom); err != nil {
+		return fmt.Errorf("设置发件人失败: %v", err)
+	}
+	for _, to := range recipients {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("设置收件人 %s 失败: %v", to, err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	msg := "From: " + channel.EmailFrom + "\r\n" +
+		"To: " + strings.Join(recipients, ", ") + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"Content-Transfer-Encoding: 8bit\r\n\r\n" +
+		strings.ReplaceAll(body, "\n", "\r\n")
+	if _, err := w.Write([]byte(msg)); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("发送邮件失败: %v", err)
+	}
+	return client.Quit()
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"host-manager/models"
+)
+
+// 记录收到的请求的测试HTTP服务
+type capturedRequest struct {
+	method      string
+	path        string
+	contentType string
+	body        []byte
+}
+
+// 测试服务监听在回环地址上，测试期间放开地址限制
+func allowLoopbackNotify(t *testing.T) {
+	t.Helper()
+	control := notifyDialControl
+	notifyDialControl = nil
+	t.Cleanup(func() { notifyDialControl = control })
+}
+
+func newCaptureServer(t *testing.T, status int) (*httptest.Server, <-chan capturedRequest) {
+	t.Helper()
+	allowLoopbackNotify(t)
+	requests := make(chan capturedRequest, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- capturedRequest{
+			method:      r.Method,
+			path:        r.URL.Path,
+			contentType: r.Header.Get("Content-Type"),
+			body:        body,
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, "bad token\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func testNotification() *AlertNotification {
+	return &AlertNotification{
+		Status:      "firing",
+		AlertID:     7,
+		RuleID:      3,
+		RuleName:    "CPU过高",
+		Severity:    "critical",
+		HostID:      1,
+		HostName:    "web-1",
+		HostAddress: "10.0.0.1",
+		Metric:      "cpu_usage",
+		Operator:    ">",
+		Threshold:   90,
+		Value:       97.5,
+		StartsAt:    time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSendNotificationWebhook(t *testing.T) {
+	srv, requests := newCaptureServer(t, http.StatusOK)
+	channel := &models.NotificationChannel{Type: "webhook", URL: srv.URL + "/hook"}
+
+	if err := SendNotification(channel, testNotification()); err != nil {
+		t.Fatalf("SendNotification: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/hook" {
+		t.Errorf("request = %s %s, want POST /hook", req.method, req.path)
+	}
+	if req.contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", req.contentType)
+	}
+	var got AlertNotification
+	if err := json.Unmarshal(req.body, &got); err != nil {
+		t.Fatalf("invalid JSON body %q: %v", req.body, err)
+	}
+	want := testNotification()
+	if got.Status != want.Status || got.AlertID != want.AlertID || got.RuleName != want.RuleName ||
+		got.HostName != want.HostName || got.Value != want.Value || !got.StartsAt.Equal(want.StartsAt) {
+		t.Errorf("payload = %+v, want %+v", got, *want)
+	}
+	if got.ResolvedAt != nil {
+		t.Errorf("resolved_at = %v, want omitted", got.ResolvedAt)
+	}
+}
+
+func TestSendNotificationWebhookErrorStatus(t *testing.T) {
+	srv, requests := newCaptureServer(t, http.StatusUnauthorized)
+	channel := &models.NotificationChannel{Type: "webhook", URL: srv.URL}
+
+	err := SendNotification(channel, testNotification())
+	<-requests
+	if err == nil || !strings.Contains(err.Error(), "401") || !strings.Contains(err.Error(), "bad token") {
+		t.Fatalf("err = %v, want status code and response body", err)
+	}
+}
+
+func TestSendNotificationChat(t *testing.T) {
+	n := testNotification()
+	text := n.Title() + "\n" + n.Text()
+
+	cases := []struct {
+		format string
+		check  func(t *testing.T, body map[string]interface{})
+	}{
+		{"", func(t *testing.T, body map[string]interface{}) {
+			if body["text"] != text {
+				t.Errorf("text = %v, want %q", body["text"], text)
+			}
+		}},
+		{"dingtalk", func(t *testing.T, body map[string]interface{}) {
+			content, _ := body["text"].(map[string]interface{})
+			if body["msgtype"] != "text" || content["content"] != text {
+				t.Errorf("body = %v", body)
+			}
+		}},
+		{"wecom", func(t *testing.T, body map[string]interface{}) {
+			content, _ := body["text"].(map[string]interface{})
+			if body["msgtype"] != "text" || content["content"] != text {
+				t.Errorf("body = %v", body)
+			}
+		}},
+		{"feishu", func(t *testing.T, body map[string]interface{}) {
+			content, _ := body["content"].(map[string]interface{})
+			if body["msg_type"] != "text" || content["text"] != text {
+				t.Errorf("body = %v", body)
+			}
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run("format="+tc.format, func(t *testing.T) {
+			srv, requests := newCaptureServer(t, http.StatusOK)
+			channel := &models.NotificationChannel{Type: "chat", URL: srv.URL, ChatFormat: tc.format}
+
+			if err := SendNotification(channel, n); err != nil {
+				t.Fatalf("SendNotification: %v", err)
+			}
+			req := <-requests
+			if req.contentType != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", req.contentType)
+			}
+			var body map[string]interface{}
+			if err := json.Unmarshal(req.body, &body); err != nil {
+				t.Fatalf("invalid JSON body %q: %v", req.body, err)
+			}
+			tc.check(t, body)
+		})
+	}
+}
+
+// 只实现发送邮件所需命令的测试SMTP服务
+type smtpSession struct {
+	mu       sync.Mutex
+	commands []string
+	auth     string // AUTH PLAIN 解码后的内容
+	data     string
+}
+
+func startSMTPServer(t *testing.T, extensions ...string) (int, *smtpSession) {
+	t.Helper()
+	allowLoopbackNotify(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	session := &smtpSession{}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		reply("220 localhost ESMTP test")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			verb := strings.ToUpper(strings.Fields(line + " ")[0])
+
+			session.mu.Lock()
+			session.commands = append(session.commands, verb)
+			session.mu.Unlock()
+
+			switch verb {
+			case "EHLO", "HELO":
+				for _, ext := range extensions {
+					reply("250-" + ext)
+				}
+				reply("250 OK")
+			case "AUTH":
+				fields := strings.Fields(line)
+				if len(fields) != 3 || fields[1] != "PLAIN" {
+					reply("504 unsupported")
+					continue
+				}
+				decoded, _ := base64.StdEncoding.DecodeString(fields[2])
+				session.mu.Lock()
+				session.auth = string(decoded)
+				session.mu.Unlock()
+				reply("235 accepted")
+			case "MAIL", "RCPT":
+				reply("250 OK")
+			case "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				session.mu.Lock()
+				session.data = data.String()
+				session.mu.Unlock()
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	return ln.Addr().(*net.TCPAddr).Port, session
+}
+
+func TestSendNotificationEmail(t *testing.T) {
+	port, session := startSMTPServer(t, "AUTH PLAIN")
+	channel := &models.NotificationChannel{
+		Type:         "email",
+		SMTPHost:     "127.0.0.1",
+		SMTPPort:     port,
+		SMTPUsername: "alert@example.com",
+		SMTPPassword: "s3cret",
+		SMTPSecurity: "none",
+		EmailFrom:    "alert@example.com",
+		EmailTo:      "ops@example.com, dev@example.com",
+	}
+
+	n := testNotification()
+	if err := SendNotification(channel, n); err != nil {
+		t.Fatalf("SendNotification: %v", err)
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+
+	if want := "\x00alert@example.com\x00s3cret"; session.auth != want {
+		t.Errorf("AUTH PLAIN = %q, want %q", session.auth, want)
+	}
+	wantCommands := []string{"EHLO", "AUTH", "MAIL", "RCPT", "RCPT", "DATA", "QUIT"}
+	if strings.Join(session.commands, " ") != strings.Join(wantCommands, " ") {
+		t.Errorf("commands = %v, want %v", session.commands, wantCommands)
+	}
+
+	header, body, ok := strings.Cut(session.data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("message has no header/body separator: %q", session.data)
+	}
+	for _, want := range []string{
+		"From: alert@example.com",
+		"To: ops@example.com, dev@example.com",
+		"Subject: =?utf-8?q?",
+		"Content-Type: text/plain; charset=utf-8",
+	} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header missing %q:\n%s", want, header)
+		}
+	}
+	if want := strings.ReplaceAll(n.Text(), "\n", "\r\n"); body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSendNotificationEmailWithoutAuth(t *testing.T) {
+	port, session := startSMTPServer(t)
+	channel := &models.NotificationChannel{
+		Type:      "email",
+		SMTPHost:  "127.0.0.1",
+		SMTPPort:  port,
+		EmailFrom: "alert@example.com",
+		EmailTo:   "ops@example.com",
+	}
+
+	// 服务器不支持STARTTLS且未强制时以明文发送，不发送AUTH
+	if err := SendNotification(channel, testNotification()); err != nil {
+		t.Fatalf("SendNotification: %v", err)
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	for _, cmd := range session.commands {
+		if cmd == "AUTH" || cmd == "STARTTLS" {
+			t.Errorf("unexpected %s in %v", cmd, session.commands)
+		}
+	}
+}
+
+func TestSendNotificationEmailRequiresStartTLS(t *testing.T) {
+	port, session := startSMTPServer(t, "AUTH PLAIN")
+	channel := &models.NotificationChannel{
+		Type:         "email",
+		SMTPHost:     "127.0.0.1",
+		SMTPPort:     port,
+		SMTPUsername: "alert@example.com",
+		SMTPPassword: "s3cret",
+		SMTPSecurity: "starttls",
+		EmailFrom:    "alert@example.com",
+		EmailTo:      "ops@example.com",
+	}
+
+	err := SendNotification(channel, testNotification())
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("err = %v, want STARTTLS error", err)
+	}
+
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.auth != "" {
+		t.Errorf("credentials sent without TLS: %q", session.auth)
+	}
+}
+
+func TestSendNotificationEmailBadAddress(t *testing.T) {
+	allowLoopbackNotify(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	channel := &models.NotificationChannel{
+		Type:      "email",
+		SMTPHost:  "127.0.0.1",
+		SMTPPort:  port,
+		EmailFrom: "alert@example.com",
+		EmailTo:   "ops@example.com",
+	}
+	err = SendNotification(channel, testNotification())
+	if err == nil || !strings.Contains(err.Error(), "连接SMTP服务器失败") {
+		t.Fatalf("err = %v, want connection error for port %d", err, port)
+	}
+}
+
+func TestNotificationRejectsInternalTargets(t *testing.T) {
+	for _, channel := range []models.NotificationChannel{
+		{Type: "webhook", URL: "http://127.0.0.1:8080/hook"},
+		{Type: "webhook", URL: "http://localhost/hook"},
+		{Type: "chat", URL: "http://169.254.169.254/latest/meta-data/"},
+		{Type: "webhook", URL: "http://[::1]/hook"},
+		{Type: "email", SMTPHost: "127.0.0.1", SMTPPort: 25, EmailFrom: "a@example.com", EmailTo: "b@example.com"},
+	} {
+		if err := ValidateNotificationChannel(&channel); err == nil {
+			t.Errorf("ValidateNotificationChannel(%+v) = nil, want error", channel)
+		}
+	}
+
+	// 已保存的渠道或解析到回环地址的域名在连接时拒绝
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s %s", r.Method, r.URL)
+	}))
+	defer srv.Close()
+	channel := &models.NotificationChannel{Type: "webhook", URL: srv.URL}
+	err := SendNotification(channel, testNotification())
+	if err == nil || !strings.Contains(err.Error(), "回环") {
+		t.Fatalf("err = %v, want loopback rejection", err)
+	}
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"host-manager/models"
+)
+
+// 回放倍速范围
+const (
+	MinPlaybackSpeed = 0.5
+	MaxPlaybackSpeed = 16.0
+)
+
+// 快进时单条输出消息的最大长度
+const playbackChunkBytes = 256 * 1024
+
+// asciicast 中的单个事件
+type castEvent struct {
+	Time float64
+	Code string
+	Data string
+}
+
+// Playback 会话回放，顺序读取 asciicast 录像并按原始时间推送
+type Playback struct {
+	open     func() (io.ReadCloser, error)
+	header   castHeader
+	duration float64
+
+	rc      io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// OpenPlayback 打开会话录像，没有录像文件的旧会话由操作记录生成
+func (a *AuditService) OpenPlayback(session *models.TerminalSession) (*Playback, error) {
+	p := &Playback{}
+	if path := a.SessionCastPath(session); path != "" {
+		p.open = func() (io.ReadCloser, error) { return os.Open(path) }
+	} else {
+		data, err := a.BuildSessionCast(session)
+		if err != nil {
+			return nil, err
+		}
+		p.open = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	}
+
+	// 先完整读一遍得到文件头和总时长
+	if err := p.rewind(); err != nil {
+		return nil, err
+	}
+	for {
+		ev, err := p.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.duration = ev.Time
+	}
+	return p, nil
+}
+
+// Close 关闭录像文件
+func (p *Playback) Close() {
+	if p.rc != nil {
+		p.rc.Close()
+		p.rc = nil
+	}
+}
+
+// 回到录像开头并读取文件头
+func (p *Playback) rewind() error {
+	p.Close()
+	rc, err := p.open()
+	if err != nil {
+		return err
+	}
+	p.rc = rc
+	p.scanner = bufio.NewScanner(rc)
+	p.scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !p.scanner.Scan() {
+		if err := p.scanner.Err(); err != nil {
+			return err
+		}
+		return errors.New("录像为空")
+	}
+	var header castHeader
+	if err := json.Unmarshal(p.scanner.Bytes(), &header); err != nil || header.Version != 2 {
+		return errors.New("不是有效的 asciicast v2 录像")
+	}
+	p.header = header
+	return nil
+}
+
+// 读取下一个事件，跳过无法解析的行
+func (p *Playback) next() (*castEvent, error) {
+	for p.scanner.Scan() {
+		var fields []json.RawMessage
+		if err := json.Unmarshal(p.scanner.Bytes(), &fields); err != nil || len(fields) != 3 {
+			continue
+		}
+		var ev castEvent
+		if json.Unmarshal(fields[0], &ev.Time) != nil || json.Unmarshal(fields[1], &ev.Code) != nil ||
+			json.Unmarshal(fields[2], &ev.Data) != nil {
+			continue
+		}
+		return &ev, nil
+	}
+	if err := p.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// PlaybackOptions 回放的初始设置
+type PlaybackOptions struct {
+	Speed  float64 // 倍速
+	Idle   float64 // 超过该秒数的空闲间隔缩短为该值，0 表示不跳过
+	Start  float64 // 开始位置（秒）
+	Paused bool
+}
+
+// 回放状态，clock 为当前在录像中的时间
+type player struct {
+	p      *Playback
+	send   func(models.PlaybackMessage) error
+	speed  float64
+	idle   float64
+	paused bool
+	clock  float64
+	ev     *castEvent // 下一个待播放的事件，nil 表示已播放完
+}
+
+// Run 按原始时间推送录像，直到 commands 关闭或推送失败；播放完后仍可接收 seek、play 命令重新播放
+func (p *Playback) Run(opts PlaybackOptions, commands <-chan models.PlaybackCommand, send func(models.PlaybackMessage) error) error {
+	pl := &player{p: p, send: send, speed: opts.Speed, idle: opts.Idle, paused: opts.Paused}
+	if err := send(models.PlaybackMessage{
+		Type:     "header",
+		Cols:     p.header.Width,
+		Rows:     p.header.Height,
+		Duration: p.duration,
+		Speed:    pl.speed,
+		Idle:     pl.idle,
+		Paused:   pl.paused,
+		Title:    p.header.Title,
+	}); err != nil {
+		return err
+	}
+	if err := pl.seek(opts.Start); err != nil {
+		return err
+	}
+
+	for {
+		if pl.ev == nil || pl.paused {
+			cmd, ok := <-commands
+			if !ok {
+				return nil
+			}
+			if err := pl.handle(cmd); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// 过长的空闲间隔直接跳到下一个事件前 idle 秒
+		if pl.idle > 0 && pl.ev.Time-pl.clock > pl.idle {
+			pl.clock = pl.ev.Time - pl.idle
+		}
+		wait := time.Duration((pl.ev.Time - pl.clock) / pl.speed * float64(time.Second))
+		started := time.Now()
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+			pl.clock = pl.ev.Time
+			if err := pl.emit(pl.ev); err != nil {
+				return err
+			}
+			if err := pl.advance(); err != nil {
+				return err
+			}
+		case cmd, ok := <-commands:
+			timer.Stop()
+			if !ok {
+				return nil
+			}
+			pl.clock += time.Since(started).Seconds() * pl.speed
+			if pl.clock > pl.ev.Time {
+				pl.clock = pl.ev.Time
+			}
+			if err := pl.handle(cmd); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// 读取下一个事件，读完时推送 end
+func (pl *player) advance() error {
+	ev, err := pl.p.next()
+	if err == io.EOF {
+		pl.ev = nil
+		pl.clock = pl.p.duration
+		return pl.send(models.PlaybackMessage{Type: "end", Time: pl.clock, Duration: pl.p.duration})
+	}
+	if err != nil {
+		return err
+	}
+	pl.ev = ev
+	return nil
+}
+
+func (pl *player) emit(ev *castEvent) error {
+	switch ev.Code {
+	case "o":
+		return pl.send(models.PlaybackMessage{Type: "output", Time: ev.Time, Data: ev.Data})
+	case "r":
+		var cols, rows int
+		if _, err := fmt.Sscanf(ev.Data, "%dx%d", &cols, &rows); err != nil {
+			return nil
+		}
+		return pl.send(models.PlaybackMessage{Type: "resize", Time: ev.Time, Cols: cols, Rows: rows})
+	}
+	// 输入和标记事件不需要显示
+	return nil
+}
+
+func (pl *player) status() error {
+	return pl.send(models.PlaybackMessage{
+		Type:     "status",
+		Time:     pl.clock,
+		Duration: pl.p.duration,
+		Speed:    pl.speed,
+		Idle:     pl.idle,
+		Paused:   pl.paused,
+	})
+}
+
+func (pl *player) handle(cmd models.PlaybackCommand) error {
+	switch cmd.Type {
+	case "pause":
+		pl.paused = true
+	case "play":
+		pl.paused = false
+		// 播放完后重新开始
+		if pl.ev == nil {
+			if err := pl.seek(0); err != nil {
+				return err
+			}
+		}
+	case "speed":
+		if cmd.Value < MinPlaybackSpeed || cmd.Value > MaxPlaybackSpeed {
+			return pl.send(models.PlaybackMessage{Type: "error", Time: pl.clock,
+				Data: fmt.Sprintf("倍速必须在%g到%g之间", MinPlaybackSpeed, MaxPlaybackSpeed)})
+		}
+		pl.speed = cmd.Value
+	case "idle":
+		if cmd.Value < 0 {
+			return pl.send(models.PlaybackMessage{Type: "error", Time: pl.clock, Data: "空闲间隔不能为负数"})
+		}
+		pl.idle = cmd.Value
+	case "seek":
+		if err := pl.seek(cmd.Value); err != nil {
+			return err
+		}
+	default:
+		return pl.send(models.PlaybackMessage{Type: "error", Time: pl.clock, Data: "不支持的命令: " + cmd.Type})
+	}
+	return pl.status()
+}
+
+// 跳转到指定时间：向后跳转时从头重放，到目标位置前的输出合并后立即推送，
+// 浏览器按顺序写入即可得到该时刻的终端画面
+func (pl *player) seek(target float64) error {
+	if target < 0 {
+		target = 0
+	}
+	if target > pl.p.duration {
+		target = pl.p.duration
+	}
+
+	if target < pl.clock || pl.ev == nil {
+		if err := pl.p.rewind(); err != nil {
+			return err
+		}
+		if err := pl.send(models.PlaybackMessage{Type: "reset", Cols: pl.p.header.Width, Rows: pl.p.header.Height}); err != nil {
+			return err
+		}
+		ev, err := pl.p.next()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		pl.ev = ev
+	}
+
+	var output strings.Builder
+	flush := func() error {
+		if output.Len() == 0 {
+			return nil
+		}
+		err := pl.send(models.PlaybackMessage{Type: "output", Time: target, Data: output.String()})
+		output.Reset()
+		return err
+	}
+	for pl.ev != nil && pl.ev.Time <= target {
+		switch pl.ev.Code {
+		case "o":
+			output.WriteString(pl.ev.Data)
+			if output.Len() >= playbackChunkBytes {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		case "r":
+			if err := flush(); err != nil {
+				return err
+			}
+			if err := pl.emit(pl.ev); err != nil {
+				return err
+			}
+		}
+		ev, err := pl.p.next()
+		if err != nil && err != io.EOF {
+			return err
+		}
+		pl.ev = ev
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	pl.clock = target
+	if pl.ev == nil {
+		return pl.send(models.PlaybackMessage{Type: "end", Time: pl.clock, Duration: pl.p.duration})
+	}
+	return nil
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/models"
+)
+
+// 进程列表采集的超时时间（含两次采样之间的1秒间隔）
+const processTimeout = 20 * time.Second
+
+// 与 statsScript 相同，只依赖 /proc 和 BusyBox 也具备的工具；CPU 使用率在间隔1秒的两次采样之间计算。
+// 进程属主取 /proc/<pid> 目录的属主，命令行优先取 ps 的 args。
+const processScript = `export LC_ALL=C
+echo '==CLK=='; getconf CLK_TCK 2>/dev/null
+echo '==PAGESIZE=='; getconf PAGESIZE 2>/dev/null
+echo '==BTIME=='; grep '^btime' /proc/stat
+echo '==MEMINFO=='; cat /proc/meminfo
+echo '==UPTIME1=='; cat /proc/uptime
+echo '==PSTAT1=='; cat /proc/[0-9]*/stat 2>/dev/null
+sleep 1
+echo '==UPTIME2=='; cat /proc/uptime
+echo '==PSTAT2=='; cat /proc/[0-9]*/stat 2>/dev/null
+echo '==OWNER=='; stat -c '%u %n' /proc/[0-9]* 2>/dev/null
+echo '==PASSWD=='; cat /etc/passwd 2>/dev/null
+echo '==ARGS=='; ps -eo pid=,args= 2>/dev/null || ps -o pid,args
+echo '==END=='
+`
+
+// 允许发送的信号
+var allowedSignals = map[string]bool{"TERM": true, "KILL": true, "HUP": true}
+
+// NormalizeSignal 将 SIGTERM、term 等写法统一为 TERM，不支持的信号返回错误
+func NormalizeSignal(signal string) (string, error) {
+	signal = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(signal)), "SIG")
+	if !allowedSignals[signal] {
+		return "", fmt.Errorf("只支持 TERM、KILL、HUP 信号")
+	}
+	return signal, nil
+}
+
+// /proc/<pid>/stat 中需要的字段
+type procStat struct {
+	pid       int
+	ppid      int
+	comm      string
+	state     string
+	ticks     uint64 // utime + stime
+	threads   int
+	startTime uint64 // 开机后的时钟滴答数
+	rssPages  uint64
+}
+
+// 解析 /proc/<pid>/stat，进程名可能包含空格和括号，以最后一个 ")" 为界
+func parseProcStat(line string) (procStat, bool) {
+	open := strings.Index(line, "(")
+	end := strings.LastIndex(line, ")")
+	if open < 0 || end < open {
+		return procStat{}, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+	if err != nil {
+		return procStat{}, false
+	}
+	fields := strings.Fields(line[end+1:])
+	if len(fields) < 22 {
+		return procStat{}, false
+	}
+
+	ps := procStat{pid: pid, comm: line[open+1 : end], state: fields[0]}
+	ps.ppid, _ = strconv.Atoi(fields[1])
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	ps.ticks = utime + stime
+	ps.threads, _ = strconv.Atoi(fields[17])
+	ps.startTime, _ = strconv.ParseUint(fields[19], 10, 64)
+	ps.rssPages, _ = strconv.ParseUint(fields[21], 10, 64)
+	return ps, true
+}
+
+func parseFirstUint(lines []string, def uint64) uint64 {
+	if len(lines) > 0 {
+		if v, err := strconv.ParseUint(strings.TrimSpace(lines[0]), 10, 64); err == nil && v > 0 {
+			return v
+		}
+	}
+	return def
+}
+
+// ListProcesses 获取主机的进程列表
+func (s *SSHService) ListProcesses(host *models.Host) ([]models.ProcessInfo, error) {
+	output, err := s.RunCommand(host, "sh -s", strings.NewReader(processScript), processTimeout)
+	sections := splitStatsSections(output)
+	if len(sections["PSTAT2"]) == 0 {
+		if err == nil {
+			err = errors.New("无法读取 /proc/<pid>/stat")
+		}
+		return nil, err
+	}
+	return parseProcesses(sections), nil
+}
+
+// 由脚本输出的各段计算每个进程的信息
+func parseProcesses(sections map[string][]string) []models.ProcessInfo {
+	clockTicks := float64(parseFirstUint(sections["CLK"], 100))
+	pageSize := parseFirstUint(sections["PAGESIZE"], 4096)
+	memTotal := parseMeminfoValues(sections["MEMINFO"])["MemTotal"]
+	interval := sampleInterval(sections["UPTIME1"], sections["UPTIME2"])
+
+	var bootTime int64
+	if len(sections["BTIME"]) > 0 {
+		if fields := strings.Fields(sections["BTIME"][0]); len(fields) == 2 {
+			bootTime, _ = strconv.ParseInt(fields[1], 10, 64)
+		}
+	}
+
+	firstTicks := make(map[int]uint64)
+	for _, line := range sections["PSTAT1"] {
+		if ps, ok := parseProcStat(line); ok {
+			firstTicks[ps.pid] = ps.ticks
+		}
+	}
+
+	owners := make(map[int]int)
+	for _, line := range sections["OWNER"] {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		uid, err1 := strconv.Atoi(fields[0])
+		pid, err2 := strconv.Atoi(strings.TrimPrefix(fields[1], "/proc/"))
+		if err1 == nil && err2 == nil {
+			owners[pid] = uid
+		}
+	}
+
+	userNames := make(map[int]string)
+	for _, line := range sections["PASSWD"] {
+		fields := strings.Split(line, ":")
+		if len(fields) < 3 {
+			continue
+		}
+		if uid, err := strconv.Atoi(fields[2]); err == nil {
+			if _, exists := userNames[uid]; !exists {
+				userNames[uid] = fields[0]
+			}
+		}
+	}
+
+	commands := make(map[int]string)
+	for _, line := range sections["ARGS"] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// BusyBox 的 ps 会输出表头，首列不是数字的行直接跳过
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		commands[pid] = strings.Join(fields[1:], " ")
+	}
+
+	var processes []models.ProcessInfo
+	for _, line := range sections["PSTAT2"] {
+		ps, ok := parseProcStat(line)
+		if !ok {
+			continue
+		}
+
+		// 第一次采样后才启动的进程，其CPU时间全部落在采样间隔内
+		delta := ps.ticks
+		if prev, ok := firstTicks[ps.pid]; ok && prev <= ps.ticks {
+			delta = ps.ticks - prev
+		}
+
+		p := models.ProcessInfo{
+			PID:     ps.pid,
+			PPID:    ps.ppid,
+			UID:     -1,
+			State:   ps.state,
+			CPU:     float64(delta) / clockTicks / interval * 100,
+			RSS:     ps.rssPages * pageSize,
+			Threads: ps.threads,
+			Command: commands[ps.pid],
+		}
+		if p.Command == "" {
+			p.Command = "[" + ps.comm + "]"
+		}
+		if uid, ok := owners[ps.pid]; ok {
+			p.UID = uid
+			p.User = userNames[uid]
+			if p.User == "" {
+				p.User = strconv.Itoa(uid)
+			}
+		}
+		if memTotal > 0 {
+			p.Memory = float64(p.RSS) / float64(memTotal) * 100
+		}
+		if bootTime > 0 {
+			p.StartTime = time.Unix(bootTime, 0).Add(time.Duration(float64(ps.startTime) / clockTicks * float64(time.Second)))
+		}
+		processes = append(processes, p)
+	}
+	return processes
+}
+
+// SortProcesses 按指定字段排序，字段可为 pid、user、cpu、memory、rss、start_time、command
+func SortProcesses(processes []models.ProcessInfo, field string, desc bool) error {
+	var less func(a, b *models.ProcessInfo) bool
+	switch field {
+	case "pid":
+		less = func(a, b *models.ProcessInfo) bool { return a.PID < b.PID }
+	case "user":
+		less = func(a, b *models.ProcessInfo) bool { return a.User < b.User }
+	case "cpu":
+		less = func(a, b *models.ProcessInfo) bool { return a.CPU < b.CPU }
+	case "memory", "mem":
+		less = func(a, b *models.ProcessInfo) bool { return a.RSS < b.RSS }
+	case "rss":
+		less = func(a, b *models.ProcessInfo) bool { return a.RSS < b.RSS }
+	case "start_time":
+		less = func(a, b *models.ProcessInfo) bool { return a.StartTime.Before(b.StartTime) }
+	case "command":
+		less = func(a, b *models.ProcessInfo) bool { return a.Command < b.Command }
+	default:
+		return fmt.Errorf("不支持的排序字段: %s", field)
+	}
+
+	sort.SliceStable(processes, func(i, j int) bool {
+		if desc {
+			return less(&processes[j], &processes[i])
+		}
+		return less(&processes[i], &processes[j])
+	})
+	return nil
+}
+
+var (
+	// ErrProcessNotFound 进程不存在
+	ErrProcessNotFound = errors.New("进程不存在")
+	// ErrNotProcessOwner 进程不属于SSH登录用户
+	ErrNotProcessOwner = errors.New("只能操作SSH登录用户自己的进程")
+)
+
+// SignalProcess 向主机上的进程发送信号，返回进程的命令行；ownOnly 为 true 时只允许操作SSH登录用户自己的进程
+func (s *SSHService) SignalProcess(host *models.Host, pid int, signal string, ownOnly bool) (string, error) {
+	if pid <= 1 {
+		return "", fmt.Errorf("不允许向PID %d发送信号", pid)
+	}
+	signal, err := NormalizeSignal(signal)
+	if err != nil {
+		return "", err
+	}
+
+	// 第一行输出命令行用于审计，属主校验与发送信号在同一次会话中完成
+	script := fmt.Sprintf(`export LC_ALL=C
+[ -d /proc/%[1]d ] || { echo '@@NOT_FOUND'; exit 2; }
+printf '%%s\n' "$(tr '\0' ' ' < /proc/%[1]d/cmdline 2>/dev/null)"
+`, pid)
+	if ownOnly {
+		script += fmt.Sprintf(`[ "$(stat -c %%u /proc/%[1]d)" = "$(id -u)" ] || { echo '@@NOT_OWNER'; exit 3; }
+`, pid)
+	}
+	script += fmt.Sprintf("kill -s %s %d\n", signal, pid)
+
+	output, err := s.RunCommand(host, "sh -s", strings.NewReader(script), 30*time.Second)
+	if strings.HasPrefix(output, "@@NOT_FOUND") {
+		return "", ErrProcessNotFound
+	}
+	command, rest, _ := strings.Cut(output, "\n")
+	command = strings.TrimSpace(command)
+	if strings.HasPrefix(rest, "@@NOT_OWNER") {
+		return command, ErrNotProcessOwner
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(rest); msg != "" {
+			return command, errors.New(msg)
+		}
+		return command, err
+	}
+	return command, nil
+}
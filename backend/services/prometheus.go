@@ -0,0 +1,296 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+)
+
+// 耗时直方图的桶上界（秒）
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// 按标签分组的直方图
+type histogram struct {
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labels string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{series: make(map[string]*histogramSeries)}
+}
+
+func (h *histogram) observe(labels string, v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[labels]
+	if !ok {
+		s = &histogramSeries{labels: labels, counts: make([]uint64, len(durationBuckets))}
+		h.series[labels] = s
+	}
+	for i, le := range durationBuckets {
+		if v <= le {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (h *histogram) write(w io.Writer, name, help string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := h.series[key]
+		prefix := s.labels
+		if prefix != "" {
+			prefix += ","
+		}
+		for i, le := range durationBuckets {
+			fmt.Fprintf(w, "%s_bucket{%sle=\"%s\"} %d\n", name, prefix, formatFloat(le), s.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, s.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, braces(s.labels), formatFloat(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, braces(s.labels), s.count)
+	}
+}
+
+// 按标签分组的计数器
+type counter struct {
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func newCounter() *counter {
+	return &counter{values: make(map[string]uint64)}
+}
+
+func (c *counter) inc(labels string) {
+	c.mu.Lock()
+	c.values[labels]++
+	c.mu.Unlock()
+}
+
+func (c *counter) write(w io.Writer, name, help string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %d\n", name, braces(key), c.values[key])
+	}
+}
+
+// host-manager 自身的运行指标
+var (
+	activeTerminalSessions int64
+	sshDialDuration        = newHistogram()
+	sshDialErrors          = newCounter()
+	apiRequestDuration     = newHistogram()
+	auditDroppedOperations = newCounter()
+)
+
+// TerminalSessionStarted 终端会话建立时调用，返回的函数在会话结束时调用
+func TerminalSessionStarted() func() {
+	atomic.AddInt64(&activeTerminalSessions, 1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { atomic.AddInt64(&activeTerminalSessions, -1) })
+	}
+}
+
+// 记录一次SSH拨号（含跳板机链路和认证）的耗时和结果
+func observeSSHDial(host *models.Host, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+		sshDialErrors.inc(labelPairs("host_id", strconv.FormatUint(uint64(host.ID), 10), "host_name", host.Name))
+	}
+	sshDialDuration.observe(labelPairs("result", result), d.Seconds())
+}
+
+// ObserveAPIRequest 记录一次API请求的耗时，route为路由模板而非实际路径
+func ObserveAPIRequest(method, route string, status int, d time.Duration) {
+	apiRequestDuration.observe(labelPairs("method", method, "route", route, "status", strconv.Itoa(status)), d.Seconds())
+}
+
+// WritePrometheusMetrics 以Prometheus文本格式输出所有主机指标和自身运行指标
+func WritePrometheusMetrics(w io.Writer) error {
+	var hosts []models.Host
+	if err := config.DB.Preload("Tags").Order("id").Find(&hosts).Error; err != nil {
+		return err
+	}
+
+	collector := GetMetricsCollector()
+	type hostEntry struct {
+		labels string
+		stats  *models.HostStats
+	}
+	entries := make([]hostEntry, 0, len(hosts))
+	for _, host := range hosts {
+		entries = append(entries, hostEntry{
+			labels: labelPairs("host_id", strconv.FormatUint(uint64(host.ID), 10), "host_name", host.Name, "tags", hostTagsLabel(host.Tags)),
+			stats:  collector.Latest(host.ID),
+		})
+	}
+
+	gauge := func(name, help string, value func(*models.HostStats) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, e := range entries {
+			if e.stats != nil {
+				fmt.Fprintf(w, "%s{%s} %s\n", name, e.labels, formatFloat(value(e.stats)))
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "# HELP hostmanager_host_up Whether the last stats collection succeeded (1) or not (0).\n# TYPE hostmanager_host_up gauge\n")
+	for _, e := range entries {
+		up := 0
+		if e.stats != nil {
+			up = 1
+		}
+		fmt.Fprintf(w, "hostmanager_host_up{%s} %d\n", e.labels, up)
+	}
+
+	gauge("hostmanager_host_cpu_usage_percent", "Overall CPU usage.", func(s *models.HostStats) float64 { return s.CPUUsage })
+	gauge("hostmanager_host_cpu_count", "Number of logical CPUs.", func(s *models.HostStats) float64 { return float64(s.CPUCount) })
+
+	fmt.Fprintf(w, "# HELP hostmanager_host_cpu_core_usage_percent Per logical CPU usage.\n# TYPE hostmanager_host_cpu_core_usage_percent gauge\n")
+	for _, e := range entries {
+		if e.stats == nil {
+			continue
+		}
+		for i, usage := range e.stats.CPUCores {
+			fmt.Fprintf(w, "hostmanager_host_cpu_core_usage_percent{%s,%s} %s\n", e.labels, labelPairs("cpu", strconv.Itoa(i)), formatFloat(usage))
+		}
+	}
+
+	gauge("hostmanager_host_load1", "1-minute load average.", func(s *models.HostStats) float64 { return s.Load1 })
+	gauge("hostmanager_host_load5", "5-minute load average.", func(s *models.HostStats) float64 { return s.Load5 })
+	gauge("hostmanager_host_load15", "15-minute load average.", func(s *models.HostStats) float64 { return s.Load15 })
+	gauge("hostmanager_host_memory_total_bytes", "Total memory.", func(s *models.HostStats) float64 { return float64(s.MemoryTotal) })
+	gauge("hostmanager_host_memory_used_bytes", "Used memory.", func(s *models.HostStats) float64 { return float64(s.MemoryUsed) })
+	gauge("hostmanager_host_memory_usage_percent", "Memory usage.", func(s *models.HostStats) float64 { return s.MemoryUsage })
+	gauge("hostmanager_host_swap_total_bytes", "Total swap.", func(s *models.HostStats) float64 { return float64(s.SwapTotal) })
+	gauge("hostmanager_host_swap_used_bytes", "Used swap.", func(s *models.HostStats) float64 { return float64(s.SwapUsed) })
+
+	filesystemGauge := func(name, help string, value func(models.FilesystemStats) float64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, e := range entries {
+			if e.stats == nil {
+				continue
+			}
+			for _, fs := range e.stats.Filesystems {
+				fmt.Fprintf(w, "%s{%s,%s} %s\n", name, e.labels,
+					labelPairs("device", fs.Device, "mountpoint", fs.MountPoint, "fstype", fs.FSType), formatFloat(value(fs)))
+			}
+		}
+	}
+	filesystemGauge("hostmanager_host_filesystem_size_bytes", "Filesystem size.", func(fs models.FilesystemStats) float64 { return float64(fs.Total) })
+	filesystemGauge("hostmanager_host_filesystem_used_bytes", "Filesystem used space.", func(fs models.FilesystemStats) float64 { return float64(fs.Used) })
+	filesystemGauge("hostmanager_host_filesystem_avail_bytes", "Filesystem space available to unprivileged users.", func(fs models.FilesystemStats) float64 { return float64(fs.Available) })
+	filesystemGauge("hostmanager_host_filesystem_files", "Filesystem total inodes.", func(fs models.FilesystemStats) float64 { return float64(fs.InodesTotal) })
+	filesystemGauge("hostmanager_host_filesystem_files_used", "Filesystem used inodes.", func(fs models.FilesystemStats) float64 { return float64(fs.InodesUsed) })
+
+	interfaceCounter := func(name, help string, value func(models.InterfaceStats) uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, e := range entries {
+			if e.stats == nil {
+				continue
+			}
+			for _, iface := range e.stats.Interfaces {
+				fmt.Fprintf(w, "%s{%s,%s} %d\n", name, e.labels, labelPairs("device", iface.Name), value(iface))
+			}
+		}
+	}
+	interfaceCounter("hostmanager_host_network_receive_bytes_total", "Bytes received per interface.", func(i models.InterfaceStats) uint64 { return i.RxBytes })
+	interfaceCounter("hostmanager_host_network_transmit_bytes_total", "Bytes transmitted per interface.", func(i models.InterfaceStats) uint64 { return i.TxBytes })
+	interfaceCounter("hostmanager_host_network_receive_packets_total", "Packets received per interface.", func(i models.InterfaceStats) uint64 { return i.RxPackets })
+	interfaceCounter("hostmanager_host_network_transmit_packets_total", "Packets transmitted per interface.", func(i models.InterfaceStats) uint64 { return i.TxPackets })
+
+	gauge("hostmanager_host_uptime_seconds", "Host uptime.", func(s *models.HostStats) float64 { return float64(s.Uptime) })
+	gauge("hostmanager_host_processes", "Number of processes.", func(s *models.HostStats) float64 { return float64(s.ProcessCount) })
+	gauge("hostmanager_host_logged_in_users", "Number of login sessions.", func(s *models.HostStats) float64 { return float64(s.LoggedInUsers) })
+	gauge("hostmanager_host_stats_timestamp_seconds", "Unix time of the last successful stats collection.", func(s *models.HostStats) float64 {
+		return float64(s.UpdatedAt.Unix())
+	})
+
+	fmt.Fprintf(w, "# HELP hostmanager_terminal_sessions_active Open web terminal sessions.\n# TYPE hostmanager_terminal_sessions_active gauge\n")
+	fmt.Fprintf(w, "hostmanager_terminal_sessions_active %d\n", atomic.LoadInt64(&activeTerminalSessions))
+
+	sshDialDuration.write(w, "hostmanager_ssh_dial_duration_seconds", "Time to establish SSH connections, including jump hosts and authentication.")
+	sshDialErrors.write(w, "hostmanager_ssh_dial_errors_total", "Failed SSH connection attempts.")
+	apiRequestDuration.write(w, "hostmanager_http_request_duration_seconds", "API request duration.")
+	auditDroppedOperations.write(w, "hostmanager_audit_dropped_operations_total", "Terminal audit records dropped because the session queue was full.")
+
+	return nil
+}
+
+// 标签名按字母排序后以逗号连接，便于在 PromQL 中用正则匹配，如 tags=~"(.*,)?prod(,.*)?"
+func hostTagsLabel(tags []models.Tag) string {
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// 将键值对拼接为Prometheus标签，值按文本格式转义
+func labelPairs(kv ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(kv[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabelValue(kv[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func escapeLabelValue(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
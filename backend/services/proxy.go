@@ -0,0 +1,138 @@
+package services
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"host-manager/models"
+
+	"golang.org/x/net/proxy"
+)
+
+// 主机上表示不使用全局代理的取值
+const proxyDirect = "direct"
+
+// 确定主机使用的代理：主机单独配置优先，其次为环境变量 SSH_PROXY
+func proxyURLForHost(host *models.Host) (*url.URL, error) {
+	raw := strings.TrimSpace(host.ProxyURL)
+	if raw == "" {
+		raw = strings.TrimSpace(os.Getenv("SSH_PROXY"))
+	}
+	if raw == "" || raw == proxyDirect {
+		return nil, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("无效的代理地址 %q: %v", raw, err)
+	}
+	switch u.Scheme {
+	case "socks5", "socks5h", "http":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议 %q（支持 socks5、http）", u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("代理地址缺少主机名: %q", raw)
+	}
+	return u, nil
+}
+
+// ValidateProxyURL 校验主机的代理配置
+func ValidateProxyURL(host *models.Host) error {
+	_, err := proxyURLForHost(host)
+	return err
+}
+
+// 建立到目标地址的TCP连接，按配置经由 SOCKS5 或 HTTP CONNECT 代理
+func dialTCP(host *models.Host, addr string, timeout time.Duration) (net.Conn, error) {
+	proxyURL, err := proxyURLForHost(host)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	if proxyURL == nil {
+		return dialer.Dial("tcp", addr)
+	}
+
+	switch proxyURL.Scheme {
+	case "http":
+		return dialHTTPConnect(dialer, proxyURL, addr, timeout)
+	default:
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		socks, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, dialer)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := socks.Dial("tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("通过SOCKS5代理 %s 连接失败: %v", proxyURL.Host, err)
+		}
+		return conn, nil
+	}
+}
+
+// 通过 HTTP CONNECT 代理建立隧道
+func dialHTTPConnect(dialer *net.Dialer, proxyURL *url.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	conn, err := dialer.Dial("tcp", proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("连接HTTP代理 %s 失败: %v", proxyURL.Host, err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("发送CONNECT请求失败: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("读取HTTP代理响应失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("HTTP代理拒绝连接: %s", resp.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+	// SSH 服务端先发送 banner，常与 CONNECT 响应在同一次读取中到达，已读入缓冲区的数据需先交给SSH握手
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, reader: reader}, nil
+	}
+	return conn, nil
+}
+
+// 先读出缓冲区中已读入的数据，再从连接读取
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
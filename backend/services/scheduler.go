@@ -0,0 +1,281 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+)
+
+const (
+	// 调度循环的最长休眠时间，防止时钟跳变后长期不检查
+	schedulerMaxSleep = time.Minute
+	// 单个任务同时执行的主机数上限
+	jobHostConcurrency = 10
+)
+
+// Scheduler 定时任务调度器，任务定义和下次触发时间持久化在数据库中，重启后继续调度
+type Scheduler struct {
+	sshService *SSHService
+
+	mu      sync.Mutex
+	running map[uint]bool // 正在执行的任务，用于防止重叠执行
+	wake    chan struct{}
+	started bool
+}
+
+var (
+	schedulerOnce     sync.Once
+	schedulerInstance *Scheduler
+)
+
+// GetScheduler 返回全局调度器
+func GetScheduler() *Scheduler {
+	schedulerOnce.Do(func() {
+		schedulerInstance = &Scheduler{
+			sshService: NewSSHService(),
+			running:    make(map[uint]bool),
+			wake:       make(chan struct{}, 1),
+		}
+	})
+	return schedulerInstance
+}
+
+// Start 启动调度循环
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	if s.started {
+		s.mu.Unlock()
+		return
+	}
+	s.started = true
+	s.mu.Unlock()
+
+	// 补全缺少下次触发时间的任务（新建或升级前的数据）
+	var jobs []models.ScheduledJob
+	config.DB.Where("enabled = ? AND next_run_at IS NULL", true).Find(&jobs)
+	for i := range jobs {
+		s.scheduleNext(&jobs[i], time.Now())
+	}
+
+	go s.loop()
+	log.Println("Job scheduler started")
+}
+
+// Reload 任务变更后唤醒调度循环重新计算
+func (s *Scheduler) Reload() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *Scheduler) loop() {
+	for {
+		s.runDueJobs()
+
+		sleep := schedulerMaxSleep
+		var next models.ScheduledJob
+		result := config.DB.Where("enabled = ? AND next_run_at IS NOT NULL", true).
+			Order("next_run_at ASC").
+			Limit(1).
+			Find(&next)
+		if result.Error == nil && result.RowsAffected > 0 && next.NextRunAt != nil {
+			if d := time.Until(*next.NextRunAt); d < sleep {
+				sleep = d
+			}
+		}
+		if sleep < 0 {
+			sleep = 0
+		}
+
+		timer := time.NewTimer(sleep)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+			timer.Stop()
+		}
+	}
+}
+
+func (s *Scheduler) runDueJobs() {
+	now := time.Now()
+	var jobs []models.ScheduledJob
+	result := config.DB.Where("enabled = ? AND next_run_at <= ?", true, now).Find(&jobs)
+	if result.Error != nil {
+		log.Printf("Failed to load due jobs: %v", result.Error)
+		return
+	}
+
+	for i := range jobs {
+		job := jobs[i]
+		// 先推进下次触发时间，重启时错过的多次触发只补执行一次
+		s.scheduleNext(&job, now)
+		go s.execute(job.ID, "schedule")
+	}
+}
+
+// 计算并保存下次触发时间（含随机抖动）
+func (s *Scheduler) scheduleNext(job *models.ScheduledJob, from time.Time) {
+	next, err := NextRunTime(job, from)
+	if err != nil {
+		log.Printf("Invalid schedule for job %d: %v", job.ID, err)
+		config.DB.Model(job).Update("next_run_at", nil)
+		return
+	}
+	config.DB.Model(job).Update("next_run_at", next)
+}
+
+// NextRunTime 按任务的时区和抖动设置计算from之后的下次触发时间
+func NextRunTime(job *models.ScheduledJob, from time.Time) (time.Time, error) {
+	schedule, err := ParseCron(job.CronExpr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc, err := loadJobLocation(job.Timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	next := schedule.Next(from.In(loc))
+	if next.IsZero() {
+		return time.Time{}, errors.New("cron表达式永远不会触发")
+	}
+	if job.JitterSeconds > 0 {
+		next = next.Add(time.Duration(rand.Int63n(int64(job.JitterSeconds)+1)) * time.Second)
+	}
+	return next, nil
+}
+
+func loadJobLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("无效的时区 %q", name)
+	}
+	return loc, nil
+}
+
+// RunNow 立即执行一次任务，不影响定时计划
+func (s *Scheduler) RunNow(jobID uint) error {
+	if s.isRunning(jobID) {
+		return errors.New("任务正在执行中")
+	}
+	go s.execute(jobID, "manual")
+	return nil
+}
+
+func (s *Scheduler) isRunning(jobID uint) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running[jobID]
+}
+
+func (s *Scheduler) execute(jobID uint, trigger string) {
+	var job models.ScheduledJob
+	if err := config.DB.Preload("Script").Preload("Hosts").First(&job, jobID).Error; err != nil {
+		log.Printf("Failed to load job %d: %v", jobID, err)
+		return
+	}
+
+	now := time.Now()
+
+	// 防止重叠执行：上一次还没结束则记录为跳过
+	s.mu.Lock()
+	if s.running[job.ID] {
+		s.mu.Unlock()
+		for _, host := range job.Hosts {
+			config.DB.Create(&models.JobRun{
+				JobID:     job.ID,
+				HostID:    host.ID,
+				Trigger:   trigger,
+				Status:    "skipped",
+				Error:     "上一次执行尚未结束",
+				StartTime: now,
+				EndTime:   &now,
+			})
+		}
+		config.DB.Model(&job).Updates(map[string]interface{}{"last_run_at": now, "last_status": "skipped"})
+		return
+	}
+	s.running[job.ID] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, job.ID)
+		s.mu.Unlock()
+	}()
+
+	command, stdin := jobCommand(&job)
+	timeout := time.Duration(job.TimeoutSeconds) * time.Second
+
+	var wg sync.WaitGroup
+	var failedMu sync.Mutex
+	failed := 0
+	sem := make(chan struct{}, jobHostConcurrency)
+
+	for i := range job.Hosts {
+		host := job.Hosts[i]
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			run := models.JobRun{
+				JobID:     job.ID,
+				HostID:    host.ID,
+				Trigger:   trigger,
+				Status:    "running",
+				StartTime: time.Now(),
+			}
+			config.DB.Create(&run)
+
+			var input io.Reader
+			if stdin != "" {
+				input = strings.NewReader(stdin)
+			}
+			output, err := s.sshService.RunCommand(&host, command, input, timeout)
+
+			end := time.Now()
+			updates := map[string]interface{}{
+				"output":   output,
+				"end_time": &end,
+				"status":   "success",
+			}
+			if err != nil {
+				updates["status"] = "failed"
+				updates["error"] = err.Error()
+				failedMu.Lock()
+				failed++
+				failedMu.Unlock()
+			}
+			config.DB.Model(&run).Updates(updates)
+		}()
+	}
+	wg.Wait()
+
+	status := "success"
+	if failed > 0 {
+		status = "failed"
+	}
+	config.DB.Model(&job).Updates(map[string]interface{}{"last_run_at": now, "last_status": status})
+}
+
+// 保存的脚本通过标准输入交给远程shell执行，避免转义问题
+func jobCommand(job *models.ScheduledJob) (string, string) {
+	if job.Script != nil {
+		return "sh -s", job.Script.Content
+	}
+	return job.Command, ""
+}
@@ -3,8 +3,10 @@ package services
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"host-manager/models"
@@ -29,24 +31,92 @@ type FileInfo struct {
 	Permissions string    `json:"permissions"`
 }
 
-// 创建SSH连接
+// 创建SSH连接，并记录拨号耗时和失败次数
 func (s *SSHService) createConnection(host *models.Host) (*ssh.Client, error) {
-	config := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
-	}
+	start := time.Now()
+	client, err := s.connect(host)
+	observeSSHDial(host, time.Since(start), err)
+	return client, err
+}
 
-	addr := fmt.Sprintf("%s:%d", host.IPAddress, host.Port)
-	client, err := ssh.Dial("tcp", addr, config)
+// 配置了跳板机时按 ProxyJump 方式逐跳建立隧道
+func (s *SSHService) connect(host *models.Host) (*ssh.Client, error) {
+	chain, err := resolveJumpChain(host)
 	if err != nil {
-		return nil, fmt.Errorf("SSH连接失败: %v", err)
+		return nil, err
 	}
+	return s.connectChain(chain)
+}
+
+// 按顺序连接跳板机链，最后一个为目标主机
+func (s *SSHService) connectChain(chain []*models.Host) (*ssh.Client, error) {
+	host := chain[len(chain)-1]
 
-	return client, nil
+	// 依次连接每一跳，后一跳通过前一跳的 direct-tcpip 通道建立
+	var clients []*ssh.Client
+	closeAll := func() {
+		for i := len(clients) - 1; i >= 0; i-- {
+			clients[i].Close()
+		}
+	}
+
+	for _, hop := range chain {
+		config, err := s.clientConfig(hop)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		addr := fmt.Sprintf("%s:%d", hop.IPAddress, hop.Port)
+		var client *ssh.Client
+		if len(clients) == 0 {
+			// 只有第一跳直接拨号（可经由代理），其余各跳走SSH隧道
+			client, err = dialFirstHop(hop, addr, config)
+		} else {
+			client, err = dialThrough(clients[len(clients)-1], addr, config)
+		}
+		if err != nil {
+			closeAll()
+			if hop != host {
+				return nil, fmt.Errorf("SSH连接失败（跳板机 %s）: %v", hop.Name, err)
+			}
+			return nil, fmt.Errorf("SSH连接失败: %v", err)
+		}
+		clients = append(clients, client)
+	}
+
+	// 目标连接关闭后依次关闭各跳板机连接
+	target := clients[len(clients)-1]
+	if len(clients) > 1 {
+		go func() {
+			target.Wait()
+			closeAll()
+		}()
+	}
+
+	return target, nil
+}
+
+// 生成单跳的SSH客户端配置
+func (s *SSHService) clientConfig(host *models.Host) (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+	if host.PrivateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(host.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("解析主机 %s 的私钥失败: %v", host.Name, err)
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+	if host.Password != "" {
+		auth = append(auth, ssh.Password(host.Password))
+	}
+
+	return &ssh.ClientConfig{
+		User:            host.Username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback(host),
+		Timeout:         10 * time.Second,
+	}, nil
 }
 
 // 执行SSH命令
@@ -71,6 +141,104 @@ func (s *SSHService) executeCommand(host *models.Host, command string) (string,
 	return string(output), nil
 }
 
+// 执行命令并返回输出（失败时同样返回已产生的输出），timeout<=0 表示不限时
+func (s *SSHService) RunCommand(host *models.Host, command string, stdin io.Reader, timeout time.Duration) (string, error) {
+	client, err := s.createConnection(host)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return "", fmt.Errorf("创建SSH会话失败: %v", err)
+	}
+	defer session.Close()
+
+	if stdin != nil {
+		session.Stdin = stdin
+	}
+
+	// 标准输出和标准错误由各自的协程复制，共用缓冲区时需加锁
+	var output syncBuffer
+	session.Stdout = &output
+	session.Stderr = &output
+
+	done := make(chan error, 1)
+	go func() {
+		done <- session.Run(command)
+	}()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		timer = time.After(timeout)
+	}
+
+	select {
+	case err = <-done:
+	case <-timer:
+		// 关闭连接以终止远程命令
+		client.Close()
+		<-done
+		return output.String(), fmt.Errorf("命令执行超时（%v）", timeout)
+	}
+
+	if err != nil {
+		return output.String(), fmt.Errorf("执行命令失败: %v", err)
+	}
+	return output.String(), nil
+}
+
+// 并发安全的输出缓冲区
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// RunPrivileged 以特权执行命令：主机启用 use_sudo 且登录用户不是 root 时通过 sudo 执行，
+// 密码经标准输入传给 sudo，不会出现在远程命令行中
+func (s *SSHService) RunPrivileged(host *models.Host, command string, timeout time.Duration) (string, error) {
+	command, password := privilegedCommand(host, command)
+	var stdin io.Reader
+	if password != "" {
+		stdin = strings.NewReader(password)
+	}
+	return s.RunCommand(host, command, stdin, timeout)
+}
+
+// 按主机的 sudo 配置包装命令，返回包装后的命令和需要先写入标准输入的密码行（可能为空）
+func privilegedCommand(host *models.Host, command string) (string, string) {
+	if !host.UseSudo || host.Username == "root" {
+		return command, ""
+	}
+
+	password := host.SudoPassword
+	if password == "" {
+		password = host.Password
+	}
+	if password == "" {
+		return "sudo -n sh -c " + shellQuote(command), ""
+	}
+	return "sudo -S -p '' sh -c " + shellQuote(command), password + "\n"
+}
+
+// 将参数用单引号包裹，供远程shell使用
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 // 获取文件列表
 func (s *SSHService) ListFiles(host *models.Host, path string) ([]FileInfo, error) {
 	// 使用 ls -la 命令获取详细文件信息
@@ -230,86 +398,13 @@ func (s *SSHService) TestConnection(host *models.Host) error {
 	return err
 }
 
-func (s *SSHService) GetHostStats(host *models.Host) (*models.HostStats, error) {
-	client, err := s.createConnection(host)
-	if err != nil {
-		return nil, err
-	}
-	defer client.Close()
-
-	stats := &models.HostStats{}
-
-	// 获取CPU使用率
-	cpuOutput, err := s.executeCommand(host, "top -bn1 | grep 'Cpu(s)' | awk '{print $2}' | cut -d'%' -f1")
-	if err == nil {
-		if cpuUsage, parseErr := strconv.ParseFloat(strings.TrimSpace(cpuOutput), 64); parseErr == nil {
-			stats.CPUUsage = cpuUsage
-		}
-	}
-
-	// 获取内存信息
-	memOutput, err := s.executeCommand(host, "free -b | grep Mem")
-	if err == nil {
-		fields := strings.Fields(memOutput)
-		if len(fields) >= 3 {
-			if total, parseErr := strconv.ParseUint(fields[1], 10, 64); parseErr == nil {
-				stats.MemoryTotal = total
-			}
-			if used, parseErr := strconv.ParseUint(fields[2], 10, 64); parseErr == nil {
-				stats.MemoryUsed = used
-				if stats.MemoryTotal > 0 {
-					stats.MemoryUsage = float64(used) / float64(stats.MemoryTotal) * 100
-				}
-			}
-		}
-	}
-
-	// 获取磁盘信息
-	diskOutput, err := s.executeCommand(host, "df -B1 / | tail -1")
-	if err == nil {
-		fields := strings.Fields(diskOutput)
-		if len(fields) >= 4 {
-			if total, parseErr := strconv.ParseUint(fields[1], 10, 64); parseErr == nil {
-				stats.DiskTotal = total
-			}
-			if used, parseErr := strconv.ParseUint(fields[2], 10, 64); parseErr == nil {
-				stats.DiskUsed = used
-				if stats.DiskTotal > 0 {
-					stats.DiskUsage = float64(used) / float64(stats.DiskTotal) * 100
-				}
-			}
-		}
-	}
-
-	// 获取网络流量信息
-	netOutput, err := s.executeCommand(host, "cat /proc/net/dev | grep eth0")
-	if err == nil {
-		fields := strings.Fields(netOutput)
-		if len(fields) >= 10 {
-			if rxBytes, parseErr := strconv.ParseUint(fields[1], 10, 64); parseErr == nil {
-				stats.NetworkIn = rxBytes
-			}
-			if txBytes, parseErr := strconv.ParseUint(fields[9], 10, 64); parseErr == nil {
-				stats.NetworkOut = txBytes
-			}
-		}
-	}
-
-	return stats, nil
-}
-
 func (s *SSHService) CreateTerminalSession(host *models.Host) (*ssh.Client, *ssh.Session, error) {
-	config := &ssh.ClientConfig{
-		User: host.Username,
-		Auth: []ssh.AuthMethod{
-			ssh.Password(host.Password),
-		},
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
-		Timeout:         10 * time.Second,
-	}
+	return s.createTerminalSession(host, 1)
+}
 
-	address := fmt.Sprintf("%s:%d", host.IPAddress, host.Port)
-	client, err := ssh.Dial("tcp", address, config)
+// 创建带PTY的会话，echo 为终端初始的回显模式
+func (s *SSHService) createTerminalSession(host *models.Host, echo uint32) (*ssh.Client, *ssh.Session, error) {
+	client, err := s.createConnection(host)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -317,12 +412,12 @@ func (s *SSHService) CreateTerminalSession(host *models.Host) (*ssh.Client, *ssh
 	session, err := client.NewSession()
 	if err != nil {
 		client.Close()
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("创建SSH会话失败: %v", err)
 	}
 
 	// 设置终端模式
 	modes := ssh.TerminalModes{
-		ssh.ECHO:          1,
+		ssh.ECHO:          echo,
 		ssh.TTY_OP_ISPEED: 14400,
 		ssh.TTY_OP_OSPEED: 14400,
 	}
@@ -330,7 +425,7 @@ func (s *SSHService) CreateTerminalSession(host *models.Host) (*ssh.Client, *ssh
 	if err := session.RequestPty("xterm", 80, 24, modes); err != nil {
 		session.Close()
 		client.Close()
-		return nil, nil, err
+		return nil, nil, fmt.Errorf("请求终端失败: %v", err)
 	}
 
 	return client, session, nil
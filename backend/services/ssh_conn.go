@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// 跳板机链的最大长度
+const maxJumpHops = 8
+
+// 解析主机的跳板机链，返回从最外层跳板机到目标主机的顺序列表
+func resolveJumpChain(host *models.Host) ([]*models.Host, error) {
+	chain := []*models.Host{host}
+	visited := map[uint]bool{}
+	if host.ID != 0 {
+		visited[host.ID] = true
+	}
+
+	current := host
+	for current.JumpHostID != nil {
+		if len(chain) > maxJumpHops {
+			return nil, fmt.Errorf("跳板机链超过%d跳", maxJumpHops)
+		}
+		if visited[*current.JumpHostID] {
+			return nil, errors.New("跳板机配置存在循环引用")
+		}
+
+		var jump models.Host
+		if err := config.DB.First(&jump, *current.JumpHostID).Error; err != nil {
+			return nil, fmt.Errorf("跳板机(ID=%d)不存在", *current.JumpHostID)
+		}
+		visited[jump.ID] = true
+		chain = append([]*models.Host{&jump}, chain...)
+		current = &jump
+	}
+
+	return chain, nil
+}
+
+// ValidateJumpHost 校验为主机设置的跳板机是否存在且不会形成循环
+func ValidateJumpHost(host *models.Host) error {
+	if host.JumpHostID == nil {
+		return nil
+	}
+	if host.ID != 0 && *host.JumpHostID == host.ID {
+		return errors.New("不能将主机自身设为跳板机")
+	}
+	_, err := resolveJumpChain(host)
+	return err
+}
+
+// 拨号并完成第一跳的SSH握手
+func dialFirstHop(host *models.Host, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := dialTCP(host, addr, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// 握手阶段同样受超时限制
+	conn.SetDeadline(time.Now().Add(config.Timeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// 经由跳板机拨号，跳板机连接目标较慢时按超时返回
+func dialThroughTimeout(via *ssh.Client, addr string, timeout time.Duration) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := via.Dial("tcp", addr)
+		done <- result{conn, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.conn, r.err
+	case <-time.After(timeout):
+		// 超时后由调用方关闭跳板机连接，迟到的连接随之失效
+		return nil, fmt.Errorf("经由跳板机连接 %s 超时", addr)
+	}
+}
+
+// 通过已建立的SSH连接拨号到下一跳，失败时由调用方关闭 via，超时后迟到的通道随之失效
+func dialThrough(via *ssh.Client, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	conn, err := dialThroughTimeout(via, addr, config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// 通道连接不支持 SetDeadline，握手超时后直接关闭连接
+	timer := time.AfterFunc(config.Timeout, func() { conn.Close() })
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, config)
+	if !timer.Stop() {
+		if err == nil {
+			c.Close()
+		}
+		return nil, fmt.Errorf("握手超时（%v）", config.Timeout)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// 主机密钥校验：首次连接时记录指纹（TOFU），之后必须一致
+func hostKeyCallback(host *models.Host) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		if host.HostKey == "" {
+			host.HostKey = fingerprint
+			if host.ID != 0 {
+				config.DB.Model(&models.Host{}).Where("id = ?", host.ID).Update("host_key", fingerprint)
+			}
+			return nil
+		}
+		if host.HostKey != fingerprint {
+			return fmt.Errorf("主机 %s 的密钥不匹配（记录为 %s，实际为 %s），可能存在中间人攻击", host.Name, host.HostKey, fingerprint)
+		}
+		return nil
+	}
+}
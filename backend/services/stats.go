@@ -0,0 +1,521 @@
+package services
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/models"
+)
+
+// 统计采集的超时时间（含两次采样之间的1秒间隔）
+const statsTimeout = 15 * time.Second
+
+// 一次SSH会话内完成全部采集，只依赖 /proc 和 POSIX df，兼容 BusyBox/Alpine。
+// 各段以 "==名称==" 分隔；CPU 和网卡速率在间隔1秒的两次采样之间计算。
+const statsScript = `export LC_ALL=C
+echo '==STAT1=='; grep '^cpu' /proc/stat
+echo '==NETDEV1=='; cat /proc/net/dev
+echo '==UPTIME1=='; cat /proc/uptime
+sleep 1
+echo '==STAT2=='; grep '^cpu' /proc/stat
+echo '==NETDEV2=='; cat /proc/net/dev
+echo '==UPTIME2=='; cat /proc/uptime
+echo '==MEMINFO=='; cat /proc/meminfo
+echo '==LOADAVG=='; cat /proc/loadavg
+echo '==MOUNTS=='; cat /proc/mounts
+echo '==DF=='; df -Pk 2>&1
+echo '==DFI=='; df -Pi 2>&1
+echo '==PROCS=='; ls -d /proc/[0-9]* 2>/dev/null | wc -l
+echo '==WHO=='; who 2>/dev/null
+echo '==END=='
+`
+
+// GetHostStats 采集主机的CPU、内存、磁盘、网络和负载等信息，单项失败记录在 Errors 中
+func (s *SSHService) GetHostStats(host *models.Host) (*models.HostStats, error) {
+	output, err := s.RunCommand(host, "sh -s", strings.NewReader(statsScript), statsTimeout)
+	sections := splitStatsSections(output)
+	if err != nil && len(sections) == 0 {
+		return nil, err
+	}
+
+	stats := &models.HostStats{
+		HostID:    host.ID,
+		UpdatedAt: time.Now(),
+		Errors:    make(map[string]string),
+	}
+
+	collect := func(metric string, parse func() error) {
+		if parseErr := parse(); parseErr != nil {
+			stats.Errors[metric] = parseErr.Error()
+		}
+	}
+	interval := sampleInterval(sections["UPTIME1"], sections["UPTIME2"])
+
+	collect("cpu", func() error { return parseCPUUsage(stats, sections) })
+	memInfo := parseMeminfoValues(sections["MEMINFO"])
+	collect("memory", func() error { return parseMemInfo(stats, memInfo) })
+	collect("swap", func() error { return parseSwap(stats, memInfo) })
+	collect("load", func() error { return parseLoadAvg(stats, sections["LOADAVG"]) })
+	collect("network", func() error { return parseNetDev(stats, sections["NETDEV1"], sections["NETDEV2"], interval) })
+	collect("disk", func() error { return parseDF(stats, sections["DF"], sections["MOUNTS"]) })
+	collect("inodes", func() error { return parseDFInodes(stats, sections["DFI"]) })
+	collect("uptime", func() error { return parseUptime(stats, sections["UPTIME2"]) })
+	collect("processes", func() error { return parseProcessCount(stats, sections["PROCS"]) })
+	collect("users", func() error { return parseWho(stats, sections) })
+
+	if len(stats.Errors) == 0 {
+		stats.Errors = nil
+	}
+	return stats, nil
+}
+
+// 按 "==名称==" 标记切分脚本输出
+func splitStatsSections(output string) map[string][]string {
+	sections := make(map[string][]string)
+	current := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(line, "==") && strings.HasSuffix(line, "==") && len(line) > 4 {
+			current = strings.Trim(line, "=")
+			sections[current] = []string{}
+			continue
+		}
+		if current != "" {
+			sections[current] = append(sections[current], line)
+		}
+	}
+	return sections
+}
+
+// 解析 /proc/stat 中 cpu 行，返回总时间和空闲时间（jiffies）
+func parseCPULine(line string) (total, idle uint64, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 {
+		return 0, 0, fmt.Errorf("无法解析CPU行: %q", line)
+	}
+
+	// user nice system idle iowait irq softirq steal（guest已计入user，不重复累加）
+	for i := 1; i < len(fields) && i <= 8; i++ {
+		v, err := strconv.ParseUint(fields[i], 10, 64)
+		if err != nil {
+			return 0, 0, fmt.Errorf("无法解析CPU行: %q", line)
+		}
+		total += v
+		if i == 4 || i == 5 {
+			idle += v
+		}
+	}
+	return total, idle, nil
+}
+
+// 两次采样之间的使用率
+func cpuPercent(first, second string) (float64, error) {
+	total1, idle1, err := parseCPULine(first)
+	if err != nil {
+		return 0, err
+	}
+	total2, idle2, err := parseCPULine(second)
+	if err != nil {
+		return 0, err
+	}
+	// 内核的 iowait 计数可能回退，用有符号差值计算，避免无符号相减回绕成极大的值
+	totalDelta := int64(total2 - total1)
+	idleDelta := int64(idle2 - idle1)
+	if totalDelta <= 0 {
+		return 0, errors.New("两次CPU采样之间没有变化")
+	}
+
+	busy := min(max(totalDelta-idleDelta, 0), totalDelta)
+	return float64(busy) / float64(totalDelta) * 100, nil
+}
+
+func parseCPUUsage(stats *models.HostStats, sections map[string][]string) error {
+	first, second := sections["STAT1"], sections["STAT2"]
+	if len(first) == 0 || len(second) == 0 {
+		return errors.New("无法读取 /proc/stat")
+	}
+
+	usage, err := cpuPercent(first[0], second[0])
+	if err != nil {
+		return err
+	}
+	stats.CPUUsage = usage
+
+	// 按CPU名称匹配两次采样，CPU热插拔时以第二次为准
+	earlier := make(map[string]string)
+	for _, line := range first[1:] {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			earlier[fields[0]] = line
+		}
+	}
+	for _, line := range second[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		stats.CPUCount++
+		core := 0.0
+		if prev, ok := earlier[fields[0]]; ok {
+			if v, err := cpuPercent(prev, line); err == nil {
+				core = v
+			}
+		}
+		stats.CPUCores = append(stats.CPUCores, core)
+	}
+	return nil
+}
+
+// 两次采样之间的实际间隔（秒），取自 /proc/uptime，无法获取时按1秒计算
+func sampleInterval(first, second []string) float64 {
+	if len(first) == 0 || len(second) == 0 {
+		return 1
+	}
+	t1, err1 := parseUptimeSeconds(first[0])
+	t2, err2 := parseUptimeSeconds(second[0])
+	if err1 != nil || err2 != nil || t2 <= t1 {
+		return 1
+	}
+	return t2 - t1
+}
+
+func parseUptimeSeconds(line string) (float64, error) {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("无法解析 /proc/uptime: %q", line)
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+func parseUptime(stats *models.HostStats, lines []string) error {
+	if len(lines) == 0 {
+		return errors.New("无法读取 /proc/uptime")
+	}
+	seconds, err := parseUptimeSeconds(lines[0])
+	if err != nil {
+		return fmt.Errorf("无法解析 /proc/uptime: %q", lines[0])
+	}
+	stats.Uptime = uint64(seconds)
+	return nil
+}
+
+func parseProcessCount(stats *models.HostStats, lines []string) error {
+	if len(lines) == 0 {
+		return errors.New("无法统计进程数")
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil || count == 0 {
+		return errors.New("无法统计进程数")
+	}
+	stats.ProcessCount = count
+	return nil
+}
+
+// 解析 who 输出；没有 who 命令（如精简容器）时记录错误
+func parseWho(stats *models.HostStats, sections map[string][]string) error {
+	lines, ok := sections["WHO"]
+	if !ok {
+		return errors.New("无法执行 who")
+	}
+
+	seen := make(map[string]bool)
+	stats.Users = []string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		stats.LoggedInUsers++
+		if !seen[fields[0]] {
+			seen[fields[0]] = true
+			stats.Users = append(stats.Users, fields[0])
+		}
+	}
+	return nil
+}
+
+// 解析 /proc/meminfo，值统一换算为字节
+func parseMeminfoValues(lines []string) map[string]uint64 {
+	values := make(map[string]uint64)
+	for _, line := range lines {
+		key, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			continue
+		}
+		if len(fields) > 1 && strings.EqualFold(fields[1], "kB") {
+			v *= 1024
+		}
+		values[key] = v
+	}
+	return values
+}
+
+func parseMemInfo(stats *models.HostStats, values map[string]uint64) error {
+	total, ok := values["MemTotal"]
+	if !ok || total == 0 {
+		return errors.New("无法读取 /proc/meminfo")
+	}
+
+	// 老内核没有 MemAvailable，按 free+buffers+cached 估算
+	available, ok := values["MemAvailable"]
+	if !ok {
+		available = values["MemFree"] + values["Buffers"] + values["Cached"]
+	}
+	if available > total {
+		available = total
+	}
+
+	stats.MemoryTotal = total
+	stats.MemoryUsed = total - available
+	stats.MemoryUsage = float64(stats.MemoryUsed) / float64(total) * 100
+	return nil
+}
+
+func parseSwap(stats *models.HostStats, values map[string]uint64) error {
+	total, ok := values["SwapTotal"]
+	if !ok {
+		return errors.New("无法读取交换分区信息")
+	}
+	free := values["SwapFree"]
+	if free > total {
+		free = total
+	}
+
+	stats.SwapTotal = total
+	stats.SwapUsed = total - free
+	if total > 0 {
+		stats.SwapUsage = float64(stats.SwapUsed) / float64(total) * 100
+	}
+	return nil
+}
+
+func parseLoadAvg(stats *models.HostStats, lines []string) error {
+	if len(lines) == 0 {
+		return errors.New("无法读取 /proc/loadavg")
+	}
+	fields := strings.Fields(lines[0])
+	if len(fields) < 3 {
+		return fmt.Errorf("无法解析 /proc/loadavg: %q", lines[0])
+	}
+
+	loads := make([]float64, 3)
+	for i := range loads {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return fmt.Errorf("无法解析 /proc/loadavg: %q", lines[0])
+		}
+		loads[i] = v
+	}
+	stats.Load1, stats.Load5, stats.Load15 = loads[0], loads[1], loads[2]
+	return nil
+}
+
+// 网卡累计流量
+type netCounters struct {
+	RxBytes, RxPackets, TxBytes, TxPackets uint64
+}
+
+// 解析 /proc/net/dev，返回各网卡的累计计数
+func parseNetDevCounters(lines []string) map[string]netCounters {
+	counters := make(map[string]netCounters)
+	for _, line := range lines {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		fields := strings.Fields(rest)
+		if name == "" || len(fields) < 10 {
+			continue
+		}
+
+		var values [4]uint64
+		valid := true
+		for i, idx := range []int{0, 1, 8, 9} {
+			v, err := strconv.ParseUint(fields[idx], 10, 64)
+			if err != nil {
+				valid = false
+				break
+			}
+			values[i] = v
+		}
+		if valid {
+			counters[name] = netCounters{RxBytes: values[0], RxPackets: values[1], TxBytes: values[2], TxPackets: values[3]}
+		}
+	}
+	return counters
+}
+
+// 统计每块网卡的流量和速率，并汇总除回环外的网卡，不依赖网卡命名（eth*、ens*、enp* 等）
+func parseNetDev(stats *models.HostStats, first, second []string, interval float64) error {
+	before := parseNetDevCounters(first)
+	after := parseNetDevCounters(second)
+	if len(after) == 0 {
+		return errors.New("无法读取 /proc/net/dev")
+	}
+
+	names := make([]string, 0, len(after))
+	for name := range after {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c := after[name]
+		iface := models.InterfaceStats{
+			Name:      name,
+			RxBytes:   c.RxBytes,
+			TxBytes:   c.TxBytes,
+			RxPackets: c.RxPackets,
+			TxPackets: c.TxPackets,
+		}
+		// 计数器回绕或网卡重建时不计算速率
+		if prev, ok := before[name]; ok && c.RxBytes >= prev.RxBytes && c.TxBytes >= prev.TxBytes {
+			iface.RxRate = float64(c.RxBytes-prev.RxBytes) / interval
+			iface.TxRate = float64(c.TxBytes-prev.TxBytes) / interval
+		}
+		stats.Interfaces = append(stats.Interfaces, iface)
+
+		if name == "lo" {
+			continue
+		}
+		stats.NetworkIn += iface.RxBytes
+		stats.NetworkOut += iface.TxBytes
+		stats.NetworkInRate += iface.RxRate
+		stats.NetworkOutRate += iface.TxRate
+	}
+	return nil
+}
+
+// 不参与容量统计的伪文件系统
+var pseudoFilesystems = map[string]bool{
+	"tmpfs": true, "devtmpfs": true, "ramfs": true, "squashfs": true, "proc": true,
+	"sysfs": true, "devpts": true, "cgroup": true, "cgroup2": true, "overlay": true,
+	"nsfs": true, "tracefs": true, "debugfs": true, "securityfs": true, "pstore": true,
+	"mqueue": true, "hugetlbfs": true, "autofs": true, "fusectl": true, "configfs": true,
+	"bpf": true, "binfmt_misc": true, "efivarfs": true, "rpc_pipefs": true, "shm": true,
+}
+
+// 解析 /proc/mounts，返回挂载点到文件系统类型的映射
+func parseMounts(lines []string) map[string]string {
+	types := make(map[string]string)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		// /proc/mounts 中空格等字符以八进制转义
+		mountPoint := strings.NewReplacer(`\040`, " ", `\011`, "\t", `\134`, `\`).Replace(fields[1])
+		types[mountPoint] = fields[2]
+	}
+	return types
+}
+
+// df -P 的一行：设备、总量、已用、可用、使用率、挂载点（挂载点可能含空格）
+func parseDFLine(line string) (device string, total, used, avail uint64, mountPoint string, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 6 || fields[0] == "Filesystem" {
+		return "", 0, 0, 0, "", false
+	}
+	var err error
+	if total, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return "", 0, 0, 0, "", false
+	}
+	if used, err = strconv.ParseUint(fields[2], 10, 64); err != nil {
+		return "", 0, 0, 0, "", false
+	}
+	if avail, err = strconv.ParseUint(fields[3], 10, 64); err != nil {
+		return "", 0, 0, 0, "", false
+	}
+	return fields[0], total, used, avail, strings.Join(fields[5:], " "), true
+}
+
+// 解析 df -Pk 输出（1K块），记录所有真实文件系统，根文件系统同时写入 Disk* 字段
+func parseDF(stats *models.HostStats, lines, mounts []string) error {
+	types := parseMounts(mounts)
+	seen := make(map[string]bool)
+
+	for _, line := range lines {
+		device, total, used, avail, mountPoint, ok := parseDFLine(line)
+		if !ok || seen[mountPoint] {
+			continue
+		}
+		seen[mountPoint] = true
+
+		fsType := types[mountPoint]
+		if mountPoint != "/" && (total == 0 || pseudoFilesystems[fsType]) {
+			continue
+		}
+
+		fs := models.FilesystemStats{
+			Device:     device,
+			MountPoint: mountPoint,
+			FSType:     fsType,
+			Total:      total * 1024,
+			Used:       used * 1024,
+			Available:  avail * 1024,
+		}
+		// 与 df 一致，使用率按 已用/(已用+可用) 计算，不含保留块
+		if used+avail > 0 {
+			fs.Usage = float64(used) / float64(used+avail) * 100
+		}
+		stats.Filesystems = append(stats.Filesystems, fs)
+
+		if mountPoint == "/" {
+			stats.DiskTotal = fs.Total
+			stats.DiskUsed = fs.Used
+			stats.DiskUsage = fs.Usage
+		}
+	}
+
+	if len(stats.Filesystems) == 0 {
+		if len(lines) > 0 {
+			return fmt.Errorf("无法解析 df 输出: %s", strings.Join(lines, " "))
+		}
+		return errors.New("无法执行 df")
+	}
+	return nil
+}
+
+// 解析 df -Pi 输出，补充各文件系统的inode使用情况
+func parseDFInodes(stats *models.HostStats, lines []string) error {
+	matched := false
+	for _, line := range lines {
+		_, total, used, _, mountPoint, ok := parseDFLine(line)
+		if !ok {
+			continue
+		}
+		for i := range stats.Filesystems {
+			fs := &stats.Filesystems[i]
+			if fs.MountPoint != mountPoint {
+				continue
+			}
+			fs.InodesTotal = total
+			fs.InodesUsed = used
+			if total > 0 {
+				fs.InodeUsage = float64(used) / float64(total) * 100
+			}
+			matched = true
+		}
+	}
+
+	if !matched && len(stats.Filesystems) > 0 {
+		return errors.New("无法获取inode使用情况（df 不支持 -i）")
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"host-manager/models"
+)
+
+const systemdTimeout = 2 * time.Minute
+
+var (
+	// 单元名只允许 systemd 规定的字符（含 \x2d 形式的转义）
+	systemdUnitPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9@._:\\-]*$`)
+	// journalctl --since/--until 接受的时间写法，如 "2024-01-01 10:00"、"-1h"、"today"
+	journalTimePattern = regexp.MustCompile(`^[0-9A-Za-z :.+\-]+$`)
+)
+
+// 可管理的单元类型
+var systemdUnitTypes = map[string]bool{
+	"service": true, "socket": true, "timer": true, "mount": true, "target": true, "path": true,
+}
+
+// 单元操作及操作后查询状态的命令
+var systemdActions = map[string]string{
+	"start":   "is-active",
+	"stop":    "is-active",
+	"restart": "is-active",
+	"reload":  "is-active",
+	"enable":  "is-enabled",
+	"disable": "is-enabled",
+}
+
+// ValidateSystemdUnit 校验单元名
+func ValidateSystemdUnit(unit string) error {
+	if len(unit) > 256 || !systemdUnitPattern.MatchString(unit) {
+		return fmt.Errorf("无效的单元名: %s", unit)
+	}
+	return nil
+}
+
+// ValidSystemdAction 判断是否为支持的操作
+func ValidSystemdAction(action string) bool {
+	_, ok := systemdActions[action]
+	return ok
+}
+
+// ListSystemdUnits 列出主机上指定类型的单元，包括已安装但未加载的单元
+func (s *SSHService) ListSystemdUnits(host *models.Host, unitType string) ([]models.SystemdUnit, error) {
+	if !systemdUnitTypes[unitType] {
+		return nil, fmt.Errorf("不支持的单元类型: %s", unitType)
+	}
+
+	script := fmt.Sprintf(`export LC_ALL=C SYSTEMD_COLORS=0
+command -v systemctl >/dev/null 2>&1 || { echo '@@NO_SYSTEMD'; exit 1; }
+echo '==UNITS=='; systemctl list-units --all --type=%[1]s --no-legend --no-pager --plain
+echo '==FILES=='; systemctl list-unit-files --type=%[1]s --no-legend --no-pager
+echo '==END=='
+`, unitType)
+
+	output, err := s.RunCommand(host, "sh -s", strings.NewReader(script), systemdTimeout)
+	if strings.HasPrefix(output, "@@NO_SYSTEMD") {
+		return nil, fmt.Errorf("主机未使用 systemd")
+	}
+	sections := splitStatsSections(output)
+	if err != nil && len(sections["UNITS"]) == 0 {
+		return nil, err
+	}
+
+	units := make(map[string]*models.SystemdUnit)
+	for _, line := range sections["UNITS"] {
+		// 较旧的 systemd 即使指定 --plain 也会在失败的单元前输出 ●
+		line = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "●"))
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+		unit := &models.SystemdUnit{
+			Name:   fields[0],
+			Load:   fields[1],
+			Active: fields[2],
+			Sub:    fields[3],
+		}
+		if len(fields) > 4 {
+			unit.Description = strings.Join(fields[4:], " ")
+		}
+		units[unit.Name] = unit
+	}
+
+	for _, line := range sections["FILES"] {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		name := fields[0]
+		if unit, ok := units[name]; ok {
+			unit.UnitFileState = fields[1]
+			continue
+		}
+		// 模板单元（如 getty@.service）不能直接启动
+		if strings.Contains(name, "@.") {
+			continue
+		}
+		units[name] = &models.SystemdUnit{
+			Name:          name,
+			Load:          "not-loaded",
+			Active:        "inactive",
+			Sub:           "dead",
+			UnitFileState: fields[1],
+		}
+	}
+
+	result := make([]models.SystemdUnit, 0, len(units))
+	for _, unit := range units {
+		result = append(result, *unit)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// SystemdAction 对单元执行 start/stop/restart/reload/enable/disable，返回操作后的状态和输出
+func (s *SSHService) SystemdAction(host *models.Host, unit, action string) (string, string, error) {
+	if err := ValidateSystemdUnit(unit); err != nil {
+		return "", "", err
+	}
+	stateCmd, ok := systemdActions[action]
+	if !ok {
+		return "", "", fmt.Errorf("不支持的操作: %s", action)
+	}
+
+	// 无论操作是否成功都输出当前状态，以标记与操作输出区分
+	quoted := shellQuote(unit)
+	command := fmt.Sprintf("systemctl %s -- %s; rc=$?; echo; echo @@STATE $(systemctl %s -- %s); exit $rc",
+		action, quoted, stateCmd, quoted)
+	output, err := s.RunPrivileged(host, command, systemdTimeout)
+
+	state := ""
+	if i := strings.LastIndex(output, "@@STATE"); i >= 0 {
+		state = strings.TrimSpace(output[i+len("@@STATE"):])
+		output = output[:i]
+	}
+	return state, strings.TrimSpace(output), err
+}
+
+// JournalOptions journalctl 查询参数
+type JournalOptions struct {
+	Lines    int    // 最多返回的行数
+	Since    string // 开始时间
+	Until    string // 结束时间
+	Priority string // 日志级别，如 err、warning 或 0-7
+}
+
+var journalPriorities = map[string]bool{
+	"emerg": true, "alert": true, "crit": true, "err": true, "warning": true, "notice": true, "info": true, "debug": true,
+	"0": true, "1": true, "2": true, "3": true, "4": true, "5": true, "6": true, "7": true,
+}
+
+// SystemdJournal 获取单元的 journalctl 日志
+func (s *SSHService) SystemdJournal(host *models.Host, unit string, opts JournalOptions) (string, error) {
+	if err := ValidateSystemdUnit(unit); err != nil {
+		return "", err
+	}
+	if opts.Lines <= 0 {
+		opts.Lines = 200
+	}
+
+	args := []string{"journalctl", "--no-pager", "-o", "short-iso", "-u", shellQuote(unit), "-n", strconv.Itoa(opts.Lines)}
+	if opts.Since != "" {
+		if !journalTimePattern.MatchString(opts.Since) {
+			return "", fmt.Errorf("无效的开始时间")
+		}
+		args = append(args, "--since", shellQuote(opts.Since))
+	}
+	if opts.Until != "" {
+		if !journalTimePattern.MatchString(opts.Until) {
+			return "", fmt.Errorf("无效的结束时间")
+		}
+		args = append(args, "--until", shellQuote(opts.Until))
+	}
+	if opts.Priority != "" {
+		if !journalPriorities[opts.Priority] {
+			return "", fmt.Errorf("无效的日志级别")
+		}
+		args = append(args, "-p", opts.Priority)
+	}
+
+	// 非 systemd-journal 组的用户只能看到自己的日志，因此同样以特权执行
+	return s.RunPrivileged(host, "LC_ALL=C "+strings.Join(args, " "), systemdTimeout)
+}
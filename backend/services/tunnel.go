@@ -0,0 +1,494 @@
+package services
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"host-manager/config"
+	"host-manager/models"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultTunnelTTL      = time.Hour
+	maxTunnelTTL          = 24 * time.Hour
+	defaultTunnelsPerUser = 5
+	tunnelDialTimeout     = 10 * time.Second
+	tunnelModeLocal       = "local"
+	tunnelModeDynamic     = "dynamic"
+	tunnelModeWebSocket   = "websocket"
+	tunnelStatusActive    = "active"
+	tunnelStatusClosed    = "closed"
+	tunnelDefaultBindAddr = "127.0.0.1"
+	tunnelDefaultTarget   = "127.0.0.1"
+)
+
+// TunnelService 管理经由SSH direct-tcpip 通道建立的端口转发
+type TunnelService struct {
+	sshService   *SSHService
+	auditService *AuditService
+
+	mu      sync.Mutex
+	tunnels map[uint]*activeTunnel
+	pending map[uint]int // 正在建立中的隧道数，按用户计入数量限制
+}
+
+type activeTunnel struct {
+	record   models.PortForward
+	client   *ssh.Client
+	listener net.Listener
+	timer    *time.Timer
+
+	bytesIn   int64
+	bytesOut  int64
+	connCount int64
+
+	closeOnce sync.Once
+}
+
+var (
+	tunnelServiceOnce     sync.Once
+	tunnelServiceInstance *TunnelService
+)
+
+// GetTunnelService 返回全局端口转发服务
+func GetTunnelService() *TunnelService {
+	tunnelServiceOnce.Do(func() {
+		tunnelServiceInstance = &TunnelService{
+			sshService:   NewSSHService(),
+			auditService: NewAuditService(),
+			tunnels:      make(map[uint]*activeTunnel),
+			pending:      make(map[uint]int),
+		}
+	})
+	return tunnelServiceInstance
+}
+
+// CloseStale 服务启动时将上次遗留的隧道记录标记为关闭
+func (t *TunnelService) CloseStale() {
+	now := time.Now()
+	config.DB.Model(&models.PortForward{}).
+		Where("status = ?", tunnelStatusActive).
+		Updates(map[string]interface{}{
+			"status":       tunnelStatusClosed,
+			"close_reason": "服务重启",
+			"closed_at":    &now,
+		})
+}
+
+func tunnelsPerUser() int {
+	if v, err := strconv.Atoi(os.Getenv("TUNNEL_MAX_PER_USER")); err == nil && v > 0 {
+		return v
+	}
+	return defaultTunnelsPerUser
+}
+
+// 监听端口上的连接不做认证，只允许监听回环地址，避免把主机所在的内网暴露成开放代理
+func tunnelBindAddr() (string, error) {
+	addr := os.Getenv("TUNNEL_BIND_ADDR")
+	if addr == "" {
+		return tunnelDefaultBindAddr, nil
+	}
+	if addr != "localhost" {
+		if ip := net.ParseIP(addr); ip == nil || !ip.IsLoopback() {
+			return "", fmt.Errorf("TUNNEL_BIND_ADDR 必须是回环地址，当前为 %s", addr)
+		}
+	}
+	return addr, nil
+}
+
+// Open 建立端口转发隧道
+func (t *TunnelService) Open(user *models.User, req models.TunnelRequest) (*models.PortForward, error) {
+	switch req.Mode {
+	case tunnelModeLocal, tunnelModeWebSocket:
+		if req.TargetPort <= 0 || req.TargetPort > 65535 {
+			return nil, errors.New("无效的目标端口")
+		}
+		if req.TargetHost == "" {
+			req.TargetHost = tunnelDefaultTarget
+		}
+	case tunnelModeDynamic:
+		req.TargetHost = ""
+		req.TargetPort = 0
+	default:
+		return nil, fmt.Errorf("不支持的转发模式 %q（支持 local、dynamic、websocket）", req.Mode)
+	}
+	if req.ListenPort < 0 || req.ListenPort > 65535 {
+		return nil, errors.New("无效的监听端口")
+	}
+
+	ttl := time.Duration(req.TTLSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = defaultTunnelTTL
+	}
+	if ttl > maxTunnelTTL {
+		return nil, fmt.Errorf("隧道有效期不能超过%v", maxTunnelTTL)
+	}
+
+	// 先占用名额再建立连接，避免并发请求超出数量限制
+	if !t.reserve(user.ID) {
+		return nil, fmt.Errorf("每个用户最多同时保持%d个隧道", tunnelsPerUser())
+	}
+	published := false
+	defer func() {
+		if !published {
+			t.release(user.ID)
+		}
+	}()
+
+	var host models.Host
+	if err := config.DB.First(&host, req.HostID).Error; err != nil {
+		return nil, errors.New("主机不存在")
+	}
+
+	target := net.JoinHostPort(req.TargetHost, strconv.Itoa(req.TargetPort))
+	if req.Mode == tunnelModeDynamic {
+		target = "*"
+	}
+
+	client, err := t.sshService.createConnection(&host)
+	if err != nil {
+		t.auditService.RecordAction(user.ID, host.ID, "tunnel.open", target, req.Mode, err)
+		return nil, err
+	}
+
+	tunnel := &activeTunnel{
+		client: client,
+		record: models.PortForward{
+			UserID:     user.ID,
+			HostID:     host.ID,
+			Mode:       req.Mode,
+			TargetHost: req.TargetHost,
+			TargetPort: req.TargetPort,
+			Status:     tunnelStatusActive,
+			ExpiresAt:  time.Now().Add(ttl),
+		},
+	}
+
+	if req.Mode != tunnelModeWebSocket {
+		bindAddr, err := tunnelBindAddr()
+		if err != nil {
+			client.Close()
+			t.auditService.RecordAction(user.ID, host.ID, "tunnel.open", target, req.Mode, err)
+			return nil, err
+		}
+		listener, err := net.Listen("tcp", net.JoinHostPort(bindAddr, strconv.Itoa(req.ListenPort)))
+		if err != nil {
+			client.Close()
+			err = fmt.Errorf("监听端口失败: %v", err)
+			t.auditService.RecordAction(user.ID, host.ID, "tunnel.open", target, req.Mode, err)
+			return nil, err
+		}
+		tunnel.listener = listener
+		tunnel.record.ListenAddr = listener.Addr().String()
+	}
+
+	if err := config.DB.Create(&tunnel.record).Error; err != nil {
+		if tunnel.listener != nil {
+			tunnel.listener.Close()
+		}
+		client.Close()
+		return nil, fmt.Errorf("保存隧道记录失败: %v", err)
+	}
+
+	// 定时器在隧道可被关闭之前创建
+	tunnel.timer = time.AfterFunc(ttl, func() {
+		t.close(tunnel, "已到期")
+	})
+
+	t.mu.Lock()
+	if t.pending[user.ID]--; t.pending[user.ID] <= 0 {
+		delete(t.pending, user.ID)
+	}
+	t.tunnels[tunnel.record.ID] = tunnel
+	t.mu.Unlock()
+	published = true
+
+	t.auditService.RecordAction(user.ID, host.ID, "tunnel.open", target,
+		fmt.Sprintf("mode=%s listen=%s ttl=%v", req.Mode, tunnel.record.ListenAddr, ttl), nil)
+
+	// SSH连接断开时隧道随之关闭
+	go func() {
+		client.Wait()
+		t.close(tunnel, "SSH连接断开")
+	}()
+	if tunnel.listener != nil {
+		go t.acceptLoop(tunnel)
+	}
+
+	record := tunnel.record
+	record.Host = host
+	record.User = *user
+	return &record, nil
+}
+
+// 未超出数量限制时为用户占用一个隧道名额
+func (t *TunnelService) reserve(userID uint) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	count := t.pending[userID]
+	for _, tunnel := range t.tunnels {
+		if tunnel.record.UserID == userID {
+			count++
+		}
+	}
+	if count >= tunnelsPerUser() {
+		return false
+	}
+	t.pending[userID]++
+	return true
+}
+
+// 释放建立失败的隧道占用的名额
+func (t *TunnelService) release(userID uint) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.pending[userID]--; t.pending[userID] <= 0 {
+		delete(t.pending, userID)
+	}
+}
+
+func (t *TunnelService) acceptLoop(tunnel *activeTunnel) {
+	for {
+		conn, err := tunnel.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			if tunnel.record.Mode == tunnelModeDynamic {
+				t.serveSOCKS(tunnel, conn)
+				return
+			}
+			t.forward(tunnel, conn, net.JoinHostPort(tunnel.record.TargetHost, strconv.Itoa(tunnel.record.TargetPort)))
+		}()
+	}
+}
+
+// 经由SSH连接目标并双向转发数据，阻塞直至任一方向结束
+func (t *TunnelService) forward(tunnel *activeTunnel, conn io.ReadWriteCloser, target string) error {
+	defer conn.Close()
+
+	remote, err := tunnel.client.Dial("tcp", target)
+	if err != nil {
+		return fmt.Errorf("连接目标 %s 失败: %v", target, err)
+	}
+	defer remote.Close()
+
+	atomic.AddInt64(&tunnel.connCount, 1)
+	pipe(tunnel, conn, remote)
+	return nil
+}
+
+func pipe(tunnel *activeTunnel, local io.ReadWriteCloser, remote net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		n, _ := io.Copy(remote, local)
+		atomic.AddInt64(&tunnel.bytesOut, n)
+		remote.Close()
+		done <- struct{}{}
+	}()
+	go func() {
+		n, _ := io.Copy(local, remote)
+		atomic.AddInt64(&tunnel.bytesIn, n)
+		local.Close()
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+// 最小化的SOCKS5服务端实现，仅支持无认证的CONNECT命令
+func (t *TunnelService) serveSOCKS(tunnel *activeTunnel, conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(tunnelDialTimeout))
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil || header[0] != 0x05 {
+		conn.Close()
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		conn.Close()
+		return
+	}
+	conn.Write([]byte{0x05, 0x00})
+
+	request := make([]byte, 4)
+	if _, err := io.ReadFull(conn, request); err != nil || request[0] != 0x05 {
+		conn.Close()
+		return
+	}
+	if request[1] != 0x01 {
+		// 仅支持 CONNECT
+		conn.Write([]byte{0x05, 0x07, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+
+	var targetHost string
+	switch request[3] {
+	case 0x01:
+		addr := make([]byte, 4)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			conn.Close()
+			return
+		}
+		targetHost = net.IP(addr).String()
+	case 0x04:
+		addr := make([]byte, 16)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			conn.Close()
+			return
+		}
+		targetHost = net.IP(addr).String()
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			conn.Close()
+			return
+		}
+		name := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			conn.Close()
+			return
+		}
+		targetHost = string(name)
+	default:
+		conn.Write([]byte{0x05, 0x08, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		conn.Close()
+		return
+	}
+	target := net.JoinHostPort(targetHost, strconv.Itoa(int(binary.BigEndian.Uint16(portBytes))))
+
+	remote, err := tunnel.client.Dial("tcp", target)
+	t.auditService.RecordAction(tunnel.record.UserID, tunnel.record.HostID, "tunnel.connect", target,
+		fmt.Sprintf("tunnel=%d", tunnel.record.ID), err)
+	if err != nil {
+		conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+		conn.Close()
+		return
+	}
+	conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+	conn.SetDeadline(time.Time{})
+
+	atomic.AddInt64(&tunnel.connCount, 1)
+	pipe(tunnel, conn, remote)
+	conn.Close()
+	remote.Close()
+}
+
+// ServeStream 将一个字节流（如WebSocket连接）转发到 websocket 模式隧道的目标
+func (t *TunnelService) ServeStream(id uint, user *models.User, stream io.ReadWriteCloser) error {
+	tunnel, err := t.authorized(id, user)
+	if err != nil {
+		return err
+	}
+	if tunnel.record.Mode != tunnelModeWebSocket {
+		return errors.New("该隧道不是WebSocket模式")
+	}
+
+	return t.forward(tunnel, stream, net.JoinHostPort(tunnel.record.TargetHost, strconv.Itoa(tunnel.record.TargetPort)))
+}
+
+func (t *TunnelService) authorized(id uint, user *models.User) (*activeTunnel, error) {
+	t.mu.Lock()
+	tunnel, ok := t.tunnels[id]
+	t.mu.Unlock()
+	if !ok {
+		return nil, errors.New("隧道不存在或已关闭")
+	}
+	if tunnel.record.UserID != user.ID && user.Role != "admin" {
+		return nil, errors.New("无权操作该隧道")
+	}
+	return tunnel, nil
+}
+
+// Close 主动关闭隧道
+func (t *TunnelService) Close(id uint, user *models.User) error {
+	tunnel, err := t.authorized(id, user)
+	if err != nil {
+		return err
+	}
+	t.close(tunnel, "用户关闭")
+	if user.ID != tunnel.record.UserID {
+		t.auditService.RecordAction(user.ID, tunnel.record.HostID, "tunnel.close", strconv.Itoa(int(id)), "管理员关闭", nil)
+	}
+	return nil
+}
+
+func (t *TunnelService) close(tunnel *activeTunnel, reason string) {
+	tunnel.closeOnce.Do(func() {
+		t.mu.Lock()
+		delete(t.tunnels, tunnel.record.ID)
+		t.mu.Unlock()
+
+		if tunnel.timer != nil {
+			tunnel.timer.Stop()
+		}
+		if tunnel.listener != nil {
+			tunnel.listener.Close()
+		}
+		tunnel.client.Close()
+
+		now := time.Now()
+		err := config.DB.Model(&models.PortForward{}).Where("id = ?", tunnel.record.ID).
+			Updates(map[string]interface{}{
+				"status":       tunnelStatusClosed,
+				"close_reason": reason,
+				"closed_at":    &now,
+				"bytes_in":     atomic.LoadInt64(&tunnel.bytesIn),
+				"bytes_out":    atomic.LoadInt64(&tunnel.bytesOut),
+				"conn_count":   atomic.LoadInt64(&tunnel.connCount),
+			}).Error
+		if err != nil {
+			log.Printf("Failed to update tunnel %d: %v", tunnel.record.ID, err)
+		}
+	})
+}
+
+// List 查询隧道记录，活动中的隧道返回实时流量统计；all为false时只返回当前用户的隧道
+func (t *TunnelService) List(user *models.User, all bool, activeOnly bool) ([]models.PortForward, error) {
+	query := config.DB.Preload("Host").Preload("User").Order("created_at DESC")
+	if !all || user.Role != "admin" {
+		query = query.Where("user_id = ?", user.ID)
+	}
+	if activeOnly {
+		query = query.Where("status = ?", tunnelStatusActive)
+	} else {
+		query = query.Limit(200)
+	}
+
+	var records []models.PortForward
+	if err := query.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i := range records {
+		if tunnel, ok := t.tunnels[records[i].ID]; ok {
+			records[i].BytesIn = atomic.LoadInt64(&tunnel.bytesIn)
+			records[i].BytesOut = atomic.LoadInt64(&tunnel.bytesOut)
+			records[i].ConnCount = atomic.LoadInt64(&tunnel.connCount)
+		}
+	}
+	return records, nil
+}
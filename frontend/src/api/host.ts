@@ -1,5 +1,5 @@
 import axios from 'axios'
-import type { Host, HostStats, CreateHostRequest } from '@/types/host'
+import type { Host, HostStats, CreateHostRequest, HostListParams, HostListResponse } from '@/types/host'
 import { API_CONFIG } from '@/config/api'
 
 const api = axios.create({
@@ -35,9 +35,27 @@ api.interceptors.response.use(
 )
 
 export const hostApi = {
-  // 获取主机列表
-  getHosts: () => {
-    return api.get<{ data: Host[] }>('/hosts')
+  // 获取主机列表（分页）
+  getHosts: (params?: HostListParams) => {
+    // tag 需序列化为 tag=a&tag=b
+    return api.get<{ data: HostListResponse }>('/hosts', { params, paramsSerializer: { indexes: null } })
+  },
+
+  // 获取全部主机，按服务端单页上限逐页拉取
+  getAllHosts: async (params?: Omit<HostListParams, 'page' | 'page_size'>) => {
+    const pageSize = 1000
+    const hosts: Host[] = []
+    for (let page = 1; ; page++) {
+      const response = await api.get<{ data: HostListResponse }>('/hosts', {
+        params: { ...params, page, page_size: pageSize },
+        paramsSerializer: { indexes: null }
+      })
+      const data = response.data.data
+      hosts.push(...data.hosts)
+      if (data.hosts.length < pageSize || hosts.length >= data.total) {
+        return hosts
+      }
+    }
   },
 
   // 创建主机
@@ -55,6 +73,11 @@ export const hostApi = {
     return api.delete(`/hosts/${id}`)
   },
 
+  // 诊断主机连接
+  testHost: (id: number) => {
+    return api.post(`/hosts/${id}/test`)
+  },
+
   // 获取主机统计信息
   getHostStats: (id: number) => {
     return api.get<{ data: HostStats }>(`/hosts/${id}/stats`)
@@ -11,8 +11,7 @@ export const useHostStore = defineStore('host', () => {
   const fetchHosts = async () => {
     loading.value = true
     try {
-      const response = await hostApi.getHosts()
-      hosts.value = response.data.data
+      hosts.value = await hostApi.getAllHosts()
     } catch (error) {
       console.error('获取主机列表失败:', error)
     } finally {
@@ -11,6 +11,27 @@ export interface Host {
   updated_at: string
 }
 
+export interface HostListParams {
+  q?: string
+  name?: string
+  ip?: string
+  tag?: string[]
+  group_id?: number
+  status?: string
+  os?: string
+  sort?: string
+  order?: 'asc' | 'desc'
+  page?: number
+  page_size?: number
+}
+
+export interface HostListResponse {
+  hosts: Host[]
+  total: number
+  page: number
+  page_size: number
+}
+
 export interface HostStats {
   host_id: number
   cpu_usage: number